@@ -6,38 +6,73 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"gossh/internal/config"
-	"gossh/internal/sshclient"
+	"gossh/internal/mux"
 	"gossh/pkg/ui"
 )
 
 // main 是 SFTP 子命令的入口函数
 // 专门处理文件传输相关的操作
 func main() {
+	// 连接复用的主连接进程以特殊参数启动，不解析其他参数
+	if len(os.Args) > 1 && os.Args[1] == mux.MasterCommand {
+		os.Exit(mux.RunMaster())
+	}
+
 	// 定义 SFTP 专用的命令行参数
 	var (
 		host     = flag.String("host", "", "SFTP 服务器地址 (必填)")
 		port     = flag.Int("port", 22, "SFTP 服务器端口 (默认: 22)")
-		username = flag.String("user", "", "用户名 (必填)")
+		username = flag.String("user", "", "用户名 (未在 ssh 配置中设置时必填)")
 		password = flag.String("pass", "", "密码")
 		keyFile  = flag.String("key", "", "私钥文件路径")
+		useAgent = flag.Bool("agent", false, "使用 SSH_AUTH_SOCK 指向的 ssh-agent 认证")
 		upload   = flag.String("upload", "", "上传文件路径")
 		download = flag.String("download", "", "下载文件路径")
 		remote   = flag.String("remote", "", "远程文件路径")
+		recurse  = flag.Bool("r", false, "递归上传或下载整个目录，-remote 或 -download 是目标目录")
+		symlinks = flag.String("symlinks", "follow", "递归传输时符号链接的处理策略: follow、preserve 或 skip")
+		resume   = flag.Bool("resume", false, "断点续传，从目标文件的末尾继续传输")
+		verify   = flag.Bool("verify", false, "续传前校验目标文件已有部分末尾的哈希，与 -resume 一起使用")
+		progress = flag.String("progress", "bar", "传输进度的显示方式: bar（输出不是终端时改为定期输出日志行）、json 或 none")
+		parallel = flag.Int("concurrency", ui.DefaultConcurrency, "每个文件同时传输的块数，高延迟链路上可以适当增大")
+		chunkLen = flag.Int("chunk-size", ui.DefaultChunkSize, "每块的字节数，超过服务器的最大数据包大小时每块需要多个请求")
+		checksum = flag.Bool("checksum", false, "传输完成后比较本地和远程文件的 SHA-256")
+		preserve = flag.Bool("p", false, "保留源文件的权限、访问时间和修改时间，递归传输时也适用于目录")
+		owner    = flag.Bool("owner", false, "保留源文件的所有者和组，通常需要 root 权限")
+
+		hostKeyPolicy  = flag.String("hostkey", "accept-new", "主机密钥校验策略: strict、accept-new 或 off")
+		knownHostsFile = flag.String("known-hosts", "", "known_hosts 文件路径 (默认: ~/.ssh/known_hosts)")
+		sshConfigFile  = flag.String("F", "", "OpenSSH 客户端配置文件 (默认: ~/.ssh/config)")
+		jumpHosts      = flag.String("J", "", "跳板机列表，如 user@bastion1,user@bastion2:2222")
+		timeout        = flag.Duration("timeout", config.DefaultConnectTimeout, "连接超时时间，如 10s (默认: 30s)")
+		aliveInterval  = flag.Duration("alive-interval", 0, "保活请求的间隔，如 30s，0 表示不发送 (对应 ServerAliveInterval)")
+		aliveCount     = flag.Int("alive-count", config.DefaultServerAliveCountMax, "连续多少次保活没有应答后断开 (对应 ServerAliveCountMax)")
+
+		controlMaster  = flag.Bool("M", false, "连接复用：没有可用的主连接时在后台启动一个，之后的调用通过它连接")
+		controlPath    = flag.String("S", "", "主连接的控制套接字路径，支持 %h %p %r (默认: ~/.ssh/gossh-%r@%h:%p)")
+		controlPersist = flag.String("persist", "", "主连接在最后一个客户端断开后保持的时间，如 10m，yes 表示一直保持 (默认: 立即退出)")
+		controlCmd     = flag.String("O", "", "控制主连接: check 检查是否在运行，exit 让它退出")
 	)
 
 	// 解析命令行参数
 	flag.Parse()
 
 	// 检查必填参数
-	if *host == "" || *username == "" {
-		fmt.Println("错误: 必须提供主机地址和用户名")
+	if *host == "" {
+		fmt.Println("错误: 必须提供主机地址")
 		fmt.Println("\n使用示例:")
 		fmt.Println("  sftp -host=192.168.1.100 -user=root -pass=123456")
 		fmt.Println("  sftp -host=192.168.1.100 -user=root -key=/path/to/key -upload=/local/file -remote=/remote/path")
+		fmt.Println("  sftp -host=192.168.1.100 -user=root -agent -r -p -download=./backup -remote=/var/www")
+		fmt.Println("  sftp -host=192.168.1.100 -user=root -agent -resume -verify -download=./big.iso -remote=/data/big.iso")
+		fmt.Println("  sftp -host=192.168.1.100 -user=root -agent -progress=json -upload=./app.tar.gz -remote=/tmp/app.tar.gz")
+		fmt.Println("  sftp -host=192.168.1.100 -user=root -agent -checksum -upload=./app.tar.gz -remote=/tmp/app.tar.gz")
+		fmt.Println("  sftp -host=myalias   （使用 ~/.ssh/config 中的配置）")
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -45,39 +80,213 @@ func main() {
 	// 创建 SSH 配置
 	cfg := &config.SSHConfig{
 		Host:     *host,
-		Port:     *port,
 		Username: *username,
 		Password: *password,
 		KeyFile:  *keyFile,
+		UseAgent: *useAgent,
+
+		HostKeyPolicy:  config.HostKeyPolicy(*hostKeyPolicy),
+		KnownHostsFile: *knownHostsFile,
+	}
+
+	// 只有明确指定了 -port、-timeout 和保活参数才覆盖 ssh 配置文件中的设置
+	if flagPassed("port") {
+		cfg.Port = *port
+	}
+	if flagPassed("timeout") {
+		cfg.ConnectTimeout = *timeout
+	}
+	if flagPassed("alive-interval") {
+		cfg.ServerAliveInterval = *aliveInterval
+	}
+	if flagPassed("alive-count") {
+		cfg.ServerAliveCountMax = *aliveCount
+	}
+
+	// 从 OpenSSH 客户端配置中补全主机地址、端口、用户名、私钥、连接超时和保活参数
+	hostConfig, err := config.LoadHostConfig(*sshConfigFile, *host)
+	if err != nil {
+		log.Fatalf("读取 SSH 配置文件失败: %v", err)
+	}
+	hostConfig.ApplyTo(cfg)
+	if cfg.Port == 0 {
+		cfg.Port = *port
+	}
+	if cfg.Username == "" {
+		fmt.Println("错误: 必须通过 -user 或 ssh 配置文件中的 User 提供用户名")
+		os.Exit(1)
+	}
+
+	// 在连接之前检查符号链接策略、进度显示方式、分块参数和续传参数
+	if *parallel < 1 || *parallel > ui.MaxConcurrency {
+		fmt.Printf("错误: -concurrency 必须在 1 到 %d 之间\n", ui.MaxConcurrency)
+		os.Exit(1)
+	}
+	if *chunkLen < 1 || *chunkLen > ui.MaxChunkSize {
+		fmt.Printf("错误: -chunk-size 必须在 1 到 %d 之间\n", ui.MaxChunkSize)
+		os.Exit(1)
+	}
+	symlinkPolicy, err := ui.ParseSymlinkPolicy(*symlinks)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+	// -progress=json 时标准输出只包含 JSON 进度，便于其他程序读取，状态信息改为输出到标准错误
+	status := statusOutput(*progress)
+	progressSink, err := ui.NewProgressSink(*progress, os.Stdout)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+	transferOpts := ui.TransferOptions{
+		Progress:      progressSink,
+		Symlinks:      symlinkPolicy,
+		Concurrency:   *parallel,
+		ChunkSize:     int64(*chunkLen),
+		Checksum:      *checksum,
+		Preserve:      *preserve,
+		PreserveOwner: *owner,
+	}
+	if *resume && *recurse {
+		fmt.Println("错误: -resume 不能与 -r 一起使用")
+		os.Exit(1)
+	}
+
+	// 连接复用，命令行参数优先于 ssh 配置文件中的 ControlMaster、ControlPath 和 ControlPersist
+	muxOpts := mux.Options{
+		Master:  *controlMaster || hostConfig.ControlMaster,
+		Persist: hostConfig.ControlPersist,
+	}
+	if flagPassed("persist") {
+		muxOpts.Persist, err = config.ParseControlPersist(*controlPersist)
+		if err != nil {
+			log.Fatalf("解析 -persist 参数失败: %v", err)
+		}
+	}
+	pathSpec := *controlPath
+	if pathSpec == "" {
+		pathSpec = hostConfig.ControlPath
+	}
+	if pathSpec == "" && (muxOpts.Master || *controlCmd != "") {
+		pathSpec = config.DefaultControlPath
+	}
+	if pathSpec != "" && pathSpec != "none" {
+		muxOpts.Path = config.ExpandControlPath(pathSpec, cfg)
+	}
+	if *controlCmd != "" {
+		if muxOpts.Path == "" {
+			log.Fatalf("-O 需要控制套接字路径")
+		}
+		msg, err := mux.RunControlCommand(muxOpts.Path, *controlCmd)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Println(msg)
+		return
 	}
 
-	// 创建 SSH 客户端
-	client, err := sshclient.NewClient(cfg)
+	// 配置跳板机，命令行的 -J 优先于 ssh 配置文件中的 ProxyJump
+	jumpSpec := *jumpHosts
+	if jumpSpec == "" {
+		jumpSpec = hostConfig.ProxyJump
+	}
+	if jumpSpec != "" {
+		cfg.JumpHosts, err = config.ResolveJumpHosts(jumpSpec, *sshConfigFile, cfg)
+		if err != nil {
+			log.Fatalf("解析跳板机失败: %v", err)
+		}
+	}
+
+	// 创建 SSH 客户端，有可用的主连接时通过它连接
+	client, err := mux.Connect(cfg, muxOpts)
 	if err != nil {
 		log.Fatalf("创建 SSH 客户端失败: %v", err)
 	}
 	defer client.Close()
 
 	// 根据参数决定操作模式
-	if *upload != "" && *remote != "" {
+	if *recurse && (*upload != "" || *download != "") && *remote != "" {
+		// 递归传输目录模式，单个文件失败不会中断其余文件，最后列出失败的文件
+		var summary *ui.TransferSummary
+		if *upload != "" {
+			fmt.Fprintf(status, "正在上传目录 %s 到 %s...\n", *upload, *remote)
+			summary, err = ui.UploadDir(client, *upload, *remote, transferOpts)
+		} else {
+			fmt.Fprintf(status, "正在下载目录 %s 到 %s...\n", *remote, *download)
+			summary, err = ui.DownloadDir(client, *remote, *download, transferOpts)
+		}
+		if err != nil {
+			log.Fatalf("目录传输失败: %v", err)
+		}
+		for _, e := range summary.Errors {
+			fmt.Fprintf(os.Stderr, "失败: %v\n", e)
+		}
+		fmt.Fprintf(status, "传输完成: %s\n", summary)
+		if summary.Failed() {
+			client.Close()
+			os.Exit(1)
+		}
+	} else if *resume && (*upload != "" || *download != "") && *remote != "" {
+		// 断点续传模式，目标文件不存在时从头传输
+		var offset int64
+		if *upload != "" {
+			fmt.Fprintf(status, "正在续传文件 %s 到 %s...\n", *upload, *remote)
+			offset, err = ui.ResumeUploadFile(client, *upload, *remote, *verify, transferOpts)
+		} else {
+			fmt.Fprintf(status, "正在续传文件 %s 到 %s...\n", *remote, *download)
+			offset, err = ui.ResumeDownloadFile(client, *remote, *download, *verify, transferOpts)
+		}
+		if err != nil {
+			log.Fatalf("文件续传失败: %v", err)
+		}
+		fmt.Fprintf(status, "文件续传成功! (从第 %d 字节处继续)\n", offset)
+	} else if *upload != "" && *remote != "" {
 		// 上传文件模式
-		fmt.Printf("正在上传文件 %s 到 %s...\n", *upload, *remote)
-		if err := ui.UploadFile(client, *upload, *remote); err != nil {
+		fmt.Fprintf(status, "正在上传文件 %s 到 %s...\n", *upload, *remote)
+		if err := ui.UploadFileWithOptions(client, *upload, *remote, transferOpts); err != nil {
 			log.Fatalf("文件上传失败: %v", err)
 		}
-		fmt.Println("文件上传成功!")
+		fmt.Fprintln(status, "文件上传成功!")
 	} else if *download != "" && *remote != "" {
 		// 下载文件模式
-		fmt.Printf("正在下载文件 %s 到 %s...\n", *remote, *download)
-		if err := ui.DownloadFile(client, *remote, *download); err != nil {
+		fmt.Fprintf(status, "正在下载文件 %s 到 %s...\n", *remote, *download)
+		if err := ui.DownloadFileWithOptions(client, *remote, *download, transferOpts); err != nil {
 			log.Fatalf("文件下载失败: %v", err)
 		}
-		fmt.Println("文件下载成功!")
+		fmt.Fprintln(status, "文件下载成功!")
 	} else {
 		// 交互式 SFTP 模式
-		fmt.Printf("正在启动 SFTP 会话到 %s@%s:%d...\n", *username, *host, *port)
+		fmt.Printf("正在启动 SFTP 会话到 %s@%s:%d...\n", cfg.Username, cfg.Host, cfg.Port)
 		if err := ui.StartSFTPSession(client); err != nil {
 			log.Fatalf("SFTP 会话启动失败: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// statusOutput 返回显示传输状态信息的输出
+// -progress=json 时标准输出只用于 JSON 进度，状态信息输出到标准错误
+// 参数:
+//   progressMode: -progress 参数的值
+// 返回值:
+//   io.Writer: 标准输出或标准错误
+func statusOutput(progressMode string) io.Writer {
+	if progressMode == "json" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// flagPassed 检查命令行中是否明确指定了某个参数
+// 参数:
+//   name: 参数名
+// 返回值:
+//   bool: 如果用户指定了该参数则返回 true
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
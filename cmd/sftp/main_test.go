@@ -0,0 +1,178 @@
+// main_test 通过进程内的 SSH 服务器端到端测试 SFTP 子命令
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// runMainEnv 设置时测试程序作为 sftp 命令运行，用于在子进程中执行 main
+const runMainEnv = "GOSSH_SFTP_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// startSFTPServer 启动只支持 sftp 子系统的进程内 SSH 服务器，接受用户 tester 和密码 secret
+// 参数:
+//   root: SFTP 服务器的工作目录
+// 返回值:
+//   int: 服务器监听的端口
+func startSFTPServer(t *testing.T, root string) int {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == "tester" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("用户名或密码错误")
+		},
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成主机密钥失败: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("创建主机密钥失败: %v", err)
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动测试服务器失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTPConn(conn, config, root)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// serveSFTPConn 完成握手，在每个会话通道的 sftp 子系统请求上运行 SFTP 服务器
+func serveSFTPConn(conn net.Conn, config *ssh.ServerConfig, root string) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "测试服务器只支持会话通道")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				var payload struct{ Name string }
+				if req.Type != "subsystem" || ssh.Unmarshal(req.Payload, &payload) != nil || payload.Name != "sftp" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					return
+				}
+				server.Serve()
+				return
+			}
+		}()
+	}
+}
+
+// runSFTP 在子进程中运行 sftp 命令，分别返回标准输出和标准错误
+func runSFTP(t *testing.T, port int, args ...string) (string, string, error) {
+	t.Helper()
+	home := t.TempDir()
+	args = append([]string{"-host=127.0.0.1", "-port=" + strconv.Itoa(port), "-user=tester", "-pass=secret", "-hostkey=off"}, args...)
+	cmd := exec.Command(os.Args[0], args...)
+	// 使用空的主目录，不读取用户的 ssh 配置和 known_hosts
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "HOME="+home, "USERPROFILE="+home)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+// TestJSONProgressOutput 测试 -progress=json 时标准输出的每一行都是 JSON 进度
+func TestJSONProgressOutput(t *testing.T) {
+	root := t.TempDir()
+	port := startSFTPServer(t, root)
+	local := filepath.Join(t.TempDir(), "app.tar.gz")
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "上传", args: []string{"-upload=" + local, "-remote=app.tar.gz"}},
+		{name: "下载", args: []string{"-download=" + filepath.Join(t.TempDir(), "copy.tar.gz"), "-remote=app.tar.gz"}},
+		{name: "续传", args: []string{"-resume", "-upload=" + local, "-remote=resumed.tar.gz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, err := runSFTP(t, port, append([]string{"-progress=json"}, tt.args...)...)
+			if err != nil {
+				t.Fatalf("sftp 退出: %v\nstderr:\n%s", err, stderr)
+			}
+
+			var done int
+			scanner := bufio.NewScanner(strings.NewReader(stdout))
+			for scanner.Scan() {
+				var event struct {
+					Event string `json:"event"`
+					Done  int64  `json:"done"`
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+					t.Fatalf("标准输出中有不是 JSON 的行 %q: %v", scanner.Text(), err)
+				}
+				if event.Event == "done" {
+					done++
+					if event.Done != int64(len(data)) {
+						t.Errorf("done 事件的 done = %d, want %d", event.Done, len(data))
+					}
+				}
+			}
+			if done != 1 {
+				t.Errorf("收到 %d 个 done 事件, want 1\nstdout:\n%s", done, stdout)
+			}
+			if !strings.Contains(stderr, "成功") {
+				t.Errorf("标准错误中没有状态信息:\n%s", stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package main 的 exec 子命令
+// 在多台主机上并发执行同一条命令，任意一台失败时以非零状态退出
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gossh/internal/config"
+	"gossh/internal/pssh"
+)
+
+// execFlags 是 exec 子命令的连接参数，对所有主机生效
+type execFlags struct {
+	fs *flag.FlagSet
+
+	port           *int
+	username       *string
+	password       *string
+	keyFile        *string
+	useAgent       *bool
+	hostKeyPolicy  *string
+	knownHostsFile *string
+	sshConfigFile  *string
+	jumpHosts      *string
+	connectTimeout *time.Duration
+}
+
+// runExec 运行 exec 子命令
+// 参数:
+//   args: 子命令之后的命令行参数
+// 返回值:
+//   int: 进程退出码，所有主机都成功时为 0，有主机失败时为 1，参数错误时为 2
+func runExec(args []string) int {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	f := &execFlags{
+		fs:       fs,
+		port:     fs.Int("port", 22, "SSH 服务器端口 (默认: 22)"),
+		username: fs.String("user", "", "用户名 (未在主机列表或 ssh 配置中设置时必填)"),
+		password: fs.String("pass", "", "密码"),
+		keyFile:  fs.String("key", "", "私钥文件路径"),
+		useAgent: fs.Bool("agent", false, "使用 SSH_AUTH_SOCK 指向的 ssh-agent 认证"),
+
+		hostKeyPolicy:  fs.String("hostkey", "accept-new", "主机密钥校验策略: strict、accept-new 或 off"),
+		knownHostsFile: fs.String("known-hosts", "", "known_hosts 文件路径 (默认: ~/.ssh/known_hosts)"),
+		sshConfigFile:  fs.String("F", "", "OpenSSH 客户端配置文件 (默认: ~/.ssh/config)"),
+		jumpHosts:      fs.String("J", "", "跳板机列表，如 user@bastion1,user@bastion2:2222"),
+		connectTimeout: fs.Duration("timeout", config.DefaultConnectTimeout, "连接超时时间，如 10s (默认: 30s)"),
+	}
+	var (
+		hostList    = fs.String("hosts", "", "主机列表，如 web[01-10],root@db1:2222")
+		hostsFile   = fs.String("hosts-file", "", "主机列表文件，每行一个主机")
+		parallel    = fs.Int("P", pssh.DefaultConcurrency, "同时连接的主机数")
+		hostTimeout = fs.Duration("host-timeout", 0, "每台主机的总超时时间（连接和执行），如 5m，0 表示不限制")
+		summary     = fs.Bool("summary", false, "结束后按相同输出分组汇总，而不是实时显示带主机名前缀的输出")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: ssh-tool exec [参数] -hosts=主机列表 命令")
+		fmt.Fprintln(fs.Output(), "\n使用示例:")
+		fmt.Fprintln(fs.Output(), "  ssh-tool exec -user=root -agent -hosts=web[01-50] uptime")
+		fmt.Fprintln(fs.Output(), "  ssh-tool exec -hosts-file=hosts.txt -P 20 -summary 'cat /etc/os-release'")
+		fmt.Fprintln(fs.Output(), "\n参数:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	command := strings.Join(fs.Args(), " ")
+	if command == "" {
+		fmt.Fprintln(os.Stderr, "错误: 必须提供要执行的命令")
+		fs.Usage()
+		return 2
+	}
+
+	// 合并 -hosts 和 -hosts-file 中的主机
+	var entries []string
+	if *hostList != "" {
+		hosts, err := config.ExpandHosts(*hostList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return 2
+		}
+		entries = append(entries, hosts...)
+	}
+	if *hostsFile != "" {
+		hosts, err := config.ReadHostsFile(*hostsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return 2
+		}
+		entries = append(entries, hosts...)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 必须通过 -hosts 或 -hosts-file 提供主机")
+		fs.Usage()
+		return 2
+	}
+
+	// 在连接任何主机之前检查所有主机的配置
+	var hosts []pssh.Host
+	for _, entry := range entries {
+		cfg, err := f.hostConfig(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 主机 %s: %v\n", entry, err)
+			return 2
+		}
+		hosts = append(hosts, pssh.Host{Name: entry, Config: cfg})
+	}
+
+	// 收到 Ctrl+C 或 SIGTERM 时终止所有主机上的命令
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := pssh.Options{Concurrency: *parallel, Timeout: *hostTimeout}
+	if !*summary {
+		opts.Output = os.Stdout
+	}
+	results := pssh.Run(ctx, hosts, command, opts)
+
+	if *summary {
+		pssh.WriteSummary(os.Stdout, results)
+	}
+
+	// 最后列出失败的主机
+	failed := 0
+	for _, r := range results {
+		if !r.Failed() {
+			continue
+		}
+		failed++
+		if *summary {
+			continue // 汇总中已经包含错误和退出码
+		}
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "[%s] 错误: %v\n", r.Host, r.Err)
+		case r.Result.ExitSignal != "":
+			fmt.Fprintf(os.Stderr, "[%s] 命令被信号 %s 终止\n", r.Host, r.Result.ExitSignal)
+		default:
+			fmt.Fprintf(os.Stderr, "[%s] 退出码: %d\n", r.Host, r.Result.ExitCode)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "完成: 成功 %d 台，失败 %d 台\n", len(results)-failed, failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// hostConfig 为主机列表中的一项生成连接配置
+// 主机中写出的用户名和端口优先于命令行参数，命令行参数优先于 ssh 配置文件
+// 参数:
+//   entry: 主机，格式为 [user@]host[:port]，host 可以是 ssh 配置文件中的别名
+// 返回值:
+//   *config.SSHConfig: 补全并验证过的配置
+//   error: 如果格式无效或配置不完整则返回错误
+func (f *execFlags) hostConfig(entry string) (*config.SSHConfig, error) {
+	parsed, err := config.ParseHostEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config.SSHConfig{
+		Host:     parsed.Host,
+		Port:     parsed.Port,
+		Username: parsed.Username,
+		Password: *f.password,
+		KeyFile:  *f.keyFile,
+		UseAgent: *f.useAgent,
+
+		HostKeyPolicy:  config.HostKeyPolicy(*f.hostKeyPolicy),
+		KnownHostsFile: *f.knownHostsFile,
+	}
+	if cfg.Username == "" {
+		cfg.Username = *f.username
+	}
+	if cfg.Port == 0 && f.passed("port") {
+		cfg.Port = *f.port
+	}
+	if f.passed("timeout") {
+		cfg.ConnectTimeout = *f.connectTimeout
+	}
+
+	hostConfig, err := config.LoadHostConfig(*f.sshConfigFile, parsed.Host)
+	if err != nil {
+		return nil, fmt.Errorf("读取 SSH 配置文件失败: %w", err)
+	}
+	hostConfig.ApplyTo(cfg)
+	if cfg.Port == 0 {
+		cfg.Port = *f.port
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("必须通过 -user、user@host 或 ssh 配置文件中的 User 提供用户名")
+	}
+
+	jumpSpec := *f.jumpHosts
+	if jumpSpec == "" {
+		jumpSpec = hostConfig.ProxyJump
+	}
+	if jumpSpec != "" {
+		cfg.JumpHosts, err = config.ResolveJumpHosts(jumpSpec, *f.sshConfigFile, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("解析跳板机失败: %w", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// passed 检查命令行中是否明确指定了某个参数
+func (f *execFlags) passed(name string) bool {
+	passed := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
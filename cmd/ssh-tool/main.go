@@ -4,12 +4,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"gossh/internal/config"
+	"gossh/internal/mux"
 	"gossh/internal/sshclient"
 	"gossh/pkg/ui"
 )
@@ -17,27 +23,70 @@ import (
 // main 是程序的入口函数
 // 负责解析命令行参数，初始化配置，启动相应的功能模块
 func main() {
+	// 连接复用的主连接进程以特殊参数启动，不解析其他参数
+	if len(os.Args) > 1 && os.Args[1] == mux.MasterCommand {
+		os.Exit(mux.RunMaster())
+	}
+
+	// 子命令：在多台主机上并发执行命令
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		os.Exit(runExec(os.Args[2:]))
+	}
+
+	// 子命令：回放会话录像
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
 	// 定义命令行参数
 	// 这些参数让用户可以通过命令行指定连接信息
 	var (
 		host     = flag.String("host", "", "SSH 服务器地址 (必填)")
 		port     = flag.Int("port", 22, "SSH 服务器端口 (默认: 22)")
-		username = flag.String("user", "", "用户名 (必填)")
+		username = flag.String("user", "", "用户名 (未在 ssh 配置中设置时必填)")
 		password = flag.String("pass", "", "密码")
 		keyFile  = flag.String("key", "", "私钥文件路径")
+		useAgent = flag.Bool("agent", false, "使用 SSH_AUTH_SOCK 指向的 ssh-agent 认证")
 		mode     = flag.String("mode", "ssh", "运行模式: ssh 或 sftp (默认: ssh)")
+
+		hostKeyPolicy  = flag.String("hostkey", "accept-new", "主机密钥校验策略: strict、accept-new 或 off")
+		knownHostsFile = flag.String("known-hosts", "", "known_hosts 文件路径 (默认: ~/.ssh/known_hosts)")
+		sshConfigFile  = flag.String("F", "", "OpenSSH 客户端配置文件 (默认: ~/.ssh/config)")
+		jumpHosts      = flag.String("J", "", "跳板机列表，如 user@bastion1,user@bastion2:2222")
+		timeout        = flag.Duration("timeout", config.DefaultConnectTimeout, "连接超时时间，如 10s (默认: 30s)")
+		aliveInterval  = flag.Duration("alive-interval", 0, "保活请求的间隔，如 30s，0 表示不发送 (对应 ServerAliveInterval)")
+		aliveCount     = flag.Int("alive-count", config.DefaultServerAliveCountMax, "连续多少次保活没有应答后断开 (对应 ServerAliveCountMax)")
+
+		controlMaster  = flag.Bool("M", false, "连接复用：没有可用的主连接时在后台启动一个，之后的调用通过它连接")
+		controlPath    = flag.String("S", "", "主连接的控制套接字路径，支持 %h %p %r (默认: ~/.ssh/gossh-%r@%h:%p)")
+		controlPersist = flag.String("persist", "", "主连接在最后一个客户端断开后保持的时间，如 10m，yes 表示一直保持 (默认: 立即退出)")
+		controlCmd     = flag.String("O", "", "控制主连接: check 检查是否在运行，exit 让它退出")
 	)
 
+	// 可以重复指定的参数
+	var localForwards stringList
+	var remoteForwards stringList
+	var dynamicForwards stringList
+	flag.Var(&localForwards, "L", "本地端口转发 [bind_address:]port:host:hostport，可重复指定")
+	flag.Var(&remoteForwards, "R", "远程端口转发 [bind_address:]port:host:hostport，可重复指定")
+	flag.Var(&dynamicForwards, "D", "动态端口转发（SOCKS 代理） [bind_address:]port，可重复指定")
+	noShell := flag.Bool("N", false, "不启动远程 shell，只运行端口转发")
+	reconnect := flag.Bool("reconnect", false, "配合 -N 使用，连接断开后自动重连并恢复端口转发")
+	escapeChar := flag.String("e", "~", "交互式会话的转义字符，如 ~ 或 ^]，none 表示禁用")
+	recordFile := flag.String("record", "", "把交互式会话录制到 asciicast v2 格式的文件，可以用 replay 子命令或 asciinema 回放")
+	recordInput := flag.Bool("record-input", false, "录像中同时记录键盘输入（可能包含密码）")
+
 	// 解析命令行参数
 	flag.Parse()
 
 	// 检查必填参数
 	// 如果用户没有提供必要的连接信息，显示帮助信息并退出
-	if *host == "" || *username == "" {
-		fmt.Println("错误: 必须提供主机地址和用户名")
+	if *host == "" {
+		fmt.Println("错误: 必须提供主机地址")
 		fmt.Println("\n使用示例:")
 		fmt.Println("  ssh-tool -host=192.168.1.100 -user=root -pass=123456")
 		fmt.Println("  ssh-tool -host=192.168.1.100 -user=root -key=/path/to/key -mode=sftp")
+		fmt.Println("  ssh-tool -host=myalias   （使用 ~/.ssh/config 中的配置）")
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -46,33 +95,207 @@ func main() {
 	// 将用户输入的参数封装成配置结构体
 	cfg := &config.SSHConfig{
 		Host:     *host,
-		Port:     *port,
 		Username: *username,
 		Password: *password,
 		KeyFile:  *keyFile,
+		UseAgent: *useAgent,
+
+		HostKeyPolicy:  config.HostKeyPolicy(*hostKeyPolicy),
+		KnownHostsFile: *knownHostsFile,
+	}
+
+	// 只有明确指定了 -port、-timeout 和保活参数才覆盖 ssh 配置文件中的设置
+	if flagPassed("port") {
+		cfg.Port = *port
+	}
+	if flagPassed("timeout") {
+		cfg.ConnectTimeout = *timeout
+	}
+	if flagPassed("alive-interval") {
+		cfg.ServerAliveInterval = *aliveInterval
+	}
+	if flagPassed("alive-count") {
+		cfg.ServerAliveCountMax = *aliveCount
+	}
+
+	// 从 OpenSSH 客户端配置中补全主机地址、端口、用户名、私钥、连接超时和保活参数
+	hostConfig, err := config.LoadHostConfig(*sshConfigFile, *host)
+	if err != nil {
+		log.Fatalf("读取 SSH 配置文件失败: %v", err)
+	}
+	hostConfig.ApplyTo(cfg)
+	if cfg.Port == 0 {
+		cfg.Port = *port
+	}
+	if cfg.Username == "" {
+		fmt.Println("错误: 必须通过 -user 或 ssh 配置文件中的 User 提供用户名")
+		os.Exit(1)
+	}
+
+	// 在连接之前检查转义字符，避免连接成功后才报错
+	escape, err := ui.ParseEscapeChar(*escapeChar)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 连接复用，命令行参数优先于 ssh 配置文件中的 ControlMaster、ControlPath 和 ControlPersist
+	muxOpts := mux.Options{
+		Master:  *controlMaster || hostConfig.ControlMaster,
+		Persist: hostConfig.ControlPersist,
+	}
+	if flagPassed("persist") {
+		muxOpts.Persist, err = config.ParseControlPersist(*controlPersist)
+		if err != nil {
+			log.Fatalf("解析 -persist 参数失败: %v", err)
+		}
+	}
+	pathSpec := *controlPath
+	if pathSpec == "" {
+		pathSpec = hostConfig.ControlPath
+	}
+	if pathSpec == "" && (muxOpts.Master || *controlCmd != "") {
+		pathSpec = config.DefaultControlPath
+	}
+	if pathSpec != "" && pathSpec != "none" {
+		muxOpts.Path = config.ExpandControlPath(pathSpec, cfg)
+	}
+	if *controlCmd != "" {
+		if muxOpts.Path == "" {
+			log.Fatalf("-O 需要控制套接字路径")
+		}
+		msg, err := mux.RunControlCommand(muxOpts.Path, *controlCmd)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Println(msg)
+		return
+	}
+
+	// 先解析端口转发参数，格式错误时不必建立连接
+	var localSpecs []config.ForwardSpec
+	for _, spec := range localForwards {
+		forwardSpec, err := config.ParseForwardSpec(spec)
+		if err != nil {
+			log.Fatalf("解析 -L 参数失败: %v", err)
+		}
+		localSpecs = append(localSpecs, forwardSpec)
+	}
+	var remoteSpecs []config.ForwardSpec
+	for _, spec := range remoteForwards {
+		forwardSpec, err := config.ParseForwardSpec(spec)
+		if err != nil {
+			log.Fatalf("解析 -R 参数失败: %v", err)
+		}
+		remoteSpecs = append(remoteSpecs, forwardSpec)
+	}
+	var dynamicAddrs []string
+	for _, spec := range dynamicForwards {
+		addr, err := config.ParseDynamicForwardSpec(spec)
+		if err != nil {
+			log.Fatalf("解析 -D 参数失败: %v", err)
+		}
+		dynamicAddrs = append(dynamicAddrs, addr)
+	}
+	if *noShell && len(localSpecs) == 0 && len(remoteSpecs) == 0 && len(dynamicAddrs) == 0 {
+		fmt.Println("错误: -N 需要配合 -L、-R 或 -D 使用")
+		os.Exit(1)
+	}
+	if *reconnect && !*noShell {
+		fmt.Println("错误: -reconnect 只能配合 -N 使用")
+		os.Exit(1)
+	}
+
+	// 配置跳板机，命令行的 -J 优先于 ssh 配置文件中的 ProxyJump
+	jumpSpec := *jumpHosts
+	if jumpSpec == "" {
+		jumpSpec = hostConfig.ProxyJump
+	}
+	if jumpSpec != "" {
+		cfg.JumpHosts, err = config.ResolveJumpHosts(jumpSpec, *sshConfigFile, cfg)
+		if err != nil {
+			log.Fatalf("解析跳板机失败: %v", err)
+		}
 	}
 
-	// 创建 SSH 客户端
+	// 自动重连时由 ReconnectingClient 负责连接和恢复端口转发
+	if *reconnect {
+		runReconnecting(cfg, localSpecs, remoteSpecs, dynamicAddrs)
+		return
+	}
+
+	// 创建 SSH 客户端，有可用的主连接时通过它连接
 	// 这个客户端负责实际的 SSH 连接和操作
-	client, err := sshclient.NewClient(cfg)
+	client, err := mux.Connect(cfg, muxOpts)
 	if err != nil {
 		log.Fatalf("创建 SSH 客户端失败: %v", err)
 	}
 	defer client.Close() // 程序结束时关闭连接
 
+	// 启动端口转发，转发在连接关闭时自动停止
+	// 只运行转发（-N）时，收到 Ctrl+C 或 SIGTERM 后退出
+	ctx := context.Background()
+	if *noShell {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	for _, spec := range localSpecs {
+		forward, err := client.LocalForward(ctx, spec.ListenAddr, spec.TargetAddr)
+		if err != nil {
+			log.Fatalf("启动本地端口转发失败: %v", err)
+		}
+		fmt.Printf("本地端口转发: %s -> %s\n", forward.ListenAddr, forward.TargetAddr)
+	}
+	for _, spec := range remoteSpecs {
+		forward, err := client.RemoteForward(ctx, spec.ListenAddr, spec.TargetAddr)
+		if err != nil {
+			log.Fatalf("启动远程端口转发失败: %v", err)
+		}
+		fmt.Printf("远程端口转发: %s -> %s\n", forward.ListenAddr, forward.TargetAddr)
+	}
+	for _, addr := range dynamicAddrs {
+		forward, err := client.DynamicForward(ctx, addr)
+		if err != nil {
+			log.Fatalf("启动动态端口转发失败: %v", err)
+		}
+		fmt.Printf("SOCKS 代理: %s\n", forward.ListenAddr)
+	}
+	if *noShell {
+		fmt.Println("端口转发已启动，按 Ctrl+C 退出")
+		<-ctx.Done()
+		return
+	}
+
 	// 根据用户选择的模式启动相应功能
 	switch *mode {
 	case "ssh":
 		// 启动 SSH 交互模式
 		// 用户可以在远程服务器上执行命令
-		fmt.Printf("正在连接到 %s@%s:%d...\n", *username, *host, *port)
-		if err := ui.StartSSHSession(client); err != nil {
+		fmt.Printf("正在连接到 %s@%s:%d...\n", cfg.Username, cfg.Host, cfg.Port)
+		if err := ui.StartSSHSessionWithOptions(client, ui.SessionOptions{
+			EscapeChar:  escape,
+			RecordFile:  *recordFile,
+			RecordInput: *recordInput,
+		}); err != nil {
+			// 远程 shell 的退出码作为程序的退出码，与 ssh 命令一致
+			var exitErr *sshclient.ExitError
+			if errors.As(err, &exitErr) {
+				client.Close()
+				os.Exit(exitErr.Result.ExitCode)
+			}
+			// 通过 ~. 断开连接时与 ssh 命令一样以 255 退出
+			if errors.Is(err, ui.ErrEscapeDisconnect) {
+				client.Close()
+				fmt.Fprintf(os.Stderr, "到 %s 的连接已断开\n", cfg.Host)
+				os.Exit(255)
+			}
 			log.Fatalf("SSH 会话启动失败: %v", err)
 		}
 	case "sftp":
 		// 启动 SFTP 文件传输模式
 		// 用户可以上传下载文件
-		fmt.Printf("正在启动 SFTP 会话到 %s@%s:%d...\n", *username, *host, *port)
+		fmt.Printf("正在启动 SFTP 会话到 %s@%s:%d...\n", cfg.Username, cfg.Host, cfg.Port)
 		if err := ui.StartSFTPSession(client); err != nil {
 			log.Fatalf("SFTP 会话启动失败: %v", err)
 		}
@@ -81,4 +304,83 @@ func main() {
 		fmt.Printf("错误: 不支持的模式 '%s'，请使用 'ssh' 或 'sftp'\n", *mode)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
+
+// runReconnecting 只运行端口转发，连接断开后自动重连并恢复所有转发
+// 收到 Ctrl+C 或 SIGTERM 后退出，放弃重连时以非零状态退出
+// 参数:
+//   cfg: SSH 连接配置
+//   localSpecs: 本地端口转发
+//   remoteSpecs: 远程端口转发
+//   dynamicAddrs: 动态端口转发的监听地址
+func runReconnecting(cfg *config.SSHConfig, localSpecs, remoteSpecs []config.ForwardSpec, dynamicAddrs []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := sshclient.NewReconnectingClient(ctx, cfg, sshclient.ReconnectOptions{
+		OnEvent: func(e sshclient.Event) {
+			log.Printf("连接状态: %v", e)
+		},
+	})
+	if err != nil {
+		log.Fatalf("创建 SSH 客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	for _, spec := range localSpecs {
+		forward, err := client.LocalForward(spec.ListenAddr, spec.TargetAddr)
+		if err != nil {
+			log.Fatalf("启动本地端口转发失败: %v", err)
+		}
+		fmt.Printf("本地端口转发: %s -> %s\n", forward.ListenAddr, forward.TargetAddr)
+	}
+	for _, spec := range remoteSpecs {
+		forward, err := client.RemoteForward(spec.ListenAddr, spec.TargetAddr)
+		if err != nil {
+			log.Fatalf("启动远程端口转发失败: %v", err)
+		}
+		fmt.Printf("远程端口转发: %s -> %s\n", forward.ListenAddr, forward.TargetAddr)
+	}
+	for _, addr := range dynamicAddrs {
+		forward, err := client.DynamicForward(addr)
+		if err != nil {
+			log.Fatalf("启动动态端口转发失败: %v", err)
+		}
+		fmt.Printf("SOCKS 代理: %s\n", forward.ListenAddr)
+	}
+
+	fmt.Println("端口转发已启动，断开后会自动重连，按 Ctrl+C 退出")
+	<-client.Done()
+	if err := client.Err(); err != nil {
+		log.Fatalf("放弃重连: %v", err)
+	}
+}
+
+// flagPassed 检查命令行中是否明确指定了某个参数
+// 参数:
+//   name: 参数名
+// 返回值:
+//   bool: 如果用户指定了该参数则返回 true
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// stringList 是可以重复指定的字符串参数，如多个 -L
+type stringList []string
+
+// String 实现 flag.Value 接口
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set 实现 flag.Value 接口，每次指定参数时追加一项
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
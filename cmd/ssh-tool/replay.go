@@ -0,0 +1,83 @@
+// Package main 的 replay 子命令
+// 在终端中回放 -record 录制的会话，可以加速播放并压缩空闲时间
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+
+	"gossh/internal/asciicast"
+)
+
+// runReplay 运行 replay 子命令
+// 参数:
+//   args: 子命令之后的命令行参数
+// 返回值:
+//   int: 进程退出码，回放完成时为 0，失败时为 1，参数错误时为 2，被中断时为 130
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 1, "播放速度的倍数，如 2 表示两倍速")
+	maxIdle := fs.Duration("idle", 0, "最长的空闲时间，录制时更长的停顿按这个时间播放，如 2s，0 表示不限制")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: ssh-tool replay [参数] 录像文件")
+		fmt.Fprintln(fs.Output(), "\n使用示例:")
+		fmt.Fprintln(fs.Output(), "  ssh-tool replay session.cast")
+		fmt.Fprintln(fs.Output(), "  ssh-tool replay -speed 4 -idle 1s session.cast")
+		fmt.Fprintln(fs.Output(), "\n参数:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "错误: 必须提供一个录像文件")
+		fs.Usage()
+		return 2
+	}
+	if *speed <= 0 {
+		fmt.Fprintln(os.Stderr, "错误: -speed 必须大于 0")
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 打开录像文件失败: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	reader, err := asciicast.NewReader(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+
+	// 回放不会调整终端大小，终端比录制时小时提醒用户
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil &&
+		(width < reader.Header.Width || height < reader.Header.Height) {
+		fmt.Fprintf(os.Stderr, "警告: 录像的终端大小为 %dx%d，当前终端只有 %dx%d，显示可能错乱\n",
+			reader.Header.Width, reader.Header.Height, width, height)
+	}
+
+	// 收到 Ctrl+C 或 SIGTERM 时停止回放
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := asciicast.PlayOptions{Speed: *speed, MaxIdle: *maxIdle}
+	if err := asciicast.Play(ctx, reader, os.Stdout, opts); err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "\n回放已中断")
+			return 130
+		}
+		fmt.Fprintf(os.Stderr, "\n错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
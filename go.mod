@@ -9,10 +9,8 @@ go 1.21
 require (
 	github.com/pkg/sftp v1.13.6 // SFTP 客户端功能
 	golang.org/x/crypto v0.17.0 // SSH 加密相关功能
+	golang.org/x/sys v0.15.0 // 系统调用（连接复用的后台进程）
 	golang.org/x/term v0.15.0 // 终端控制功能
 )
 
-require (
-	github.com/kr/fs v0.1.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
-)
+require github.com/kr/fs v0.1.0 // indirect
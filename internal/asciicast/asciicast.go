@@ -0,0 +1,255 @@
+// Package asciicast 提供 asciinema v2 格式（asciicast v2）的会话录像
+// 录像文件的第一行是 JSON 格式的头部，记录终端大小和开始时间，
+// 之后每行是一个事件 [时间, 类型, 数据]，时间是距离开始的秒数
+// 格式说明见 https://docs.asciinema.org/manual/asciicast/v2/
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Version 是支持的录像格式版本
+const Version = 2
+
+// EventType 表示录像事件的类型
+type EventType string
+
+const (
+	// EventOutput 是终端输出
+	EventOutput EventType = "o"
+	// EventInput 是用户输入
+	EventInput EventType = "i"
+	// EventResize 是终端大小变化，数据格式为 "宽x高"
+	EventResize EventType = "r"
+)
+
+// Header 是录像文件的头部
+type Header struct {
+	Version   int               `json:"version"`           // 格式版本，固定为 2
+	Width     int               `json:"width"`             // 录像开始时的终端宽度
+	Height    int               `json:"height"`            // 录像开始时的终端高度
+	Timestamp int64             `json:"timestamp"`         // 录像开始的 Unix 时间
+	Title     string            `json:"title,omitempty"`   // 标题，通常是 user@host
+	Command   string            `json:"command,omitempty"` // 录制的命令
+	Env       map[string]string `json:"env,omitempty"`     // 环境变量，通常包括 SHELL 和 TERM
+}
+
+// Event 是录像中的一个事件
+type Event struct {
+	Time float64   // 距离录像开始的秒数
+	Type EventType // 事件类型
+	Data string    // 事件数据
+}
+
+// MarshalJSON 把事件编码为 [时间, 类型, 数据] 数组，时间精确到微秒
+func (e Event) MarshalJSON() ([]byte, error) {
+	t := math.Round(e.Time*1e6) / 1e6
+	return json.Marshal([]interface{}{t, e.Type, e.Data})
+}
+
+// UnmarshalJSON 从 [时间, 类型, 数据] 数组解码事件
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("事件应该有 3 个字段，实际有 %d 个", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return fmt.Errorf("无效的事件时间: %w", err)
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return fmt.Errorf("无效的事件类型: %w", err)
+	}
+	if err := json.Unmarshal(fields[2], &e.Data); err != nil {
+		return fmt.Errorf("无效的事件数据: %w", err)
+	}
+	return nil
+}
+
+// ParseResize 解析终端大小变化事件的数据
+// 参数:
+//   data: "宽x高" 格式的数据，如 "120x40"
+// 返回值:
+//   int: 宽度
+//   int: 高度
+//   error: 如果格式无效则返回错误
+func ParseResize(data string) (int, int, error) {
+	var width, height int
+	if _, err := fmt.Sscanf(data, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("无效的终端大小: %q", data)
+	}
+	return width, height, nil
+}
+
+// Recorder 把终端会话写入录像文件，可以在多个 goroutine 中同时使用
+// 写入录像失败不会影响会话本身：错误被记录下来，由 Err 和 Close 返回
+type Recorder struct {
+	w     io.Writer
+	start time.Time
+
+	mu      sync.Mutex
+	writers []*streamWriter // 用于关闭时写出还没有写出的数据
+	err     error           // 第一次写入失败的错误
+	closed  bool
+}
+
+// NewRecorder 写入头部并创建录像
+// 头部的 Version 和 Timestamp 为空时自动填写
+// 参数:
+//   w: 录像的输出
+//   header: 录像头部
+// 返回值:
+//   *Recorder: 录像对象
+//   error: 如果写入头部失败则返回错误
+func NewRecorder(w io.Writer, header Header) (*Recorder, error) {
+	start := time.Now()
+	if header.Version == 0 {
+		header.Version = Version
+	}
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("编码录像头部失败: %w", err)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("写入录像头部失败: %w", err)
+	}
+	return &Recorder{w: w, start: start}, nil
+}
+
+// Create 创建录像文件并写入头部
+// 录像可能包含敏感信息，文件只有所有者可以读写
+// 参数:
+//   path: 录像文件路径，已经存在时覆盖
+//   header: 录像头部
+// 返回值:
+//   *Recorder: 录像对象，Close 时关闭文件
+//   error: 如果创建失败则返回错误
+func Create(path string, header Header) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建录像文件失败: %w", err)
+	}
+	rec, err := NewRecorder(file, header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Writer 返回一个把写入的数据记录为指定类型事件的 io.Writer
+// 写入总是成功，可以放在 io.MultiWriter 中而不影响其他输出；
+// 被拆开的 UTF-8 字符会留到下一次写入时一起记录
+// 参数:
+//   typ: 事件类型，通常是 EventOutput 或 EventInput
+func (r *Recorder) Writer(typ EventType) io.Writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sw := &streamWriter{rec: r, typ: typ}
+	r.writers = append(r.writers, sw)
+	return sw
+}
+
+// Resize 记录终端大小变化
+// 参数:
+//   width: 新的宽度
+//   height: 新的高度
+func (r *Recorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(EventResize, strconv.Itoa(width)+"x"+strconv.Itoa(height))
+}
+
+// Err 返回第一次写入录像失败的错误
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close 写出剩余的数据并结束录像，输出实现了 io.Closer 时同时关闭它
+// 返回值:
+//   error: 写入录像过程中第一次失败的错误
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	for _, sw := range r.writers {
+		if len(sw.pending) > 0 {
+			r.write(sw.typ, string(sw.pending))
+			sw.pending = nil
+		}
+	}
+	r.closed = true
+	if c, ok := r.w.(io.Closer); ok {
+		if err := c.Close(); err != nil && r.err == nil {
+			r.err = fmt.Errorf("关闭录像文件失败: %w", err)
+		}
+	}
+	return r.err
+}
+
+// write 写入一个事件，调用方必须持有 r.mu
+func (r *Recorder) write(typ EventType, data string) {
+	if r.closed || r.err != nil {
+		return
+	}
+	event := Event{Time: time.Since(r.start).Seconds(), Type: typ, Data: data}
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	if err != nil {
+		r.err = fmt.Errorf("写入会话录像失败: %w", err)
+	}
+}
+
+// streamWriter 把一个数据流记录为事件
+type streamWriter struct {
+	rec     *Recorder
+	typ     EventType
+	pending []byte // 上次写入末尾不完整的 UTF-8 字符
+}
+
+// Write 实现 io.Writer 接口，总是返回 len(p), nil
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.rec.mu.Lock()
+	defer w.rec.mu.Unlock()
+
+	data := append(w.pending, p...)
+	cut := len(data) - incompleteRuneLen(data)
+	w.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		w.rec.write(w.typ, string(data[:cut]))
+	}
+	return len(p), nil
+}
+
+// incompleteRuneLen 返回 data 末尾不完整的 UTF-8 字符的字节数
+// JSON 字符串只能包含有效的 UTF-8，被拆开的字符需要等后续字节到达后再记录
+func incompleteRuneLen(data []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if utf8.FullRune(data[len(data)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
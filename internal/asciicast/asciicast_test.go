@@ -0,0 +1,301 @@
+// asciicast_test 提供会话录像和回放的单元测试
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll 读取录像中的所有事件
+func readAll(t *testing.T, data []byte) (Header, []Event) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var events []Event
+	for {
+		event, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return r.Header, events
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+// TestRecorder 测试录像的头部和各类事件
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf, Header{Width: 80, Height: 24, Title: "root@web", Env: map[string]string{"TERM": "xterm"}})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	out := rec.Writer(EventOutput)
+	in := rec.Writer(EventInput)
+
+	out.Write([]byte("$ "))
+	in.Write([]byte("ls\r"))
+	rec.Resize(120, 40)
+	// “中”被拆成两次写入
+	out.Write([]byte("\xe4\xb8"))
+	out.Write([]byte("\xad\r\n"))
+	// 末尾不完整的字符在关闭时写出
+	out.Write([]byte("\xe4"))
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	header, events := readAll(t, buf.Bytes())
+	if header.Version != Version || header.Width != 80 || header.Height != 24 || header.Title != "root@web" || header.Env["TERM"] != "xterm" {
+		t.Errorf("Header = %+v", header)
+	}
+	if header.Timestamp == 0 {
+		t.Error("Header.Timestamp 没有设置")
+	}
+
+	want := []Event{
+		{Type: EventOutput, Data: "$ "},
+		{Type: EventInput, Data: "ls\r"},
+		{Type: EventResize, Data: "120x40"},
+		{Type: EventOutput, Data: "中\r\n"},
+		{Type: EventOutput, Data: "�"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("事件数量 = %d, want %d: %+v", len(events), len(want), events)
+	}
+	last := 0.0
+	for i, event := range events {
+		if event.Type != want[i].Type || event.Data != want[i].Data {
+			t.Errorf("事件 %d = (%s, %q), want (%s, %q)", i, event.Type, event.Data, want[i].Type, want[i].Data)
+		}
+		if event.Time < last {
+			t.Errorf("事件 %d 的时间 %v 早于上一个事件 %v", i, event.Time, last)
+		}
+		last = event.Time
+	}
+}
+
+// failingWriter 在写入头部之后的每次写入都失败
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("磁盘已满")
+	}
+	return len(p), nil
+}
+
+// TestRecorder_WriteError 测试写入录像失败不影响调用方，错误由 Close 返回
+func TestRecorder_WriteError(t *testing.T) {
+	rec, err := NewRecorder(&failingWriter{}, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	n, err := rec.Writer(EventOutput).Write([]byte("hello"))
+	if n != 5 || err != nil {
+		t.Errorf("Write() = (%d, %v), want (5, nil)", n, err)
+	}
+	if err := rec.Close(); err == nil || !contains(err.Error(), "磁盘已满") {
+		t.Errorf("Close() error = %v, want 包含 磁盘已满", err)
+	}
+}
+
+// TestCreate 测试录像文件的权限
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	rec, err := Create(path, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	rec.Writer(EventOutput).Write([]byte("hello"))
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("录像文件的权限 = %v, want 0600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if _, events := readAll(t, data); len(events) != 1 || events[0].Data != "hello" {
+		t.Errorf("事件 = %+v, want 一个 hello 输出", events)
+	}
+}
+
+// TestEvent_JSON 测试事件的编码和解码
+func TestEvent_JSON(t *testing.T) {
+	data, err := json.Marshal(Event{Time: 1.23456789, Type: EventOutput, Data: "a\"b"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `[1.234568,"o","a\"b"]` {
+		t.Errorf("Marshal() = %s", data)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "有效的事件", input: `[0.5, "i", "x"]`},
+		{name: "不是数组", input: `{"time": 1}`, wantErr: true},
+		{name: "字段数量不对", input: `[1, "o"]`, wantErr: true},
+		{name: "时间不是数字", input: `["1", "o", "x"]`, wantErr: true},
+		{name: "数据不是字符串", input: `[1, "o", 2]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event Event
+			err := json.Unmarshal([]byte(tt.input), &event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestParseResize 测试终端大小事件的解析
+func TestParseResize(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantWidth  int
+		wantHeight int
+		wantErr    bool
+	}{
+		{name: "有效的大小", data: "120x40", wantWidth: 120, wantHeight: 40},
+		{name: "缺少高度", data: "120x", wantErr: true},
+		{name: "大小为零", data: "0x40", wantErr: true},
+		{name: "不是数字", data: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, err := ParseResize(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResize(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+			if width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("ParseResize(%q) = %dx%d, want %dx%d", tt.data, width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+// TestNewReader_Errors 测试无效的录像文件
+func TestNewReader_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "空文件", input: "", wantErr: "录像文件为空"},
+		{name: "头部不是 JSON", input: "hello\n", wantErr: "无效的录像头部"},
+		{name: "版本不支持", input: `{"version": 1, "width": 80, "height": 24}` + "\n", wantErr: "不支持的录像版本"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.input))
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewReader() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	r, err := NewReader(strings.NewReader(`{"version": 2, "width": 80, "height": 24}` + "\n\n" + "[0.1, \"o\"\n"))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := r.Next(); err == nil || !contains(err.Error(), "第 3 行") {
+		t.Errorf("Next() error = %v, want 第 3 行无效", err)
+	}
+}
+
+// cast 是测试用的录像，最后一个事件之前空闲了 10 秒
+const cast = `{"version": 2, "width": 80, "height": 24, "timestamp": 1700000000}
+[0.0, "o", "a"]
+[0.1, "i", "x"]
+[0.2, "r", "100x30"]
+[0.2, "o", "b"]
+[10.2, "o", "c"]
+`
+
+// TestPlay 测试回放的输出和时间
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PlayOptions
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		// 空闲时间压缩到 0.1 秒：0.1 + 0.1 + 0.1 = 0.3 秒
+		{name: "压缩空闲时间", opts: PlayOptions{MaxIdle: 100 * time.Millisecond}, minTime: 250 * time.Millisecond, maxTime: 2 * time.Second},
+		// 十倍速：0.3 / 10 = 0.03 秒
+		{name: "加速播放", opts: PlayOptions{Speed: 10, MaxIdle: 100 * time.Millisecond}, minTime: 20 * time.Millisecond, maxTime: 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(cast))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			var out bytes.Buffer
+			start := time.Now()
+			if err := Play(context.Background(), r, &out, tt.opts); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+			elapsed := time.Since(start)
+			if out.String() != "abc" {
+				t.Errorf("Play() 输出 = %q, want \"abc\"", out.String())
+			}
+			if elapsed < tt.minTime || elapsed > tt.maxTime {
+				t.Errorf("Play() 用时 %v, want %v 到 %v", elapsed, tt.minTime, tt.maxTime)
+			}
+		})
+	}
+}
+
+// TestPlay_Cancel 测试取消回放
+func TestPlay_Cancel(t *testing.T) {
+	r, err := NewReader(strings.NewReader(cast))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	if err := Play(ctx, r, &out, PlayOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Play() error = %v, want context.DeadlineExceeded", err)
+	}
+	if out.String() != "a" {
+		t.Errorf("取消前的输出 = %q, want \"a\"", out.String())
+	}
+}
+
+// contains 检查字符串是否包含子字符串
+func contains(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
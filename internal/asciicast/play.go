@@ -0,0 +1,151 @@
+// Package asciicast 的回放模块
+// 按录像中的时间间隔把终端输出写到本地终端，可以加速播放并压缩过长的空闲时间
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Reader 按顺序读取录像文件中的事件
+type Reader struct {
+	Header Header // 录像头部
+
+	r    *bufio.Reader
+	line int // 已经读取的行数，用于错误信息
+}
+
+// NewReader 读取并检查录像头部
+// 参数:
+//   r: 录像文件的内容
+// 返回值:
+//   *Reader: 用于读取事件
+//   error: 如果头部无效或者版本不支持则返回错误
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+	line, err := reader.readLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("录像文件为空")
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(line, &reader.Header); err != nil {
+		return nil, fmt.Errorf("无效的录像头部: %w", err)
+	}
+	if reader.Header.Version != Version {
+		return nil, fmt.Errorf("不支持的录像版本 %d，只支持版本 %d", reader.Header.Version, Version)
+	}
+	return reader, nil
+}
+
+// Next 读取下一个事件
+// 返回值:
+//   Event: 读取到的事件
+//   error: 没有更多事件时返回 io.EOF，格式无效时返回错误
+func (r *Reader) Next() (Event, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Event{}, err
+	}
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return Event{}, fmt.Errorf("录像第 %d 行无效: %w", r.line, err)
+	}
+	return event, nil
+}
+
+// readLine 读取下一个非空行，没有更多内容时返回 io.EOF
+func (r *Reader) readLine() ([]byte, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			r.line++
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				return line, nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("读取录像失败: %w", err)
+		}
+	}
+}
+
+// PlayOptions 是回放的参数
+type PlayOptions struct {
+	// Speed 是播放速度的倍数，如 2 表示两倍速，小于等于 0 时按原速播放
+	Speed float64
+	// MaxIdle 是两个事件之间最长的等待时间（按原速计算），0 表示不限制
+	// 录制时长时间没有操作的部分按这个时间播放
+	MaxIdle time.Duration
+}
+
+// Play 按录像中的时间间隔把终端输出写到 w
+// 输入和终端大小变化事件不会回放
+// 参数:
+//   ctx: 上下文，取消时停止回放
+//   r: 录像读取器
+//   w: 回放的输出，通常是标准输出
+//   opts: 回放参数
+// 返回值:
+//   error: 如果读取录像或写入输出失败，或者回放被取消则返回错误
+func Play(ctx context.Context, r *Reader, w io.Writer, opts PlayOptions) error {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	// 按累计的播放时间计算每个事件的输出时刻，避免逐个等待造成的误差累积
+	start := time.Now()
+	var last, played float64 // 上一个事件在录像中的时间，以及压缩空闲后的累计时间
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		event, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		gap := event.Time - last
+		if gap < 0 {
+			gap = 0
+		}
+		if opts.MaxIdle > 0 && gap > opts.MaxIdle.Seconds() {
+			gap = opts.MaxIdle.Seconds()
+		}
+		last = event.Time
+		played += gap
+
+		if event.Type != EventOutput {
+			continue
+		}
+		at := start.Add(time.Duration(played / speed * float64(time.Second)))
+		if wait := time.Until(at); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, event.Data); err != nil {
+			return fmt.Errorf("输出录像失败: %w", err)
+		}
+	}
+}
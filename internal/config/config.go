@@ -7,8 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// DefaultConnectTimeout 是未配置连接超时时间时使用的默认值
+const DefaultConnectTimeout = 30 * time.Second
+
+// DefaultServerAliveCountMax 是未配置时允许连续没有应答的保活请求数，与 OpenSSH 一致
+const DefaultServerAliveCountMax = 3
+
 // SSHConfig 定义了 SSH 连接所需的所有配置信息
 // 这个结构体包含了连接远程服务器需要的所有参数
 type SSHConfig struct {
@@ -17,6 +25,50 @@ type SSHConfig struct {
 	Username string // 登录用户名
 	Password string // 登录密码（可选，也可以使用密钥）
 	KeyFile  string // 私钥文件路径（可选，用于密钥认证）
+	UseAgent bool   // 是否使用 SSH_AUTH_SOCK 指向的 ssh-agent 认证（可选）
+
+	KeyPassphrase string // 私钥密码（可选，为空时读取环境变量或在终端提示输入）
+
+	HostKeyPolicy  HostKeyPolicy // 主机密钥校验策略，为空时使用 accept-new
+	KnownHostsFile string        // known_hosts 文件路径，为空时使用 ~/.ssh/known_hosts
+
+	ConnectTimeout time.Duration // 建立连接（TCP 连接和 SSH 握手）的超时时间，为 0 时使用 DefaultConnectTimeout
+
+	ServerAliveInterval time.Duration // 发送保活请求的间隔，为 0 时不发送
+	ServerAliveCountMax int           // 连续多少个保活请求没有应答时断开连接，为 0 时使用 DefaultServerAliveCountMax
+
+	JumpHosts []*SSHConfig // 跳板机列表，按连接顺序排列，每一跳通过上一跳建立连接（可选）
+}
+
+// HostKeyPolicy 表示主机密钥的校验策略
+// 对应 OpenSSH 的 StrictHostKeyChecking 选项
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict 严格模式：主机必须已经记录在 known_hosts 中
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyAcceptNew 首次信任模式：未知主机自动写入 known_hosts，已知主机密钥不一致时拒绝连接
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	// HostKeyOff 关闭校验：不检查主机密钥（存在中间人攻击风险，仅用于测试）
+	HostKeyOff HostKeyPolicy = "off"
+)
+
+// ParseHostKeyPolicy 将字符串解析为主机密钥校验策略
+// 空字符串会被解析为默认的 accept-new 策略
+// 参数:
+//   s: 策略名称，可以是 "strict"、"accept-new" 或 "off"
+// 返回值:
+//   HostKeyPolicy: 解析后的策略
+//   error: 如果策略名称无效则返回错误
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch HostKeyPolicy(s) {
+	case "":
+		return HostKeyAcceptNew, nil
+	case HostKeyStrict, HostKeyAcceptNew, HostKeyOff:
+		return HostKeyPolicy(s), nil
+	default:
+		return "", fmt.Errorf("无效的主机密钥校验策略: %s（可选值: strict, accept-new, off）", s)
+	}
 }
 
 // Validate 验证配置信息是否完整和有效
@@ -39,9 +91,9 @@ func (c *SSHConfig) Validate() error {
 		return errors.New("端口必须在 1-65535 范围内")
 	}
 
-	// 检查认证方式：必须提供密码或密钥文件
-	if c.Password == "" && c.KeyFile == "" {
-		return errors.New("必须提供密码或私钥文件")
+	// 检查认证方式：必须提供密码、密钥文件或启用 ssh-agent
+	if c.Password == "" && c.KeyFile == "" && !c.UseAgent {
+		return errors.New("必须提供密码或私钥文件，或者启用 ssh-agent 认证")
 	}
 
 	// 如果指定了密钥文件，检查文件是否存在
@@ -51,6 +103,28 @@ func (c *SSHConfig) Validate() error {
 		}
 	}
 
+	// 检查连接超时时间
+	if c.ConnectTimeout < 0 {
+		return errors.New("连接超时时间不能为负数")
+	}
+
+	// 检查保活设置
+	if c.ServerAliveInterval < 0 || c.ServerAliveCountMax < 0 {
+		return errors.New("保活间隔和次数不能为负数")
+	}
+
+	// 检查主机密钥校验策略是否有效
+	if _, err := ParseHostKeyPolicy(string(c.HostKeyPolicy)); err != nil {
+		return err
+	}
+
+	// 检查每一个跳板机的配置
+	for i, hop := range c.JumpHosts {
+		if err := hop.Validate(); err != nil {
+			return fmt.Errorf("跳板机 %d (%s) 配置无效: %w", i+1, hop.Host, err)
+		}
+	}
+
 	return nil
 }
 
@@ -62,6 +136,54 @@ func (c *SSHConfig) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// GetConnectTimeout 返回实际生效的连接超时时间
+// 未配置时返回默认的 30 秒
+// 返回值:
+//   time.Duration: 连接超时时间
+func (c *SSHConfig) GetConnectTimeout() time.Duration {
+	if c.ConnectTimeout == 0 {
+		return DefaultConnectTimeout
+	}
+	return c.ConnectTimeout
+}
+
+// GetServerAliveCountMax 返回实际生效的保活请求最大未应答次数
+// 未配置时返回默认的 3 次
+// 返回值:
+//   int: 最大未应答次数
+func (c *SSHConfig) GetServerAliveCountMax() int {
+	if c.ServerAliveCountMax == 0 {
+		return DefaultServerAliveCountMax
+	}
+	return c.ServerAliveCountMax
+}
+
+// GetHostKeyPolicy 返回实际生效的主机密钥校验策略
+// 未配置时返回默认的 accept-new 策略
+// 返回值:
+//   HostKeyPolicy: 主机密钥校验策略
+func (c *SSHConfig) GetHostKeyPolicy() HostKeyPolicy {
+	if c.HostKeyPolicy == "" {
+		return HostKeyAcceptNew
+	}
+	return c.HostKeyPolicy
+}
+
+// GetKnownHostsFile 返回 known_hosts 文件路径
+// 未配置时返回用户主目录下的 ~/.ssh/known_hosts
+// 返回值:
+//   string: known_hosts 文件路径，无法确定主目录时返回空字符串
+func (c *SSHConfig) GetKnownHostsFile() string {
+	if c.KnownHostsFile != "" {
+		return c.KnownHostsFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
 // HasKeyAuth 检查是否使用密钥认证
 // 返回值:
 //   bool: 如果配置了密钥文件则返回 true，否则返回 false
@@ -74,4 +196,11 @@ func (c *SSHConfig) HasKeyAuth() bool {
 //   bool: 如果配置了密码则返回 true，否则返回 false
 func (c *SSHConfig) HasPasswordAuth() bool {
 	return c.Password != ""
-}
\ No newline at end of file
+}
+
+// HasAgentAuth 检查是否使用 ssh-agent 认证
+// 返回值:
+//   bool: 如果启用了 ssh-agent 则返回 true，否则返回 false
+func (c *SSHConfig) HasAgentAuth() bool {
+	return c.UseAgent
+}
@@ -6,6 +6,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 // TestSSHConfig_Validate 测试配置验证功能
@@ -264,6 +265,106 @@ func TestSSHConfig_ValidateWithKeyFile(t *testing.T) {
 	}
 }
 
+// TestParseHostKeyPolicy 测试主机密钥校验策略解析
+func TestParseHostKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    HostKeyPolicy
+		wantErr bool
+	}{
+		{name: "空字符串使用默认策略", input: "", want: HostKeyAcceptNew},
+		{name: "严格模式", input: "strict", want: HostKeyStrict},
+		{name: "首次信任模式", input: "accept-new", want: HostKeyAcceptNew},
+		{name: "关闭校验", input: "off", want: HostKeyOff},
+		{name: "无效策略", input: "yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHostKeyPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseHostKeyPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseHostKeyPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 无效策略应该在配置验证时被拒绝
+	cfg := &SSHConfig{
+		Host:          "192.168.1.100",
+		Port:          22,
+		Username:      "root",
+		Password:      "123456",
+		HostKeyPolicy: "yes",
+	}
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "无效的主机密钥校验策略") {
+		t.Errorf("SSHConfig.Validate() error = %v, want invalid policy error", err)
+	}
+}
+
+// TestSSHConfig_GetKnownHostsFile 测试 known_hosts 路径的默认值
+func TestSSHConfig_GetKnownHostsFile(t *testing.T) {
+	cfg := &SSHConfig{KnownHostsFile: "/tmp/known_hosts"}
+	if got := cfg.GetKnownHostsFile(); got != "/tmp/known_hosts" {
+		t.Errorf("SSHConfig.GetKnownHostsFile() = %v, want /tmp/known_hosts", got)
+	}
+
+	t.Setenv("HOME", "/home/tester")
+	cfg = &SSHConfig{}
+	if got := cfg.GetKnownHostsFile(); got != "/home/tester/.ssh/known_hosts" {
+		t.Errorf("SSHConfig.GetKnownHostsFile() = %v, want /home/tester/.ssh/known_hosts", got)
+	}
+}
+
+// TestSSHConfig_GetConnectTimeout 测试连接超时时间的默认值和校验
+func TestSSHConfig_GetConnectTimeout(t *testing.T) {
+	cfg := &SSHConfig{}
+	if got := cfg.GetConnectTimeout(); got != DefaultConnectTimeout {
+		t.Errorf("SSHConfig.GetConnectTimeout() = %v, want %v", got, DefaultConnectTimeout)
+	}
+
+	cfg = &SSHConfig{ConnectTimeout: 5 * time.Second}
+	if got := cfg.GetConnectTimeout(); got != 5*time.Second {
+		t.Errorf("SSHConfig.GetConnectTimeout() = %v, want 5s", got)
+	}
+
+	cfg = &SSHConfig{
+		Host:           "192.168.1.100",
+		Port:           22,
+		Username:       "root",
+		Password:       "123456",
+		ConnectTimeout: -time.Second,
+	}
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "连接超时时间不能为负数") {
+		t.Errorf("SSHConfig.Validate() error = %v, want negative timeout error", err)
+	}
+}
+
+// TestSSHConfig_GetServerAliveCountMax 测试保活次数的默认值和校验
+func TestSSHConfig_GetServerAliveCountMax(t *testing.T) {
+	if got := (&SSHConfig{}).GetServerAliveCountMax(); got != DefaultServerAliveCountMax {
+		t.Errorf("SSHConfig.GetServerAliveCountMax() = %d, want %d", got, DefaultServerAliveCountMax)
+	}
+	if got := (&SSHConfig{ServerAliveCountMax: 5}).GetServerAliveCountMax(); got != 5 {
+		t.Errorf("SSHConfig.GetServerAliveCountMax() = %d, want 5", got)
+	}
+
+	cfg := &SSHConfig{
+		Host:                "192.168.1.100",
+		Port:                22,
+		Username:            "root",
+		Password:            "123456",
+		ServerAliveInterval: -time.Second,
+	}
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "保活间隔和次数不能为负数") {
+		t.Errorf("SSHConfig.Validate() error = %v, want negative keepalive error", err)
+	}
+}
+
 // contains 检查字符串是否包含子字符串
 // 这是一个辅助函数，用于错误信息的部分匹配
 func contains(s, substr string) bool {
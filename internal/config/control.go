@@ -0,0 +1,57 @@
+// Package config 的连接复用配置模块
+// 处理 ControlPath 的占位符展开和 ControlPersist 的解析
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultControlPath 是启用连接复用但没有指定控制套接字时使用的路径
+// 每个用户、主机和端口的组合对应一个主连接
+const DefaultControlPath = "~/.ssh/gossh-%r@%h:%p"
+
+// ControlPersistForever 表示主连接在没有客户端时也一直保持，直到收到 exit 命令或连接断开
+const ControlPersistForever time.Duration = -1
+
+// ParseControlPersist 解析 ControlPersist 的值
+// 参数:
+//   value: "yes" 表示一直保持，"no" 或 "0" 表示最后一个客户端断开后立即退出，
+//          纯数字表示秒数，也可以写成 "10m"、"1h30m" 这样的时间
+// 返回值:
+//   time.Duration: 保持的时间，一直保持时为 ControlPersistForever
+//   error: 如果格式无效则返回错误
+func ParseControlPersist(value string) (time.Duration, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return ControlPersistForever, nil
+	case "no":
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("无效的 ControlPersist: %s", value)
+}
+
+// ExpandControlPath 展开控制套接字路径中的 "~" 和占位符
+// 支持 %h（主机地址）、%p（端口）、%r（远程用户）、%u（本地用户）、%d（主目录）和 %%
+// 参数:
+//   path: 控制套接字路径，如 "~/.ssh/gossh-%r@%h:%p"
+//   cfg: 已经补全的连接配置，占位符的值取自这里
+// 返回值:
+//   string: 展开后的路径
+func ExpandControlPath(path string, cfg *SSHConfig) string {
+	h := &HostConfig{
+		Alias:    cfg.Host,
+		HostName: cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.Username,
+	}
+	return expandHome(h.expandTokens(path))
+}
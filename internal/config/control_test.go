@@ -0,0 +1,60 @@
+// control_test 提供连接复用配置的单元测试
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseControlPersist 测试 ControlPersist 的各种写法
+func TestParseControlPersist(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "一直保持", value: "yes", want: ControlPersistForever},
+		{name: "立即退出", value: "no", want: 0},
+		{name: "大小写不敏感", value: "Yes", want: ControlPersistForever},
+		{name: "秒数", value: "600", want: 10 * time.Minute},
+		{name: "时间", value: "1h30m", want: 90 * time.Minute},
+		{name: "负数", value: "-5", wantErr: true},
+		{name: "无效的值", value: "later", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseControlPersist(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseControlPersist(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseControlPersist(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExpandControlPath 测试控制套接字路径的展开
+func TestExpandControlPath(t *testing.T) {
+	t.Setenv("HOME", "/home/tester")
+	cfg := &SSHConfig{Host: "10.0.0.10", Port: 2222, Username: "deploy"}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "默认路径", path: DefaultControlPath, want: "/home/tester/.ssh/gossh-deploy@10.0.0.10:2222"},
+		{name: "绝对路径", path: "/tmp/cm-%h-%p", want: "/tmp/cm-10.0.0.10-2222"},
+		{name: "百分号", path: "/tmp/100%%-%r", want: "/tmp/100%-deploy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandControlPath(tt.path, cfg); got != tt.want {
+				t.Errorf("ExpandControlPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package config 的端口转发配置模块
+// 解析 OpenSSH 格式的 -L / -R / -D 端口转发参数
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ForwardSpec 表示一条端口转发规则
+// 对于本地转发，ListenAddr 是本地监听地址，TargetAddr 是从服务器端连接的地址
+// 对于远程转发，ListenAddr 是服务器端监听地址，TargetAddr 是从本地连接的地址
+type ForwardSpec struct {
+	ListenAddr string // 监听地址，格式为 "host:port"
+	TargetAddr string // 转发目标地址，格式为 "host:port"
+}
+
+// String 返回 OpenSSH 格式的转发规则
+func (f ForwardSpec) String() string {
+	return f.ListenAddr + ":" + f.TargetAddr
+}
+
+// ParseForwardSpec 解析 OpenSSH 格式的端口转发参数
+// 格式为 [bind_address:]port:host:hostport，IPv6 地址需要用方括号括起来
+// 省略 bind_address 时只监听 localhost
+// 参数:
+//   spec: 转发参数，如 "8080:db.internal:5432" 或 "0.0.0.0:8080:db.internal:5432"
+// 返回值:
+//   ForwardSpec: 解析后的转发规则
+//   error: 如果格式无效则返回错误
+func ParseForwardSpec(spec string) (ForwardSpec, error) {
+	fields, err := splitForwardFields(spec)
+	if err != nil {
+		return ForwardSpec{}, err
+	}
+
+	var bind, listenPort, host, hostPort string
+	switch len(fields) {
+	case 3:
+		bind, listenPort, host, hostPort = "localhost", fields[0], fields[1], fields[2]
+	case 4:
+		bind, listenPort, host, hostPort = fields[0], fields[1], fields[2], fields[3]
+		if bind == "" || bind == "*" {
+			bind = "0.0.0.0" // 与 OpenSSH 一致，空地址和 "*" 表示所有地址
+		}
+	default:
+		return ForwardSpec{}, fmt.Errorf("无效的端口转发参数 %q，格式应为 [bind_address:]port:host:hostport", spec)
+	}
+
+	if err := checkForwardPort(listenPort, true); err != nil {
+		return ForwardSpec{}, fmt.Errorf("无效的端口转发参数 %q: %w", spec, err)
+	}
+	if err := checkForwardPort(hostPort, false); err != nil {
+		return ForwardSpec{}, fmt.Errorf("无效的端口转发参数 %q: %w", spec, err)
+	}
+	if host == "" {
+		return ForwardSpec{}, fmt.Errorf("无效的端口转发参数 %q: 目标主机不能为空", spec)
+	}
+
+	return ForwardSpec{
+		ListenAddr: net.JoinHostPort(bind, listenPort),
+		TargetAddr: net.JoinHostPort(host, hostPort),
+	}, nil
+}
+
+// ParseDynamicForwardSpec 解析 OpenSSH 格式的动态端口转发参数
+// 格式为 [bind_address:]port，省略 bind_address 时只监听 localhost
+// 参数:
+//   spec: 转发参数，如 "1080" 或 "0.0.0.0:1080"
+// 返回值:
+//   string: SOCKS 代理的监听地址，格式为 "host:port"
+//   error: 如果格式无效则返回错误
+func ParseDynamicForwardSpec(spec string) (string, error) {
+	fields, err := splitForwardFields(spec)
+	if err != nil {
+		return "", err
+	}
+
+	var bind, port string
+	switch len(fields) {
+	case 1:
+		bind, port = "localhost", fields[0]
+	case 2:
+		bind, port = fields[0], fields[1]
+		if bind == "" || bind == "*" {
+			bind = "0.0.0.0"
+		}
+	default:
+		return "", fmt.Errorf("无效的动态转发参数 %q，格式应为 [bind_address:]port", spec)
+	}
+
+	if err := checkForwardPort(port, true); err != nil {
+		return "", fmt.Errorf("无效的动态转发参数 %q: %w", spec, err)
+	}
+	return net.JoinHostPort(bind, port), nil
+}
+
+// splitForwardFields 按冒号拆分转发参数，方括号内的冒号不拆分
+func splitForwardFields(spec string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inBracket := false
+	for _, r := range spec {
+		switch {
+		case r == '[' && !inBracket:
+			inBracket = true
+		case r == ']' && inBracket:
+			inBracket = false
+		case r == ':' && !inBracket:
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inBracket {
+		return nil, fmt.Errorf("无效的端口转发参数 %q: 方括号不匹配", spec)
+	}
+	return append(fields, current.String()), nil
+}
+
+// checkForwardPort 检查端口号是否有效
+// 监听端口允许为 0，表示由系统（或服务器）分配
+func checkForwardPort(port string, allowZero bool) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 || (n == 0 && !allowZero) {
+		return fmt.Errorf("无效的端口: %q", port)
+	}
+	return nil
+}
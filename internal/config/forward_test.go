@@ -0,0 +1,87 @@
+// forward_test 提供端口转发参数解析的单元测试
+package config
+
+import "testing"
+
+// TestParseForwardSpec 测试 OpenSSH 格式的端口转发参数
+func TestParseForwardSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    ForwardSpec
+		wantErr bool
+	}{
+		{
+			name: "省略监听地址",
+			spec: "8080:db.internal:5432",
+			want: ForwardSpec{ListenAddr: "localhost:8080", TargetAddr: "db.internal:5432"},
+		},
+		{
+			name: "指定监听地址",
+			spec: "127.0.0.1:8080:db.internal:5432",
+			want: ForwardSpec{ListenAddr: "127.0.0.1:8080", TargetAddr: "db.internal:5432"},
+		},
+		{
+			name: "星号表示所有地址",
+			spec: "*:8080:localhost:80",
+			want: ForwardSpec{ListenAddr: "0.0.0.0:8080", TargetAddr: "localhost:80"},
+		},
+		{
+			name: "IPv6 地址",
+			spec: "[::1]:8080:[fe80::1]:22",
+			want: ForwardSpec{ListenAddr: "[::1]:8080", TargetAddr: "[fe80::1]:22"},
+		},
+		{
+			name: "监听端口为 0",
+			spec: "0:db:5432",
+			want: ForwardSpec{ListenAddr: "localhost:0", TargetAddr: "db:5432"},
+		},
+		{name: "字段太少", spec: "8080:db", wantErr: true},
+		{name: "端口无效", spec: "abc:db:5432", wantErr: true},
+		{name: "目标端口为 0", spec: "8080:db:0", wantErr: true},
+		{name: "目标主机为空", spec: "8080::5432", wantErr: true},
+		{name: "方括号不匹配", spec: "[::1:8080:db:22", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseForwardSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseForwardSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseForwardSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseDynamicForwardSpec 测试 OpenSSH 格式的动态端口转发参数
+func TestParseDynamicForwardSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{name: "只有端口", spec: "1080", want: "localhost:1080"},
+		{name: "指定监听地址", spec: "127.0.0.1:1080", want: "127.0.0.1:1080"},
+		{name: "星号表示所有地址", spec: "*:1080", want: "0.0.0.0:1080"},
+		{name: "IPv6 地址", spec: "[::1]:1080", want: "[::1]:1080"},
+		{name: "端口无效", spec: "socks", wantErr: true},
+		{name: "端口超出范围", spec: "70000", wantErr: true},
+		{name: "字段太多", spec: "1080:host:80", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDynamicForwardSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDynamicForwardSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDynamicForwardSpec() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package config 的主机列表模块
+// 解析批量执行时使用的主机列表，支持 pdsh 风格的范围写法
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxExpandedHosts 是一个主机列表最多展开的主机数，防止写错范围时占用大量内存
+const maxExpandedHosts = 10000
+
+// ExpandHosts 展开主机列表
+// 多个主机用逗号分隔，方括号内可以写数字范围和列表，如 "web[01-03,07].example.com"
+// 范围起点有前导零时，展开结果保持相同的宽度
+// 包含冒号的方括号是 IPv6 地址，如 "[::1]:22" 或 "[fe80::1]"，原样保留不展开
+// 参数:
+//   spec: 主机列表，如 "web[1-3],db1"
+// 返回值:
+//   []string: 展开后的主机列表，保持顺序并去除重复
+//   error: 如果格式无效则返回错误
+func ExpandHosts(spec string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, pattern := range splitOutsideBrackets(spec) {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		expanded, err := expandHostPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的主机列表 %q: %w", pattern, err)
+		}
+		for _, host := range expanded {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) > maxExpandedHosts {
+			return nil, fmt.Errorf("主机列表展开后超过 %d 台", maxExpandedHosts)
+		}
+	}
+	return hosts, nil
+}
+
+// ReadHostsFile 从文件中读取主机列表
+// 每行一个主机列表（可以使用 ExpandHosts 支持的写法），空行和 # 开头的注释会被忽略
+// 参数:
+//   path: 文件路径
+// 返回值:
+//   []string: 展开后的主机列表，保持顺序并去除重复
+//   error: 如果读取失败或格式无效则返回错误
+func ReadHostsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开主机列表文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取主机列表文件失败: %w", err)
+	}
+	return ExpandHosts(strings.Join(specs, ","))
+}
+
+// splitOutsideBrackets 按逗号拆分，方括号内的逗号不拆分
+func splitOutsideBrackets(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// expandHostPattern 展开单个主机模式中的第一个方括号，剩余部分递归展开
+// IPv6 地址的方括号原样保留，继续展开后面的部分
+func expandHostPattern(pattern string) ([]string, error) {
+	open := strings.IndexByte(pattern, '[')
+	if open < 0 {
+		if strings.ContainsRune(pattern, ']') {
+			return nil, fmt.Errorf("方括号不匹配")
+		}
+		return []string{pattern}, nil
+	}
+	end := strings.IndexByte(pattern[open:], ']')
+	if end < 0 {
+		return nil, fmt.Errorf("方括号不匹配")
+	}
+	end += open
+
+	// 范围写法中不会出现冒号，有冒号时是 IPv6 地址
+	values := []string{pattern[open : end+1]}
+	if inner := pattern[open+1 : end]; !strings.ContainsRune(inner, ':') {
+		var err error
+		values, err = expandRanges(inner)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rest, err := expandHostPattern(pattern[end+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := pattern[:open]
+	hosts := make([]string, 0, len(values)*len(rest))
+	for _, v := range values {
+		for _, r := range rest {
+			hosts = append(hosts, prefix+v+r)
+			if len(hosts) > maxExpandedHosts {
+				return nil, fmt.Errorf("展开后超过 %d 台", maxExpandedHosts)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// expandRanges 展开方括号内的内容，如 "01-03,07" 展开为 01、02、03、07
+func expandRanges(s string) ([]string, error) {
+	var values []string
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			if _, err := strconv.Atoi(part); err != nil {
+				return nil, fmt.Errorf("无效的范围 %q", part)
+			}
+			values = append(values, part)
+			continue
+		}
+
+		start, err1 := strconv.Atoi(from)
+		stop, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || start < 0 || start > stop {
+			return nil, fmt.Errorf("无效的范围 %q", part)
+		}
+		if stop-start >= maxExpandedHosts {
+			return nil, fmt.Errorf("范围 %q 太大", part)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from) // 保持前导零
+		}
+		for i := start; i <= stop; i++ {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,79 @@
+// hostlist_test 提供主机列表展开的单元测试
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestExpandHosts 测试逗号列表和方括号范围的展开
+func TestExpandHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "单个主机", spec: "web1", want: []string{"web1"}},
+		{name: "逗号分隔", spec: "web1, db1,", want: []string{"web1", "db1"}},
+		{name: "数字范围", spec: "web[1-3]", want: []string{"web1", "web2", "web3"}},
+		{
+			name: "保持前导零",
+			spec: "web[08-10].example.com",
+			want: []string{"web08.example.com", "web09.example.com", "web10.example.com"},
+		},
+		{name: "范围和列表混合", spec: "n[1-2,5]", want: []string{"n1", "n2", "n5"}},
+		{
+			name: "多个方括号",
+			spec: "r[1-2]n[1-2]",
+			want: []string{"r1n1", "r1n2", "r2n1", "r2n2"},
+		},
+		{name: "去除重复", spec: "web[1-2],web2,web1", want: []string{"web1", "web2"}},
+		{name: "带用户名和端口", spec: "root@web[1-2]:2222", want: []string{"root@web1:2222", "root@web2:2222"}},
+		{name: "IPv6 地址", spec: "[fe80::1],[::1]", want: []string{"[fe80::1]", "[::1]"}},
+		{name: "IPv6 地址和端口", spec: "root@[::1]:22,[2001:db8::1]:2222", want: []string{"root@[::1]:22", "[2001:db8::1]:2222"}},
+		{name: "IPv6 地址和范围混合", spec: "web[1-2],[::1]:22", want: []string{"web1", "web2", "[::1]:22"}},
+		{name: "IPv6 地址不完整", spec: "[::1:22", wantErr: true},
+		{name: "方括号不匹配", spec: "web[1-3", wantErr: true},
+		{name: "多余的右括号", spec: "web1-3]", wantErr: true},
+		{name: "范围颠倒", spec: "web[3-1]", wantErr: true},
+		{name: "范围不是数字", spec: "web[a-c]", wantErr: true},
+		{name: "范围太大", spec: "web[1-99999]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandHosts(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadHostsFile 测试从文件读取主机列表
+func TestReadHostsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := "# 生产环境\nweb[1-2]\n\n  db1  \n# db2\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入主机列表文件失败: %v", err)
+	}
+
+	got, err := ReadHostsFile(path)
+	if err != nil {
+		t.Fatalf("ReadHostsFile() error = %v", err)
+	}
+	want := []string{"web1", "web2", "db1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadHostsFile() = %v, want %v", got, want)
+	}
+
+	if _, err := ReadHostsFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ReadHostsFile() 读取不存在的文件应该返回错误")
+	}
+}
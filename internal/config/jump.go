@@ -0,0 +1,128 @@
+// Package config 的跳板机配置模块
+// 解析 "-J user@bastion1,user@bastion2:2222" 格式的跳板机列表
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseJumpHosts 解析逗号分隔的跳板机列表
+// 每一项的格式为 [user@]host[:port]，IPv6 地址需要写成 [addr]:port
+// 返回的配置只包含列表中明确写出的字段，其余字段由 InheritFrom 补全
+// 参数:
+//   spec: 跳板机列表，如 "jump@bastion1,bastion2:2222"
+// 返回值:
+//   []*SSHConfig: 按连接顺序排列的跳板机配置
+//   error: 如果格式无效则返回错误
+func ParseJumpHosts(spec string) ([]*SSHConfig, error) {
+	var hops []*SSHConfig
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("无效的跳板机列表: %q", spec)
+		}
+
+		hop, err := ParseHostEntry(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的跳板机: %w", err)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// ParseHostEntry 解析 [user@]host[:port] 格式的单个主机
+// 参数:
+//   item: 主机，如 "jump@bastion:2222" 或 "[::1]:22"
+// 返回值:
+//   *SSHConfig: 只包含用户名、主机和端口的配置，没有写出的字段为零值
+//   error: 如果格式无效则返回错误
+func ParseHostEntry(item string) (*SSHConfig, error) {
+	entry := &SSHConfig{}
+	if at := strings.LastIndex(item, "@"); at >= 0 {
+		entry.Username = item[:at]
+		item = item[at+1:]
+	}
+
+	// 只有带方括号或者恰好一个冒号时才认为包含端口
+	host := item
+	if strings.HasPrefix(item, "[") || strings.Count(item, ":") == 1 {
+		h, p, err := net.SplitHostPort(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的主机地址 %q: %w", item, err)
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("无效的端口: %q", item)
+		}
+		host, entry.Port = h, port
+	}
+	if host == "" {
+		return nil, fmt.Errorf("主机地址不能为空: %q", item)
+	}
+	entry.Host = host
+	return entry, nil
+}
+
+// ResolveJumpHosts 解析跳板机列表，并补全每一跳的配置
+// 每一跳先通过 ssh 配置文件解析别名，再从目标主机继承认证方式
+// 参数:
+//   spec: 跳板机列表，格式同 ParseJumpHosts
+//   sshConfigFile: OpenSSH 客户端配置文件路径，为空时使用 ~/.ssh/config
+//   target: 最终要连接的目标主机配置
+// 返回值:
+//   []*SSHConfig: 补全后的跳板机配置
+//   error: 如果解析失败则返回错误
+func ResolveJumpHosts(spec, sshConfigFile string, target *SSHConfig) ([]*SSHConfig, error) {
+	hops, err := ParseJumpHosts(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hop := range hops {
+		hostConfig, err := LoadHostConfig(sshConfigFile, hop.Host)
+		if err != nil {
+			return nil, err
+		}
+		hostConfig.ApplyTo(hop)
+		hop.InheritFrom(target)
+	}
+	return hops, nil
+}
+
+// InheritFrom 从目标主机配置继承尚未设置的字段
+// 用户名、私钥、ssh-agent、主机密钥校验、超时和保活设置会被继承，端口默认为 22
+// 密码不会被继承，避免把目标主机的密码发送给跳板机
+// 参数:
+//   base: 目标主机的配置
+func (c *SSHConfig) InheritFrom(base *SSHConfig) {
+	if c.Username == "" {
+		c.Username = base.Username
+	}
+	if c.Port == 0 {
+		c.Port = 22
+	}
+	if c.KeyFile == "" {
+		c.KeyFile = base.KeyFile
+		c.KeyPassphrase = base.KeyPassphrase
+	}
+	if base.UseAgent {
+		c.UseAgent = true
+	}
+	if c.HostKeyPolicy == "" {
+		c.HostKeyPolicy = base.HostKeyPolicy
+	}
+	if c.KnownHostsFile == "" {
+		c.KnownHostsFile = base.KnownHostsFile
+	}
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = base.ConnectTimeout
+	}
+	if c.ServerAliveInterval == 0 {
+		c.ServerAliveInterval = base.ServerAliveInterval
+		c.ServerAliveCountMax = base.ServerAliveCountMax
+	}
+}
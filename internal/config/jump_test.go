@@ -0,0 +1,126 @@
+// jump_test 提供跳板机列表解析的单元测试
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParseJumpHosts 测试跳板机列表解析
+func TestParseJumpHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []*SSHConfig
+		wantErr bool
+	}{
+		{
+			name: "单个跳板机",
+			spec: "bastion",
+			want: []*SSHConfig{{Host: "bastion"}},
+		},
+		{
+			name: "用户名和端口",
+			spec: "jump@bastion1,admin@bastion2:2222",
+			want: []*SSHConfig{
+				{Host: "bastion1", Username: "jump"},
+				{Host: "bastion2", Port: 2222, Username: "admin"},
+			},
+		},
+		{
+			name: "IPv6 地址",
+			spec: "root@[::1]:2200,fe80::1",
+			want: []*SSHConfig{
+				{Host: "::1", Port: 2200, Username: "root"},
+				{Host: "fe80::1"},
+			},
+		},
+		{name: "空项", spec: "bastion1,,bastion2", wantErr: true},
+		{name: "无效端口", spec: "bastion:abc", wantErr: true},
+		{name: "缺少主机", spec: "user@", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJumpHosts(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJumpHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseJumpHosts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResolveJumpHosts 测试跳板机别名解析和配置继承
+func TestResolveJumpHosts(t *testing.T) {
+	target := &SSHConfig{
+		Host:           "10.0.0.10",
+		Port:           22,
+		Username:       "deploy",
+		Password:       "secret",
+		KeyFile:        "/path/to/key",
+		UseAgent:       true,
+		HostKeyPolicy:  HostKeyStrict,
+		KnownHostsFile: "/path/to/known_hosts",
+	}
+
+	hops, err := ResolveJumpHosts("bastion,other@10.0.0.1", filepath.Join("testdata", "ssh_config"), target)
+	if err != nil {
+		t.Fatalf("ResolveJumpHosts() error = %v", err)
+	}
+
+	want := []*SSHConfig{
+		{
+			Host:           "bastion.example.com", // 来自 Include 的 bastion.conf
+			Port:           22022,
+			Username:       "jump",
+			KeyFile:        "/path/to/key",
+			UseAgent:       true,
+			HostKeyPolicy:  HostKeyStrict,
+			KnownHostsFile: "/path/to/known_hosts",
+
+			ServerAliveInterval: 30 * time.Second, // 来自 ssh_config 的全局选项
+		},
+		{
+			Host:           "10.0.0.1",
+			Port:           22,
+			Username:       "other",
+			KeyFile:        "/path/to/key",
+			UseAgent:       true,
+			HostKeyPolicy:  HostKeyStrict,
+			KnownHostsFile: "/path/to/known_hosts",
+
+			ServerAliveInterval: 30 * time.Second, // 来自 ssh_config 的全局选项
+		},
+	}
+	if !reflect.DeepEqual(hops, want) {
+		t.Errorf("ResolveJumpHosts() = %+v, want %+v", hops, want)
+	}
+
+	// 密码不应该被继承
+	for _, hop := range hops {
+		if hop.Password != "" {
+			t.Errorf("跳板机 %s 继承了目标主机的密码", hop.Host)
+		}
+	}
+}
+
+// TestSSHConfig_ValidateJumpHosts 测试跳板机配置的验证
+func TestSSHConfig_ValidateJumpHosts(t *testing.T) {
+	cfg := &SSHConfig{
+		Host:      "192.168.1.100",
+		Port:      22,
+		Username:  "root",
+		Password:  "123456",
+		JumpHosts: []*SSHConfig{{Host: "bastion", Port: 22, Username: "root"}},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "跳板机 1 (bastion) 配置无效") {
+		t.Errorf("SSHConfig.Validate() error = %v, want jump host error", err)
+	}
+}
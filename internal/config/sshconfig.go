@@ -0,0 +1,563 @@
+// Package config 的 OpenSSH 客户端配置解析模块
+// 读取 ~/.ssh/config 格式的文件，把主机别名解析成具体的连接参数
+// 支持 Host / Match host 块、通配符、Include，以及"第一个匹配的值生效"的语义
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxIncludeDepth 是 Include 嵌套的最大层数，防止循环包含
+const maxIncludeDepth = 16
+
+// HostConfig 表示从 ssh_config 中为某个主机别名解析出的选项
+// 字段为零值表示配置文件中没有设置该选项
+type HostConfig struct {
+	Alias               string        // 用户输入的主机别名
+	HostName            string        // 实际连接的主机地址
+	User                string        // 登录用户名
+	Port                int           // 服务器端口
+	IdentityFiles       []string      // 私钥文件列表，按出现顺序排列
+	ProxyJump           string        // 跳板机列表，格式为 "user@host:port,..."
+	ServerAliveInterval time.Duration // 保活间隔
+	ServerAliveCountMax int           // 最大未应答保活次数
+	ConnectTimeout      time.Duration // 连接超时时间
+	ControlMaster       bool          // 是否在没有可用的主连接时启动一个（yes、auto 等）
+	ControlPath         string        // 控制套接字路径，占位符在连接参数确定后由 ExpandControlPath 展开
+	ControlPersist      time.Duration // 主连接空闲后保持的时间，ControlPersistForever 表示一直保持
+}
+
+// SSHConfigFile 表示解析后的 OpenSSH 客户端配置文件
+// 包括通过 Include 引入的所有文件
+type SSHConfigFile struct {
+	path  string          // 顶层配置文件路径
+	items []sshConfigItem // 按出现顺序排列的条件和选项
+}
+
+// sshConfigItem 是配置文件中的一行：条件块的开头（Host/Match）或者一个选项
+type sshConfigItem struct {
+	cond *sshConfigCond // 所属的条件块，nil 表示全局选项
+	head bool           // true 表示这一行是条件块的开头
+	key  string         // 小写的选项名
+	args []string       // 选项参数
+	file string         // 所在文件，用于错误信息
+	line int            // 所在行号，用于错误信息
+}
+
+// sshConfigCond 表示一个 Host 或 Match 条件
+type sshConfigCond struct {
+	hosts    []string         // Host 行的模式列表
+	criteria []matchCriterion // Match 行的条件列表
+	isMatch  bool             // true 表示这是 Match 块
+}
+
+// matchCriterion 表示 Match 行中的一个条件，如 "host *.example.com"
+type matchCriterion struct {
+	name    string // 小写的条件名：all、host、originalhost、user
+	negate  bool   // 条件前是否有 "!"
+	pattern string // 逗号分隔的模式列表
+}
+
+// DefaultSSHConfigPath 返回用户的 OpenSSH 客户端配置文件路径
+// 返回值:
+//   string: ~/.ssh/config 的绝对路径，无法确定主目录时返回空字符串
+func DefaultSSHConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// ParseSSHConfig 解析 OpenSSH 客户端配置文件
+// 参数:
+//   path: 配置文件路径
+// 返回值:
+//   *SSHConfigFile: 解析后的配置
+//   error: 如果文件无法读取或格式错误则返回错误
+func ParseSSHConfig(path string) (*SSHConfigFile, error) {
+	f := &SSHConfigFile{path: path}
+	if _, err := f.parseFile(path, nil, 0); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LoadHostConfig 从配置文件中解析主机别名
+// 使用默认路径且文件不存在时返回只包含别名的空配置，不视为错误
+// 参数:
+//   path: 配置文件路径，为空时使用 ~/.ssh/config
+//   alias: 主机别名
+// 返回值:
+//   *HostConfig: 解析结果
+//   error: 如果配置文件无法解析则返回错误
+func LoadHostConfig(path, alias string) (*HostConfig, error) {
+	if path == "" {
+		path = DefaultSSHConfigPath()
+		if _, err := os.Stat(path); path == "" || os.IsNotExist(err) {
+			return &HostConfig{Alias: alias, HostName: alias}, nil
+		}
+	}
+
+	file, err := ParseSSHConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return file.Resolve(alias)
+}
+
+// parseFile 解析单个配置文件，并把其中的行追加到 items
+// 参数:
+//   path: 文件路径
+//   cond: 文件开始时所处的条件块（Include 出现在 Host 块中时非空）
+//   depth: 当前 Include 嵌套层数
+// 返回值:
+//   *sshConfigCond: 文件结束时所处的条件块
+//   error: 解析失败时返回错误
+func (f *SSHConfigFile) parseFile(path string, cond *sshConfigCond, depth int) (*sshConfigCond, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开 SSH 配置文件失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		key, args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		if key == "" {
+			continue // 空行或注释
+		}
+
+		switch key {
+		case "host":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s:%d: Host 需要至少一个模式", path, lineNum)
+			}
+			cond = &sshConfigCond{hosts: args}
+			f.items = append(f.items, sshConfigItem{cond: cond, head: true, file: path, line: lineNum})
+		case "match":
+			criteria, err := parseMatchCriteria(args)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+			}
+			cond = &sshConfigCond{criteria: criteria, isMatch: true}
+			f.items = append(f.items, sshConfigItem{cond: cond, head: true, file: path, line: lineNum})
+		case "include":
+			if depth >= maxIncludeDepth {
+				return nil, fmt.Errorf("%s:%d: Include 嵌套层数过多", path, lineNum)
+			}
+			for _, pattern := range args {
+				if err := f.include(pattern, cond, depth); err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+				}
+			}
+		default:
+			f.items = append(f.items, sshConfigItem{cond: cond, key: key, args: args, file: path, line: lineNum})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 SSH 配置文件失败: %w", err)
+	}
+	return cond, nil
+}
+
+// include 处理 Include 指令，支持通配符
+// 相对路径相对于顶层配置文件所在的目录（对 ~/.ssh/config 来说就是 ~/.ssh）
+func (f *SSHConfigFile) include(pattern string, cond *sshConfigCond, depth int) error {
+	pattern = expandHome(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(f.path), pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("无效的 Include 路径 %s: %w", pattern, err)
+	}
+	for _, match := range matches {
+		// 被包含文件中的 Host/Match 只在该文件内有效，结束后回到原来的条件块
+		if _, err := f.parseFile(match, cond, depth+1); err != nil {
+			return err
+		}
+		f.items = append(f.items, sshConfigItem{cond: cond, head: true, file: match})
+	}
+	return nil
+}
+
+// splitConfigLine 把一行配置拆分为小写的选项名和参数
+// 支持 "Key value"、"Key=value" 两种写法，以及用双引号包含空格的参数
+func splitConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	// 选项名以空白或 "=" 结束
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	var args []string
+	var current strings.Builder
+	inQuote, hasArg := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		case r == '#' && !inQuote && !hasArg:
+			// 行尾注释
+			return key, args, nil
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuote {
+		return "", nil, errors.New("引号不匹配")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return key, args, nil
+}
+
+// parseMatchCriteria 解析 Match 行的条件
+// 支持 all、host、originalhost 和 user，其余条件（如 exec）视为不匹配
+func parseMatchCriteria(args []string) ([]matchCriterion, error) {
+	if len(args) == 0 {
+		return nil, errors.New("Match 需要至少一个条件")
+	}
+
+	var criteria []matchCriterion
+	for i := 0; i < len(args); i++ {
+		c := matchCriterion{name: strings.ToLower(args[i])}
+		if strings.HasPrefix(c.name, "!") {
+			c.negate = true
+			c.name = c.name[1:]
+		}
+		if c.name == "all" || c.name == "canonical" || c.name == "final" {
+			criteria = append(criteria, c)
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("Match 条件 %s 缺少参数", c.name)
+		}
+		i++
+		c.pattern = args[i]
+		criteria = append(criteria, c)
+	}
+	return criteria, nil
+}
+
+// Resolve 为主机别名解析配置
+// 按文件中的顺序处理所有匹配的块，每个选项以第一次出现的值为准
+// 参数:
+//   alias: 用户输入的主机别名
+// 返回值:
+//   *HostConfig: 解析结果
+//   error: 如果选项值无效则返回错误
+func (f *SSHConfigFile) Resolve(alias string) (*HostConfig, error) {
+	h := &HostConfig{Alias: alias}
+	seen := make(map[string]bool)           // 已经设置过的选项
+	active := make(map[*sshConfigCond]bool) // 每个条件块在出现时的匹配结果
+	current := true                         // 当前条件块是否匹配
+
+	for _, item := range f.items {
+		if item.head {
+			if item.cond == nil {
+				current = true
+				continue
+			}
+			matched, evaluated := active[item.cond]
+			if !evaluated {
+				matched = item.cond.matches(h)
+				active[item.cond] = matched
+			}
+			current = matched
+			continue
+		}
+		if !current || len(item.args) == 0 {
+			continue
+		}
+
+		// IdentityFile 可以出现多次，全部保留；其余选项第一个值生效
+		if item.key == "identityfile" {
+			h.IdentityFiles = append(h.IdentityFiles, item.args...)
+			continue
+		}
+		if seen[item.key] {
+			continue
+		}
+
+		if err := h.set(item.key, item.args[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", item.file, item.line, err)
+		}
+		seen[item.key] = true
+	}
+
+	// 没有设置 HostName 时直接使用别名
+	if h.HostName == "" {
+		h.HostName = alias
+	}
+	h.HostName = h.expandTokens(h.HostName)
+	for i, file := range h.IdentityFiles {
+		h.IdentityFiles[i] = expandHome(h.expandTokens(file))
+	}
+	return h, nil
+}
+
+// set 设置单个选项的值，不认识的选项会被忽略
+func (h *HostConfig) set(key, value string) error {
+	switch key {
+	case "hostname":
+		h.HostName = value
+	case "user":
+		h.User = value
+	case "port":
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("无效的端口: %s", value)
+		}
+		h.Port = port
+	case "proxyjump":
+		if !strings.EqualFold(value, "none") {
+			h.ProxyJump = value
+		}
+	case "serveraliveinterval":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("无效的 ServerAliveInterval: %s", value)
+		}
+		h.ServerAliveInterval = time.Duration(seconds) * time.Second
+	case "serveralivecountmax":
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return fmt.Errorf("无效的 ServerAliveCountMax: %s", value)
+		}
+		h.ServerAliveCountMax = count
+	case "connecttimeout":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("无效的 ConnectTimeout: %s", value)
+		}
+		h.ConnectTimeout = time.Duration(seconds) * time.Second
+	case "controlmaster":
+		switch strings.ToLower(value) {
+		case "yes", "auto", "ask", "autoask":
+			h.ControlMaster = true
+		case "no":
+		default:
+			return fmt.Errorf("无效的 ControlMaster: %s", value)
+		}
+	case "controlpath":
+		h.ControlPath = value
+	case "controlpersist":
+		persist, err := ParseControlPersist(value)
+		if err != nil {
+			return err
+		}
+		h.ControlPersist = persist
+	}
+	return nil
+}
+
+// matches 判断条件块是否适用于当前主机
+// Host 块匹配别名；Match host 匹配已经解析出的 HostName（没有时为别名）
+func (c *sshConfigCond) matches(h *HostConfig) bool {
+	if !c.isMatch {
+		return matchPatternList(c.hosts, h.Alias)
+	}
+
+	for _, criterion := range c.criteria {
+		var ok bool
+		switch criterion.name {
+		case "all":
+			ok = true
+		case "host":
+			target := h.Alias
+			if h.HostName != "" {
+				target = h.expandTokens(h.HostName)
+			}
+			ok = matchPatternList(strings.Split(criterion.pattern, ","), target)
+		case "originalhost":
+			ok = matchPatternList(strings.Split(criterion.pattern, ","), h.Alias)
+		case "user":
+			ok = h.User != "" && matchPatternList(strings.Split(criterion.pattern, ","), h.User)
+		default:
+			// 不支持的条件（exec、localuser 等）按不匹配处理
+			ok = false
+		}
+		if ok == criterion.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPatternList 判断主机名是否匹配模式列表
+// 至少匹配一个普通模式，并且不匹配任何以 "!" 开头的否定模式
+func matchPatternList(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if wildcardMatch(strings.ToLower(pattern[1:]), strings.ToLower(host)) {
+				return false
+			}
+			continue
+		}
+		if wildcardMatch(strings.ToLower(pattern), strings.ToLower(host)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// wildcardMatch 实现 OpenSSH 的通配符匹配："*" 匹配任意字符串，"?" 匹配单个字符
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// 合并连续的 "*"，然后尝试匹配剩余部分的每一个后缀
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if wildcardMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+// expandTokens 展开 OpenSSH 的 % 占位符
+// 支持 %h（主机名）、%n（别名）、%p（端口）、%r（远程用户）、%u（本地用户）、%d（主目录）和 %%
+func (h *HostConfig) expandTokens(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'h':
+			host := h.HostName
+			if host == "" || strings.Contains(host, "%") {
+				host = h.Alias
+			}
+			b.WriteString(host)
+		case 'n':
+			b.WriteString(h.Alias)
+		case 'p':
+			port := h.Port
+			if port == 0 {
+				port = 22
+			}
+			b.WriteString(strconv.Itoa(port))
+		case 'r':
+			b.WriteString(h.User)
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		case 'd':
+			if home, err := os.UserHomeDir(); err == nil {
+				b.WriteString(home)
+			}
+		default:
+			// 不认识的占位符原样保留
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// expandHome 把路径开头的 "~" 展开为用户主目录
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// ApplyTo 用解析结果补全连接配置中尚未设置的字段
+// 已经设置的字段（例如来自命令行参数）保持不变
+// 参数:
+//   cfg: 要补全的连接配置
+func (h *HostConfig) ApplyTo(cfg *SSHConfig) {
+	if cfg.Host == "" || cfg.Host == h.Alias {
+		cfg.Host = h.HostName
+	}
+	if cfg.Port == 0 {
+		cfg.Port = h.Port
+	}
+	if cfg.Username == "" {
+		cfg.Username = h.User
+	}
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = h.ConnectTimeout
+	}
+	if cfg.ServerAliveInterval == 0 {
+		cfg.ServerAliveInterval = h.ServerAliveInterval
+	}
+	if cfg.ServerAliveCountMax == 0 {
+		cfg.ServerAliveCountMax = h.ServerAliveCountMax
+	}
+
+	// 使用第一个存在的私钥文件
+	if cfg.KeyFile == "" {
+		for _, file := range h.IdentityFiles {
+			if _, err := os.Stat(file); err == nil {
+				cfg.KeyFile = file
+				break
+			}
+		}
+	}
+}
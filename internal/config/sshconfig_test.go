@@ -0,0 +1,245 @@
+// sshconfig_test 提供 OpenSSH 客户端配置解析的单元测试
+// 使用 testdata 目录下的配置文件作为测试数据
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSSHConfigFile_Resolve 测试主机别名解析
+func TestSSHConfigFile_Resolve(t *testing.T) {
+	t.Setenv("HOME", "/home/tester")
+
+	file, err := ParseSSHConfig(filepath.Join("testdata", "ssh_config"))
+	if err != nil {
+		t.Fatalf("ParseSSHConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		alias string
+		want  HostConfig
+	}{
+		{
+			name:  "第一个匹配的值生效，Include 先于后面的块",
+			alias: "web",
+			want: HostConfig{
+				Alias:               "web",
+				HostName:            "10.0.0.10",
+				User:                "included",
+				Port:                2222,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_web", "/home/tester/.ssh/id_default"},
+				ServerAliveInterval: 30 * time.Second,
+				ControlMaster:       true,
+				ControlPath:         "~/.ssh/cm-%r@%h:%p",
+				ControlPersist:      10 * time.Minute,
+			},
+		},
+		{
+			name:  "Include 文件中的主机",
+			alias: "bastion",
+			want: HostConfig{
+				Alias:               "bastion",
+				HostName:            "bastion.example.com",
+				User:                "jump",
+				Port:                22022,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_default"},
+				ServerAliveInterval: 30 * time.Second,
+			},
+		},
+		{
+			name:  "通配符、%h 展开和 Match host",
+			alias: "db-01",
+			want: HostConfig{
+				Alias:               "db-01",
+				HostName:            "db-01.internal.example.com",
+				User:                "dbadmin",
+				Port:                2200,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_internal", "/home/tester/.ssh/id_default"},
+				ProxyJump:           "bastion",
+				ServerAliveInterval: 30 * time.Second,
+			},
+		},
+		{
+			name:  "否定模式和 ProxyJump none",
+			alias: "db-legacy",
+			want: HostConfig{
+				Alias:               "db-legacy",
+				HostName:            "10.0.0.99",
+				User:                "defaultuser",
+				Port:                22,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_default"},
+				ServerAliveInterval: 30 * time.Second,
+				ConnectTimeout:      5 * time.Second,
+				ServerAliveCountMax: 5,
+			},
+		},
+		{
+			name:  "引号和等号写法",
+			alias: "quoted",
+			want: HostConfig{
+				Alias:               "quoted",
+				HostName:            "quoted.example.com",
+				User:                "john doe",
+				Port:                22,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_default"},
+				ServerAliveInterval: 30 * time.Second,
+			},
+		},
+		{
+			name:  "只匹配 Host *",
+			alias: "unknown.example.org",
+			want: HostConfig{
+				Alias:               "unknown.example.org",
+				HostName:            "unknown.example.org",
+				User:                "defaultuser",
+				Port:                22,
+				IdentityFiles:       []string{"/home/tester/.ssh/id_default"},
+				ServerAliveInterval: 30 * time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := file.Resolve(tt.alias)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSSHConfigFile_IncludeInHostBlock 测试 Host 块中的 Include 只对该块生效
+func TestSSHConfigFile_IncludeInHostBlock(t *testing.T) {
+	file, err := ParseSSHConfig(filepath.Join("testdata", "ssh_config_nested"))
+	if err != nil {
+		t.Fatalf("ParseSSHConfig() error = %v", err)
+	}
+
+	nested, err := file.Resolve("nested")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if nested.HostName != "nested.example.com" || nested.User != "nesteduser" || nested.Port != 4444 {
+		t.Errorf("Resolve(nested) = %+v", *nested)
+	}
+
+	other, err := file.Resolve("other")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if other.HostName != "other.example.com" || other.User != "" || other.Port != 0 {
+		t.Errorf("Resolve(other) = %+v", *other)
+	}
+}
+
+// TestParseSSHConfig_Errors 测试格式错误的配置文件
+func TestParseSSHConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{name: "Host 缺少模式", content: "Host\n", errMsg: "Host 需要至少一个模式"},
+		{name: "Match 缺少参数", content: "Match host\n", errMsg: "缺少参数"},
+		{name: "引号不匹配", content: "Host \"web\n", errMsg: "引号不匹配"},
+		{name: "无效端口", content: "Host web\n  Port abc\n", errMsg: "无效的端口"},
+		{name: "无效的 ControlMaster", content: "Host web\n  ControlMaster maybe\n", errMsg: "无效的 ControlMaster"},
+		{name: "无效的 ControlPersist", content: "Host web\n  ControlPersist soon\n", errMsg: "无效的 ControlPersist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("写入配置文件失败: %v", err)
+			}
+
+			file, err := ParseSSHConfig(path)
+			if err == nil {
+				_, err = file.Resolve("web")
+			}
+			if err == nil || !contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want error containing %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+// TestWildcardMatch 测试通配符匹配
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "anything", true},
+		{"web?", "web1", true},
+		{"web?", "web12", false},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"db-*-prod", "db-01-prod", true},
+		{"10.0.*.1", "10.0.5.1", true},
+	}
+
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestHostConfig_ApplyTo 测试用解析结果补全连接配置
+func TestHostConfig_ApplyTo(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "id_test")
+	if err := os.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatalf("写入私钥文件失败: %v", err)
+	}
+
+	host := &HostConfig{
+		Alias:         "web",
+		HostName:      "10.0.0.10",
+		User:          "deploy",
+		Port:          2222,
+		IdentityFiles: []string{"/path/to/missing", keyFile},
+	}
+
+	// 未设置的字段从 ssh_config 补全
+	cfg := &SSHConfig{Host: "web"}
+	host.ApplyTo(cfg)
+	want := &SSHConfig{Host: "10.0.0.10", Port: 2222, Username: "deploy", KeyFile: keyFile}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ApplyTo() = %+v, want %+v", cfg, want)
+	}
+
+	// 命令行中明确指定的字段保持不变
+	cfg = &SSHConfig{Host: "web", Port: 22, Username: "root", KeyFile: "/my/key"}
+	host.ApplyTo(cfg)
+	want = &SSHConfig{Host: "10.0.0.10", Port: 22, Username: "root", KeyFile: "/my/key"}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ApplyTo() = %+v, want %+v", cfg, want)
+	}
+}
+
+// TestLoadHostConfig_MissingDefault 测试默认配置文件不存在时保持主机地址不变
+func TestLoadHostConfig_MissingDefault(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	host, err := LoadHostConfig("", "192.168.1.100")
+	if err != nil {
+		t.Fatalf("LoadHostConfig() error = %v", err)
+	}
+	cfg := &SSHConfig{Host: "192.168.1.100", Username: "root"}
+	host.ApplyTo(cfg)
+	if cfg.Host != "192.168.1.100" {
+		t.Errorf("ApplyTo() 后 Host = %q, want 192.168.1.100", cfg.Host)
+	}
+}
@@ -0,0 +1,156 @@
+// Package mux 的客户端模块
+// 通过控制套接字使用已有的主连接，以及发送 check / exit 控制命令
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// dialTimeout 是连接控制套接字和完成本地握手的超时时间
+const dialTimeout = 5 * time.Second
+
+// ErrNoMaster 表示控制套接字上没有正在运行的主连接
+var ErrNoMaster = errors.New("没有正在运行的主连接")
+
+// Options 是连接复用的参数
+type Options struct {
+	Path    string        // 展开后的控制套接字路径，为空时不复用连接
+	Master  bool          // 没有可用的主连接时是否在后台启动一个
+	Persist time.Duration // 新启动的主连接在最后一个客户端断开后保持的时间
+}
+
+// Connect 按照连接复用的设置建立客户端
+// 有可用的主连接时通过它打开连接；没有时，如果 opts.Master 为 true 则先在后台启动主连接，
+// 否则直接连接服务器
+// 参数:
+//   cfg: SSH 连接配置
+//   opts: 连接复用参数
+// 返回值:
+//   *sshclient.Client: 建立好的客户端
+//   error: 如果连接失败则返回错误
+func Connect(cfg *config.SSHConfig, opts Options) (*sshclient.Client, error) {
+	if opts.Path == "" {
+		return sshclient.NewClient(cfg)
+	}
+	if client, err := Dial(opts.Path, cfg); err == nil {
+		return client, nil
+	}
+	if !opts.Master {
+		return sshclient.NewClient(cfg)
+	}
+
+	if err := StartMaster(opts.Path, cfg, opts.Persist); err != nil {
+		return nil, err
+	}
+	return Dial(opts.Path, cfg)
+}
+
+// Dial 通过控制套接字上的主连接建立客户端
+// 参数:
+//   path: 控制套接字路径
+//   cfg: 连接配置，只用于显示连接信息和保活参数，认证已经由主连接完成
+// 返回值:
+//   *sshclient.Client: 建立好的客户端，关闭时不影响主连接
+//   error: 如果没有主连接在运行则返回 ErrNoMaster
+func Dial(path string, cfg *config.SSHConfig) (*sshclient.Client, error) {
+	conn, err := dialControl(path)
+	if err != nil {
+		return nil, err
+	}
+	return sshclient.NewClientFromConn(cfg, conn), nil
+}
+
+// Check 检查控制套接字上是否有主连接在运行
+// 参数:
+//   path: 控制套接字路径
+// 返回值:
+//   int: 主连接的进程号
+//   error: 如果没有主连接在运行则返回 ErrNoMaster
+func Check(path string) (int, error) {
+	conn, err := dialControl(path)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ok, reply, err := conn.SendRequest(checkRequest, true, nil)
+	if err != nil || !ok {
+		return 0, fmt.Errorf("检查主连接失败: %w", ErrNoMaster)
+	}
+	var payload struct{ Pid uint32 }
+	if err := ssh.Unmarshal(reply, &payload); err != nil {
+		return 0, fmt.Errorf("无效的应答: %w", err)
+	}
+	return int(payload.Pid), nil
+}
+
+// Exit 让控制套接字上的主连接退出，通过它打开的连接也会断开
+// 参数:
+//   path: 控制套接字路径
+// 返回值:
+//   error: 如果没有主连接在运行则返回 ErrNoMaster
+func Exit(path string) error {
+	conn, err := dialControl(path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if ok, _, err := conn.SendRequest(exitRequest, true, nil); err != nil || !ok {
+		return fmt.Errorf("请求主连接退出失败: %w", ErrNoMaster)
+	}
+	return nil
+}
+
+// RunControlCommand 执行 -O 指定的控制命令
+// 参数:
+//   path: 控制套接字路径
+//   cmd: 控制命令，check 检查主连接是否在运行，exit 让主连接退出
+// 返回值:
+//   string: 给用户看的结果
+//   error: 如果命令不支持或者没有主连接在运行则返回错误
+func RunControlCommand(path, cmd string) (string, error) {
+	switch cmd {
+	case "check":
+		pid, err := Check(path)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("主连接正在运行 (pid=%d)", pid), nil
+	case "exit":
+		if err := Exit(path); err != nil {
+			return "", err
+		}
+		return "已请求主连接退出", nil
+	default:
+		return "", fmt.Errorf("不支持的控制命令 %q，请使用 check 或 exit", cmd)
+	}
+}
+
+// dialControl 连接控制套接字并完成本地 SSH 握手
+func dialControl(path string) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoMaster, err)
+	}
+
+	// 控制套接字只有当前用户可以访问，主连接的主机密钥每次启动时随机生成，不需要校验
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, path, &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %v", ErrNoMaster, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
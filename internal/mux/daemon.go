@@ -0,0 +1,125 @@
+// Package mux 的后台进程模块
+// 第一次调用时以特殊参数重新执行程序自身，由子进程建立连接并作为主连接在后台运行
+// 连接参数通过管道传给子进程，子进程准备好之后通过另一个管道通知父进程
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// MasterCommand 是启动后台主连接时传给程序自身的第一个参数
+// 使用连接复用的程序需要在解析命令行参数之前检查它，并调用 RunMaster
+const MasterCommand = "__mux-master"
+
+// masterReady 是主连接进程准备好之后写入状态管道的内容，其他内容都是错误信息
+const masterReady = "ok"
+
+// masterRequest 是传给主连接进程的参数
+type masterRequest struct {
+	Config  *config.SSHConfig // 连接配置，包括密码等认证信息，只通过管道传递
+	Path    string            // 控制套接字路径
+	Persist time.Duration     // 空闲后保持的时间
+}
+
+// StartMaster 在后台启动主连接，等它连接成功并开始监听控制套接字后返回
+// 主连接建立之前子进程仍然使用当前的终端，需要时可以提示输入私钥密码
+// 参数:
+//   path: 控制套接字路径
+//   cfg: SSH 连接配置
+//   persist: 最后一个客户端断开后保持的时间
+// 返回值:
+//   error: 如果子进程无法启动或者连接失败则返回错误
+func StartMaster(path string, cfg *config.SSHConfig, persist time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("找不到程序路径: %w", err)
+	}
+
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("创建管道失败: %w", err)
+	}
+	defer reqW.Close()
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		return fmt.Errorf("创建管道失败: %w", err)
+	}
+	defer statusR.Close()
+
+	// 子进程中 fd 3 是参数管道，fd 4 是状态管道
+	cmd := exec.Command(exe, MasterCommand)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{reqR, statusW}
+	cmd.SysProcAttr = sysProcAttr()
+	err = cmd.Start()
+	reqR.Close()
+	statusW.Close()
+	if err != nil {
+		return fmt.Errorf("启动主连接进程失败: %w", err)
+	}
+
+	json.NewEncoder(reqW).Encode(&masterRequest{Config: cfg, Path: path, Persist: persist})
+	reqW.Close()
+
+	status, _ := io.ReadAll(statusR)
+	if string(status) != masterReady {
+		cmd.Wait()
+		if len(status) == 0 {
+			return errors.New("启动主连接失败: 主连接进程意外退出")
+		}
+		return fmt.Errorf("启动主连接失败: %s", status)
+	}
+	return cmd.Process.Release()
+}
+
+// RunMaster 是主连接进程的入口，由 StartMaster 启动的子进程调用
+// 建立连接、开始监听控制套接字后脱离终端，一直运行到主连接关闭
+// 返回值:
+//   int: 进程退出码
+func RunMaster() int {
+	reqFile := os.NewFile(3, "mux-request")
+	status := os.NewFile(4, "mux-status")
+	fail := func(err error) int {
+		fmt.Fprint(status, err)
+		status.Close()
+		return 1
+	}
+
+	var req masterRequest
+	if err := json.NewDecoder(reqFile).Decode(&req); err != nil {
+		return fail(fmt.Errorf("读取主连接参数失败: %w", err))
+	}
+	reqFile.Close()
+
+	client, err := sshclient.NewClient(req.Config)
+	if err != nil {
+		return fail(err)
+	}
+	master, err := Listen(req.Path, client, req.Persist)
+	if err != nil {
+		client.Close()
+		return fail(err)
+	}
+	if err := detachStdio(); err != nil {
+		master.Close()
+		return fail(err)
+	}
+	status.WriteString(masterReady)
+	status.Close()
+
+	if err := master.Serve(); err != nil {
+		return 1
+	}
+	return 0
+}
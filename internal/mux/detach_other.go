@@ -0,0 +1,19 @@
+//go:build !unix
+
+// Package mux 在不支持的平台上的实现
+package mux
+
+import (
+	"errors"
+	"syscall"
+)
+
+// sysProcAttr 在不支持的平台上不设置任何属性
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// detachStdio 在不支持的平台上返回错误，主连接进程不会进入后台运行
+func detachStdio() error {
+	return errors.New("当前平台不支持后台主连接")
+}
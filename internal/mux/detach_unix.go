@@ -0,0 +1,34 @@
+//go:build unix
+
+// Package mux 在 Unix 系统上脱离终端的实现
+package mux
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysProcAttr 让主连接进程在新的会话中运行，关闭终端时不会收到 SIGHUP
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// detachStdio 把标准输入、输出和错误重定向到 /dev/null
+// 否则启动主连接的进程退出后，它的终端或管道仍然被主连接占用
+func detachStdio() error {
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("打开 %s 失败: %w", os.DevNull, err)
+	}
+	defer null.Close()
+
+	for fd := 0; fd <= 2; fd++ {
+		if err := unix.Dup2(int(null.Fd()), fd); err != nil {
+			return fmt.Errorf("重定向标准输入输出失败: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package mux 提供类似 OpenSSH ControlMaster 的连接复用功能
+// 主连接持有到服务器的 SSH 连接，并在 Unix 套接字上运行一个本地 SSH 服务；
+// 之后的调用连接这个套接字，打开的通道和全局请求都由主连接转发到服务器，
+// 因此不需要重新握手和认证
+package mux
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"gossh/internal/sshclient"
+)
+
+// 主连接自己处理的全局请求
+const (
+	checkRequest = "check@gossh" // 检查主连接是否在运行，应答中包含进程号
+	exitRequest  = "exit@gossh"  // 让主连接退出
+)
+
+// startupGrace 是主连接启动后等待第一个客户端的最短时间
+// 避免 ControlPersist 为 0 时在启动它的进程连接之前就退出
+const startupGrace = 10 * time.Second
+
+// Master 是连接复用的主连接
+type Master struct {
+	path     string            // 控制套接字路径
+	client   *sshclient.Client // 到服务器的连接
+	listener net.Listener      // 控制套接字
+	config   *ssh.ServerConfig // 本地 SSH 服务的配置
+	persist  time.Duration     // 最后一个客户端断开后保持的时间
+
+	mu     sync.Mutex                   // 保护下面的字段
+	active map[*ssh.ServerConn]struct{} // 当前连接的客户端
+	idle   *time.Timer                  // 空闲超时的定时器
+	closed bool                         // 是否已经关闭
+
+	done chan struct{} // 关闭时关闭
+}
+
+// tcpipForwardPayload 是 tcpip-forward 和 cancel-tcpip-forward 请求的负载（RFC 4254 7.1）
+type tcpipForwardPayload struct {
+	Addr string
+	Port uint32
+}
+
+// forwardedTCPIPPayload 是 forwarded-tcpip 通道的负载（RFC 4254 7.2）
+type forwardedTCPIPPayload struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// Listen 在控制套接字上启动主连接
+// 套接字文件已经存在但没有主连接在监听时视为残留文件并删除
+// 参数:
+//   path: 控制套接字路径
+//   client: 到服务器的连接，由主连接负责关闭
+//   persist: 最后一个客户端断开后保持的时间，为 config.ControlPersistForever 时一直保持
+// 返回值:
+//   *Master: 已经开始监听的主连接，需要调用 Serve 处理客户端
+//   error: 如果已经有主连接在使用这个套接字或者无法监听则返回错误
+func Listen(path string, client *sshclient.Client, persist time.Duration) (*Master, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("控制套接字 %s 已经有主连接在使用", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("删除残留的控制套接字失败: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听控制套接字失败: %w", err)
+	}
+	// 任何能连接套接字的进程都可以使用这个连接，只允许当前用户访问
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置控制套接字权限失败: %w", err)
+	}
+
+	// 本地 SSH 服务不需要认证，主机密钥每次启动时随机生成
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("生成主机密钥失败: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("生成主机密钥失败: %w", err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	return &Master{
+		path:     path,
+		client:   client,
+		listener: listener,
+		config:   serverConfig,
+		persist:  persist,
+		active:   make(map[*ssh.ServerConn]struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Serve 接受客户端连接，直到主连接关闭
+// 收到 exit 命令、空闲超过 ControlPersist 或者到服务器的连接断开时关闭
+// 返回值:
+//   error: 正常关闭时为 nil
+func (m *Master) Serve() error {
+	go func() {
+		select {
+		case <-m.client.Done():
+			m.Close()
+		case <-m.done:
+		}
+	}()
+
+	m.mu.Lock()
+	if m.persist >= 0 {
+		m.idle = time.AfterFunc(max(m.persist, startupGrace), func() { m.Close() })
+	}
+	m.mu.Unlock()
+
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			select {
+			case <-m.done:
+				return nil
+			default:
+				return fmt.Errorf("接受控制连接失败: %w", err)
+			}
+		}
+		go m.handle(conn)
+	}
+}
+
+// Close 关闭主连接：停止监听、断开所有客户端并关闭到服务器的连接
+// 返回值:
+//   error: 始终为 nil
+func (m *Master) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	if m.idle != nil {
+		m.idle.Stop()
+	}
+	conns := make([]*ssh.ServerConn, 0, len(m.active))
+	for conn := range m.active {
+		conns = append(conns, conn)
+	}
+	m.mu.Unlock()
+
+	close(m.done)
+	m.listener.Close()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	m.client.Close()
+	return nil
+}
+
+// Done 返回一个在主连接关闭时关闭的通道
+func (m *Master) Done() <-chan struct{} {
+	return m.done
+}
+
+// handle 处理一个客户端连接：完成握手后转发通道和全局请求
+func (m *Master) handle(conn net.Conn) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, m.config)
+	if err != nil {
+		return
+	}
+	if !m.acquire(sconn) {
+		sconn.Close()
+		return
+	}
+	defer m.release(sconn)
+
+	go m.handleGlobalRequests(sconn, reqs)
+	for newChannel := range chans {
+		go m.openChannel(newChannel)
+	}
+}
+
+// acquire 登记一个客户端并停止空闲计时
+// 返回值:
+//   bool: 主连接已经关闭时返回 false
+func (m *Master) acquire(conn *ssh.ServerConn) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false
+	}
+	m.active[conn] = struct{}{}
+	if m.idle != nil {
+		m.idle.Stop()
+		m.idle = nil
+	}
+	return true
+}
+
+// release 注销一个客户端，最后一个客户端断开时开始空闲计时
+func (m *Master) release(conn *ssh.ServerConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, conn)
+	if m.closed || len(m.active) > 0 || m.persist < 0 {
+		return
+	}
+	m.idle = time.AfterFunc(m.persist, func() { m.Close() })
+}
+
+// openChannel 在服务器上打开同样的通道，并在两个通道之间转发数据和请求
+func (m *Master) openChannel(newChannel ssh.NewChannel) {
+	upstream, upstreamReqs, err := m.client.GetConnection().OpenChannel(newChannel.ChannelType(), newChannel.ExtraData())
+	if err != nil {
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			newChannel.Reject(openErr.Reason, openErr.Message)
+		} else {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		}
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	proxyChannel(channel, reqs, upstream, upstreamReqs)
+}
+
+// proxyChannel 在客户端通道和服务器通道之间转发数据、扩展数据和通道请求
+// 服务器关闭通道后，等剩余的数据都转发完再关闭客户端通道，保证客户端收到完整的输出和退出状态
+func proxyChannel(channel ssh.Channel, reqs <-chan *ssh.Request, upstream ssh.Channel, upstreamReqs <-chan *ssh.Request) {
+	go func() {
+		io.Copy(upstream, channel)
+		upstream.CloseWrite()
+	}()
+	go io.Copy(upstream.Stderr(), channel.Stderr())
+
+	var output sync.WaitGroup
+	output.Add(2)
+	go func() {
+		defer output.Done()
+		io.Copy(channel, upstream)
+	}()
+	go func() {
+		defer output.Done()
+		io.Copy(channel.Stderr(), upstream.Stderr())
+	}()
+
+	// 客户端关闭通道时关闭服务器上的通道
+	// 命令很快结束时，服务器可能在 exec 的应答传回客户端之前就关闭了通道，
+	// 转发客户端请求期间持有 pending，关闭客户端通道之前等应答发送完
+	var pending sync.Mutex
+	go func() {
+		forwardRequests(reqs, upstream, &pending)
+		upstream.Close()
+	}()
+
+	forwardRequests(upstreamReqs, channel, nil)
+	output.Wait()
+	pending.Lock()
+	defer pending.Unlock()
+	channel.CloseWrite()
+	channel.Close()
+}
+
+// forwardRequests 把通道请求转发到另一个通道，并把应答传回去
+// 参数:
+//   reqs: 要转发的请求
+//   to: 请求发往的通道
+//   pending: 不为 nil 时，从发送请求到传回应答期间持有这个锁
+func forwardRequests(reqs <-chan *ssh.Request, to ssh.Channel, pending *sync.Mutex) {
+	for req := range reqs {
+		if pending != nil {
+			pending.Lock()
+		}
+		ok, err := to.SendRequest(req.Type, req.WantReply, req.Payload)
+		if req.WantReply {
+			req.Reply(ok && err == nil, nil)
+		}
+		if pending != nil {
+			pending.Unlock()
+		}
+	}
+}
+
+// handleGlobalRequests 处理客户端的全局请求
+// 控制命令由主连接自己处理；远程端口转发由主连接在服务器上监听，
+// 收到的连接再以 forwarded-tcpip 通道交给请求的客户端；其他请求原样转发到服务器
+func (m *Master) handleGlobalRequests(sconn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	listeners := make(map[string]net.Listener)
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	upstream := m.client.GetConnection()
+	for req := range reqs {
+		switch req.Type {
+		case checkRequest:
+			req.Reply(true, ssh.Marshal(struct{ Pid uint32 }{uint32(os.Getpid())}))
+		case exitRequest:
+			req.Reply(true, nil)
+			go m.Close()
+		case "tcpip-forward":
+			var payload tcpipForwardPayload
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			listener, err := upstream.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			port := uint32(listener.Addr().(*net.TCPAddr).Port)
+			listeners[net.JoinHostPort(payload.Addr, strconv.Itoa(int(port)))] = listener
+
+			// 端口为 0 时需要在回复中告诉客户端实际分配的端口
+			var reply []byte
+			if payload.Port == 0 {
+				reply = ssh.Marshal(struct{ Port uint32 }{port})
+			}
+			req.Reply(true, reply)
+			go acceptForwarded(sconn, listener, payload.Addr, port)
+		case "cancel-tcpip-forward":
+			var payload tcpipForwardPayload
+			ssh.Unmarshal(req.Payload, &payload)
+			key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+			listener, ok := listeners[key]
+			if ok {
+				listener.Close()
+				delete(listeners, key)
+			}
+			req.Reply(ok, nil)
+		default:
+			ok, reply, err := upstream.SendRequest(req.Type, req.WantReply, req.Payload)
+			if req.WantReply {
+				req.Reply(ok && err == nil, reply)
+			}
+		}
+	}
+}
+
+// acceptForwarded 接受服务器转发过来的连接，并通过 forwarded-tcpip 通道交给客户端
+func acceptForwarded(sconn *ssh.ServerConn, listener net.Listener, addr string, port uint32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			payload := forwardedTCPIPPayload{Addr: addr, Port: port}
+			if origin, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+				payload.OriginAddr = origin.IP.String()
+				payload.OriginPort = uint32(origin.Port)
+			}
+			channel, reqs, err := sconn.OpenChannel("forwarded-tcpip", ssh.Marshal(&payload))
+			if err != nil {
+				return
+			}
+			defer channel.Close()
+			go ssh.DiscardRequests(reqs)
+			go func() {
+				io.Copy(channel, conn)
+				channel.CloseWrite()
+			}()
+			io.Copy(conn, channel)
+		}()
+	}
+}
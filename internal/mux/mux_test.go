@@ -0,0 +1,328 @@
+// mux_test 提供连接复用的单元测试
+// 主连接连到进程内的 SSH 服务器，客户端通过临时目录中的控制套接字使用它
+package mux
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// startUpstream 启动一个只支持 exec 的 SSH 服务器，返回连接它的配置
+func startUpstream(t *testing.T) *config.SSHConfig {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成主机密钥失败: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("创建签名器失败: %v", err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == "tester" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("用户名或密码错误")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动测试服务器失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveUpstream(conn, serverConfig)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return &config.SSHConfig{
+		Host:          addr.IP.String(),
+		Port:          addr.Port,
+		Username:      "tester",
+		Password:      "secret",
+		HostKeyPolicy: config.HostKeyOff,
+	}
+}
+
+// serveUpstream 处理一个连接：会话通道中的 exec 请求通过本地的 sh -c 执行
+func serveUpstream(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "测试服务器不支持该通道类型")
+			continue
+		}
+		channel, reqs, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range reqs {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+
+				cmd := exec.Command("sh", "-c", payload.Command)
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+				status := 0
+				if err := cmd.Run(); err != nil {
+					var exitErr *exec.ExitError
+					if !errors.As(err, &exitErr) {
+						status = 127
+					} else {
+						status = exitErr.ExitCode()
+					}
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+				return
+			}
+		}()
+	}
+}
+
+// startMaster 连接测试服务器并在临时目录中启动主连接
+// 返回值:
+//   *Master: 正在运行的主连接
+//   *config.SSHConfig: 连接配置
+func startMaster(t *testing.T, persist time.Duration) (*Master, *config.SSHConfig) {
+	t.Helper()
+	cfg := startUpstream(t)
+	client, err := sshclient.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	master, err := Listen(filepath.Join(t.TempDir(), "ctl"), client, persist)
+	if err != nil {
+		client.Close()
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go master.Serve()
+	t.Cleanup(func() { master.Close() })
+	return master, cfg
+}
+
+// waitClosed 等待主连接关闭
+func waitClosed(t *testing.T, master *Master) {
+	t.Helper()
+	select {
+	case <-master.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("主连接没有关闭")
+	}
+}
+
+// TestDial_Run 测试多个客户端通过主连接并发执行命令
+func TestDial_Run(t *testing.T) {
+	master, cfg := startMaster(t, config.ControlPersistForever)
+
+	tests := []struct {
+		name       string
+		cmd        string
+		wantStdout string
+		wantStderr string
+		wantCode   int
+	}{
+		{name: "标准输出", cmd: "echo hello", wantStdout: "hello\n"},
+		{name: "标准错误和退出码", cmd: "echo oops >&2; exit 3", wantStderr: "oops\n", wantCode: 3},
+		{name: "大量输出", cmd: "seq 1 20000 | tail -n 1", wantStdout: "20000\n"},
+	}
+
+	var wg sync.WaitGroup
+	for _, tt := range tests {
+		tt := tt
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := Dial(master.path, cfg)
+			if err != nil {
+				t.Errorf("%s: Dial() error = %v", tt.name, err)
+				return
+			}
+			defer client.Close()
+
+			result, err := client.Run(context.Background(), tt.cmd, nil)
+			if err != nil && tt.wantCode == 0 {
+				t.Errorf("%s: Run() error = %v", tt.name, err)
+				return
+			}
+			if string(result.Stdout) != tt.wantStdout || string(result.Stderr) != tt.wantStderr || result.ExitCode != tt.wantCode {
+				t.Errorf("%s: Run() = (%q, %q, %d), want (%q, %q, %d)", tt.name,
+					result.Stdout, result.Stderr, result.ExitCode, tt.wantStdout, tt.wantStderr, tt.wantCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 客户端关闭后主连接继续运行
+	select {
+	case <-master.Done():
+		t.Fatal("客户端断开后主连接被关闭")
+	default:
+	}
+}
+
+// TestCheckAndExit 测试 check 和 exit 控制命令
+func TestCheckAndExit(t *testing.T) {
+	master, _ := startMaster(t, config.ControlPersistForever)
+
+	pid, err := Check(master.path)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("Check() = %d, want %d", pid, os.Getpid())
+	}
+
+	if err := Exit(master.path); err != nil {
+		t.Fatalf("Exit() error = %v", err)
+	}
+	waitClosed(t, master)
+	if _, err := os.Stat(master.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("退出后控制套接字仍然存在: %v", err)
+	}
+
+	if _, err := Check(master.path); !errors.Is(err, ErrNoMaster) {
+		t.Errorf("退出后 Check() error = %v, want ErrNoMaster", err)
+	}
+	if err := Exit(master.path); !errors.Is(err, ErrNoMaster) {
+		t.Errorf("退出后 Exit() error = %v, want ErrNoMaster", err)
+	}
+}
+
+// TestMaster_Persist 测试最后一个客户端断开并空闲超过 ControlPersist 后主连接退出
+func TestMaster_Persist(t *testing.T) {
+	master, cfg := startMaster(t, 50*time.Millisecond)
+
+	client, err := Dial(master.path, cfg)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-master.Done():
+		t.Fatal("还有客户端连接时主连接就退出了")
+	default:
+	}
+
+	client.Close()
+	waitClosed(t, master)
+}
+
+// TestMaster_UpstreamClosed 测试到服务器的连接断开后主连接退出
+func TestMaster_UpstreamClosed(t *testing.T) {
+	master, _ := startMaster(t, config.ControlPersistForever)
+	master.client.GetConnection().Close()
+	waitClosed(t, master)
+}
+
+// TestListen_Socket 测试残留的套接字文件和已经在使用的套接字
+func TestListen_Socket(t *testing.T) {
+	master, cfg := startMaster(t, config.ControlPersistForever)
+
+	client, err := sshclient.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	// 已经有主连接在使用的套接字
+	if _, err := Listen(master.path, client, 0); err == nil || !contains(err.Error(), "已经有主连接在使用") {
+		t.Errorf("Listen() error = %v, want 已经有主连接在使用", err)
+	}
+
+	// 残留的文件会被替换
+	stale := filepath.Join(t.TempDir(), "stale")
+	if err := os.WriteFile(stale, nil, 0600); err != nil {
+		t.Fatalf("创建残留文件失败: %v", err)
+	}
+	second, err := Listen(stale, client, config.ControlPersistForever)
+	if err != nil {
+		t.Fatalf("Listen() 残留文件 error = %v", err)
+	}
+	defer second.Close()
+	go second.Serve()
+
+	info, err := os.Stat(stale)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("控制套接字的模式 = %v, want 权限为 0600 的套接字", info.Mode())
+	}
+	if _, err := Check(stale); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
+
+// TestConnect 测试没有控制套接字时直接连接
+func TestConnect(t *testing.T) {
+	cfg := startUpstream(t)
+	path := filepath.Join(t.TempDir(), "ctl")
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "不复用连接", opts: Options{}},
+		{name: "没有主连接时直接连接", opts: Options{Path: path}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := Connect(cfg, tt.opts)
+			if err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer client.Close()
+			if got := client.GetConnection().RemoteAddr().String(); got != net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)) {
+				t.Errorf("连接的地址 = %s, want 服务器地址", got)
+			}
+		})
+	}
+}
+
+// contains 检查字符串是否包含子字符串
+func contains(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
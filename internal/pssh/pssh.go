@@ -0,0 +1,219 @@
+// Package pssh 提供在多台主机上并发执行同一条命令的功能
+// 使用有上限的工作池连接各台主机，每台主机单独计算超时
+// 输出可以带主机名前缀实时显示，也可以在结束后按相同输出分组汇总
+package pssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// DefaultConcurrency 是未指定并发数时同时连接的主机数
+const DefaultConcurrency = 10
+
+// Host 表示一台要执行命令的主机
+type Host struct {
+	Name   string            // 显示名称，通常是用户输入的主机，为空时使用 Config 中的地址
+	Config *config.SSHConfig // 连接配置
+}
+
+// label 返回主机的显示名称
+func (h Host) label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	if h.Config.Port != 0 && h.Config.Port != 22 {
+		return h.Config.Host + ":" + strconv.Itoa(h.Config.Port)
+	}
+	return h.Config.Host
+}
+
+// Options 是批量执行的参数
+type Options struct {
+	Concurrency int           // 同时连接的主机数，为 0 时使用 DefaultConcurrency
+	Timeout     time.Duration // 每台主机的超时时间（包括连接和执行），为 0 时不限制
+	Output      io.Writer     // 设置后每一行输出都带上 "[主机] " 前缀实时写入，否则收集到结果中
+}
+
+// HostResult 表示一台主机的执行结果
+type HostResult struct {
+	Host   string            // 主机的显示名称
+	Result *sshclient.Result // 命令的执行结果，连接失败时为 nil
+	Err    error             // 连接失败、超时等错误
+}
+
+// Failed 检查这台主机是否执行失败
+// 返回值:
+//   bool: 连接失败、超时或者命令以非零状态退出时返回 true
+func (r *HostResult) Failed() bool {
+	return r.Err != nil || r.Result == nil || !r.Result.Success()
+}
+
+// runner 是执行命令需要的客户端功能，测试时可以替换
+type runner interface {
+	Run(ctx context.Context, cmd string, opts *sshclient.RunOptions) (*sshclient.Result, error)
+	Close() error
+}
+
+// connect 使用共用的私钥缓存连接一台主机，测试时可以替换
+var connect = func(ctx context.Context, cfg *config.SSHConfig, keys *sshclient.KeyCache) (runner, error) {
+	return sshclient.NewClientWithKeys(ctx, cfg, keys)
+}
+
+// Run 在所有主机上并发执行同一条命令
+// 连接之前先依次解析所有主机使用的私钥，加密的私钥只提示一次密码，无法使用时这些主机直接失败
+// 参数:
+//   ctx: 上下文，取消时中止所有主机上的命令
+//   hosts: 主机列表
+//   cmd: 要执行的命令
+//   opts: 执行参数
+// 返回值:
+//   []*HostResult: 每台主机的结果，顺序与 hosts 相同
+func Run(ctx context.Context, hosts []Host, cmd string, opts Options) []*HostResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	// 多台主机的输出写入同一个 Writer，需要按行加锁
+	var outMu sync.Mutex
+	prefixed := func(label string) *sshclient.LineWriter {
+		return sshclient.NewLineWriter(func(line string) {
+			outMu.Lock()
+			defer outMu.Unlock()
+			fmt.Fprintf(opts.Output, "[%s] %s\n", label, line)
+		})
+	}
+
+	// 并发连接时多台主机不能同时在终端上提示输入密码，所以先在这里逐个解析私钥
+	results := make([]*HostResult, len(hosts))
+	keys := sshclient.NewKeyCache()
+	for i, host := range hosts {
+		if err := keys.Preload(host.Config); err != nil {
+			results[i] = &HostResult{Host: host.label(), Err: err}
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		if results[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, host Host) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			runOpts := &sshclient.RunOptions{}
+			if opts.Output != nil {
+				runOpts.Stdout = prefixed(host.label())
+				runOpts.Stderr = prefixed(host.label())
+			}
+			results[i] = runHost(ctx, host, keys, cmd, opts.Timeout, runOpts)
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// runHost 连接一台主机并执行命令，超时时间包括连接和执行
+func runHost(ctx context.Context, host Host, keys *sshclient.KeyCache, cmd string, timeout time.Duration, runOpts *sshclient.RunOptions) *HostResult {
+	result := &HostResult{Host: host.label()}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client, err := connect(ctx, host.Config, keys)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer client.Close()
+
+	result.Result, result.Err = client.Run(ctx, cmd, runOpts)
+	return result
+}
+
+// Group 是输出和退出状态都相同的一组主机
+type Group struct {
+	Hosts    []string // 主机的显示名称
+	Stdout   []byte   // 标准输出
+	Stderr   []byte   // 标准错误
+	ExitCode int      // 退出码，连接失败时为 -1
+	Err      error    // 连接失败、超时等错误，组内所有主机的错误信息相同
+}
+
+// Summarize 把输出、退出码和错误都相同的主机合并成一组
+// 参数:
+//   results: Run 返回的结果
+// 返回值:
+//   []*Group: 分组结果，按每组第一台主机在 results 中的顺序排列
+func Summarize(results []*HostResult) []*Group {
+	var groups []*Group
+	index := make(map[string]*Group)
+	for _, r := range results {
+		g := &Group{ExitCode: -1, Err: r.Err}
+		if r.Result != nil {
+			g.Stdout, g.Stderr, g.ExitCode = r.Result.Stdout, r.Result.Stderr, r.Result.ExitCode
+		}
+		errText := ""
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		key := fmt.Sprintf("%d\x00%q\x00%q\x00%q", g.ExitCode, g.Stdout, g.Stderr, errText)
+
+		if existing, ok := index[key]; ok {
+			existing.Hosts = append(existing.Hosts, r.Host)
+			continue
+		}
+		g.Hosts = []string{r.Host}
+		index[key] = g
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// WriteSummary 把分组后的结果写入 w
+// 每组先列出主机，再输出标准输出、标准错误和退出状态
+// 参数:
+//   w: 输出目标
+//   results: Run 返回的结果
+func WriteSummary(w io.Writer, results []*HostResult) {
+	for _, g := range Summarize(results) {
+		fmt.Fprintf(w, "==== %s (%d 台) ====\n", strings.Join(g.Hosts, ", "), len(g.Hosts))
+		writeBlock(w, g.Stdout)
+		if len(g.Stderr) > 0 {
+			fmt.Fprintln(w, "---- 标准错误 ----")
+			writeBlock(w, g.Stderr)
+		}
+		switch {
+		case g.Err != nil:
+			fmt.Fprintf(w, "错误: %v\n", g.Err)
+		case g.ExitCode != 0:
+			fmt.Fprintf(w, "退出码: %d\n", g.ExitCode)
+		}
+	}
+}
+
+// writeBlock 输出一段内容，保证以换行符结尾
+func writeBlock(w io.Writer, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	w.Write(data)
+	if data[len(data)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+}
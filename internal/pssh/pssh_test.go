@@ -0,0 +1,282 @@
+// pssh_test 提供批量执行的单元测试
+// 使用模拟的客户端代替真实的 SSH 连接
+package pssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// fakeRunner 模拟一台主机上的命令执行
+type fakeRunner struct {
+	stdout string
+	stderr string
+	code   int
+	delay  time.Duration
+}
+
+// Run 模拟执行命令，输出写入 RunOptions 中的 Writer 或者收集到结果中
+func (f *fakeRunner) Run(ctx context.Context, cmd string, opts *sshclient.RunOptions) (*sshclient.Result, error) {
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return &sshclient.Result{Command: cmd, ExitCode: -1, TimedOut: true}, ctx.Err()
+	}
+
+	result := &sshclient.Result{Command: cmd, ExitCode: f.code}
+	if opts.Stdout != nil {
+		fmt.Fprint(opts.Stdout, f.stdout)
+	} else {
+		result.Stdout = []byte(f.stdout)
+	}
+	if opts.Stderr != nil {
+		fmt.Fprint(opts.Stderr, f.stderr)
+	} else {
+		result.Stderr = []byte(f.stderr)
+	}
+	return result, nil
+}
+
+// Close 实现 runner 接口
+func (f *fakeRunner) Close() error { return nil }
+
+// useFakeHosts 用模拟的客户端替换真实连接，Host 为 "down" 的主机连接失败
+// 返回值:
+//   *int64: 当前正在执行的主机数的最大值
+func useFakeHosts(t *testing.T, runners map[string]*fakeRunner) *int64 {
+	t.Helper()
+	var running, peak int64
+	orig := connect
+	connect = func(ctx context.Context, cfg *config.SSHConfig, keys *sshclient.KeyCache) (runner, error) {
+		if cfg.Host == "down" {
+			return nil, errors.New("连接被拒绝")
+		}
+		n := atomic.AddInt64(&running, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		return &countingRunner{fakeRunner: runners[cfg.Host], running: &running}, nil
+	}
+	t.Cleanup(func() { connect = orig })
+	return &peak
+}
+
+// countingRunner 在关闭时减少正在执行的主机数
+type countingRunner struct {
+	*fakeRunner
+	running *int64
+}
+
+// Close 实现 runner 接口
+func (c *countingRunner) Close() error {
+	atomic.AddInt64(c.running, -1)
+	return nil
+}
+
+// hostsOf 根据名称创建主机列表
+func hostsOf(names ...string) []Host {
+	var hosts []Host
+	for _, name := range names {
+		hosts = append(hosts, Host{Name: name, Config: &config.SSHConfig{Host: name}})
+	}
+	return hosts
+}
+
+// TestRun 测试结果顺序、失败判断和每台主机的超时
+func TestRun(t *testing.T) {
+	useFakeHosts(t, map[string]*fakeRunner{
+		"web1": {stdout: "ok\n"},
+		"web2": {stdout: "no match\n", code: 1},
+		"slow": {stdout: "late\n", delay: 5 * time.Second},
+	})
+
+	start := time.Now()
+	results := Run(context.Background(), hostsOf("web1", "web2", "down", "slow"), "uptime", Options{Timeout: 200 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("超时的主机应该被中止，耗时 %v", elapsed)
+	}
+
+	tests := []struct {
+		host       string
+		wantFailed bool
+		wantErr    error
+		wantStdout string
+	}{
+		{host: "web1", wantStdout: "ok\n"},
+		{host: "web2", wantFailed: true, wantStdout: "no match\n"},
+		{host: "down", wantFailed: true},
+		{host: "slow", wantFailed: true, wantErr: context.DeadlineExceeded},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("结果数量 = %d, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			r := results[i]
+			if r.Host != tt.host {
+				t.Fatalf("results[%d].Host = %s, want %s", i, r.Host, tt.host)
+			}
+			if r.Failed() != tt.wantFailed {
+				t.Errorf("Failed() = %v, want %v (err = %v)", r.Failed(), tt.wantFailed, r.Err)
+			}
+			if tt.wantErr != nil && !errors.Is(r.Err, tt.wantErr) {
+				t.Errorf("Err = %v, want %v", r.Err, tt.wantErr)
+			}
+			if r.Result != nil && string(r.Result.Stdout) != tt.wantStdout {
+				t.Errorf("Stdout = %q, want %q", r.Result.Stdout, tt.wantStdout)
+			}
+		})
+	}
+}
+
+// TestRun_Concurrency 测试同时执行的主机数不超过上限
+func TestRun_Concurrency(t *testing.T) {
+	runners := make(map[string]*fakeRunner)
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("host%02d", i)
+		names = append(names, name)
+		runners[name] = &fakeRunner{stdout: "ok\n", delay: 20 * time.Millisecond}
+	}
+	peak := useFakeHosts(t, runners)
+
+	results := Run(context.Background(), hostsOf(names...), "true", Options{Concurrency: 3})
+	for _, r := range results {
+		if r.Failed() {
+			t.Errorf("%s 执行失败: %v", r.Host, r.Err)
+		}
+	}
+	if got := atomic.LoadInt64(peak); got > 3 || got < 1 {
+		t.Errorf("同时执行的主机数最大为 %d, want 1-3", got)
+	}
+}
+
+// TestRun_PreloadKeys 测试并发连接之前只解析一次私钥，私钥无法使用时不连接这些主机
+func TestRun_PreloadKeys(t *testing.T) {
+	badKey := filepath.Join(t.TempDir(), "id_bad")
+	if err := os.WriteFile(badKey, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("写入私钥失败: %v", err)
+	}
+
+	var mu sync.Mutex
+	var connected []string
+	shared := make(map[*sshclient.KeyCache]bool)
+	orig := connect
+	connect = func(ctx context.Context, cfg *config.SSHConfig, keys *sshclient.KeyCache) (runner, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		connected = append(connected, cfg.Host)
+		shared[keys] = true
+		return &fakeRunner{stdout: "ok\n"}, nil
+	}
+	t.Cleanup(func() { connect = orig })
+
+	hosts := hostsOf("web1", "web2", "web3")
+	hosts[0].Config.KeyFile = badKey
+	hosts[2].Config.KeyFile = badKey
+	results := Run(context.Background(), hosts, "uptime", Options{})
+
+	for _, i := range []int{0, 2} {
+		var keyErr *sshclient.PrivateKeyError
+		if !errors.As(results[i].Err, &keyErr) {
+			t.Errorf("%s 的错误 = %v, want *sshclient.PrivateKeyError", results[i].Host, results[i].Err)
+		}
+	}
+	if results[1].Failed() {
+		t.Errorf("web2 执行失败: %v", results[1].Err)
+	}
+	if strings.Join(connected, ",") != "web2" {
+		t.Errorf("连接的主机 = %v, want [web2]", connected)
+	}
+	if len(shared) != 1 || shared[nil] {
+		t.Errorf("连接应该共用同一个私钥缓存，got %v", shared)
+	}
+}
+
+// TestRun_LiveOutput 测试实时输出带上主机名前缀，并且各行不会交错
+func TestRun_LiveOutput(t *testing.T) {
+	useFakeHosts(t, map[string]*fakeRunner{
+		"web1": {stdout: "line1\nline2\n", stderr: "warn\n"},
+		"web2": {stdout: "line1\n"},
+	})
+
+	var buf syncBuffer
+	results := Run(context.Background(), hostsOf("web1", "web2"), "deploy", Options{Output: &buf})
+	for _, r := range results {
+		if len(r.Result.Stdout) != 0 {
+			t.Errorf("实时输出时不应该收集输出，got %q", r.Result.Stdout)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+	want := []string{"[web1] line1", "[web1] line2", "[web1] warn", "[web2] line1"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("输出的行 = %q, want %q", lines, want)
+	}
+}
+
+// TestWriteSummary 测试相同的输出合并成一组
+func TestWriteSummary(t *testing.T) {
+	results := []*HostResult{
+		{Host: "web1", Result: &sshclient.Result{Stdout: []byte("v1.2\n")}},
+		{Host: "web2", Result: &sshclient.Result{Stdout: []byte("v1.1\n")}},
+		{Host: "web3", Result: &sshclient.Result{Stdout: []byte("v1.2\n")}},
+		{Host: "web4", Result: &sshclient.Result{Stderr: []byte("not found"), ExitCode: 127}},
+		{Host: "web5", Err: errors.New("连接被拒绝")},
+	}
+
+	groups := Summarize(results)
+	if len(groups) != 4 {
+		t.Fatalf("Summarize() 返回 %d 组, want 4", len(groups))
+	}
+	if got := strings.Join(groups[0].Hosts, ","); got != "web1,web3" {
+		t.Errorf("第一组的主机 = %s, want web1,web3", got)
+	}
+
+	var buf bytes.Buffer
+	WriteSummary(&buf, results)
+	want := "==== web1, web3 (2 台) ====\nv1.2\n" +
+		"==== web2 (1 台) ====\nv1.1\n" +
+		"==== web4 (1 台) ====\n---- 标准错误 ----\nnot found\n退出码: 127\n" +
+		"==== web5 (1 台) ====\n错误: 连接被拒绝\n"
+	if buf.String() != want {
+		t.Errorf("WriteSummary() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+// syncBuffer 是可以并发写入的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write 实现 io.Writer 接口
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String 返回已经写入的内容
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
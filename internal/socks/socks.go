@@ -0,0 +1,302 @@
+// Package socks 实现了 SOCKS5 和 SOCKS4a 代理协议的服务端
+// 只支持 CONNECT 命令，目标地址交给调用方提供的 Dialer 连接
+// 配合 SSH 连接使用时，域名会原样交给服务器解析，实现动态端口转发（-D）
+package socks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 协议版本号
+const (
+	version4 = 0x04
+	version5 = 0x05
+)
+
+// SOCKS5 协议常量（RFC 1928）
+const (
+	authNone         = 0x00 // 无需认证
+	authNoAcceptable = 0xff // 没有可接受的认证方式
+
+	cmdConnect = 0x01 // CONNECT 命令
+
+	atypIPv4   = 0x01 // IPv4 地址
+	atypDomain = 0x03 // 域名
+	atypIPv6   = 0x04 // IPv6 地址
+)
+
+// SOCKS5 应答码
+const (
+	replySucceeded           = 0x00
+	replyGeneralFailure      = 0x01
+	replyNotAllowed          = 0x02
+	replyConnectionRefused   = 0x05
+	replyCommandNotSupported = 0x07
+	replyAddrNotSupported    = 0x08
+)
+
+// SOCKS4 应答码
+const (
+	reply4Granted  = 0x5a
+	reply4Rejected = 0x5b
+)
+
+// HandshakeTimeout 是完成 SOCKS 握手的最长时间，防止客户端连接后一直不发送请求
+var HandshakeTimeout = 30 * time.Second
+
+// Dialer 用于连接代理目标地址
+// *ssh.Client 实现了这个接口，目标由 SSH 服务器连接，域名也由服务器解析
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// Request 表示一个已经解析的 CONNECT 请求
+type Request struct {
+	Version byte   // 协议版本，4 或 5
+	Target  string // 目标地址，格式为 "host:port"，host 可能是域名
+
+	conn net.Conn // 客户端连接
+}
+
+// ReadRequest 在客户端连接上完成握手并读取 CONNECT 请求
+// 根据第一个字节自动识别 SOCKS5 或 SOCKS4/4a
+// 参数:
+//   conn: 客户端连接
+// 返回值:
+//   *Request: 解析后的请求，调用方连接目标后需要调用 Reply 应答
+//   error: 如果握手失败或请求不受支持则返回错误，此时已经向客户端发送了失败应答
+func ReadRequest(conn net.Conn) (*Request, error) {
+	var ver [1]byte
+	if _, err := io.ReadFull(conn, ver[:]); err != nil {
+		return nil, fmt.Errorf("读取 SOCKS 版本失败: %w", err)
+	}
+
+	switch ver[0] {
+	case version5:
+		return readRequest5(conn)
+	case version4:
+		return readRequest4(conn)
+	default:
+		return nil, fmt.Errorf("不支持的 SOCKS 版本: %d", ver[0])
+	}
+}
+
+// readRequest5 读取 SOCKS5 请求，版本号已经读取
+func readRequest5(conn net.Conn) (*Request, error) {
+	// 认证方式协商：VER NMETHODS METHODS，只支持无需认证
+	var n [1]byte
+	if _, err := io.ReadFull(conn, n[:]); err != nil {
+		return nil, fmt.Errorf("读取认证方式失败: %w", err)
+	}
+	methods := make([]byte, n[0])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, fmt.Errorf("读取认证方式失败: %w", err)
+	}
+	if !hasByte(methods, authNone) {
+		conn.Write([]byte{version5, authNoAcceptable})
+		return nil, errors.New("客户端不支持无认证方式")
+	}
+	if _, err := conn.Write([]byte{version5, authNone}); err != nil {
+		return nil, fmt.Errorf("发送认证应答失败: %w", err)
+	}
+
+	// 请求：VER CMD RSV ATYP DST.ADDR DST.PORT
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, fmt.Errorf("读取请求失败: %w", err)
+	}
+	if header[0] != version5 {
+		return nil, fmt.Errorf("请求中的 SOCKS 版本无效: %d", header[0])
+	}
+
+	var host string
+	switch header[3] {
+	case atypIPv4, atypIPv6:
+		size := net.IPv4len
+		if header[3] == atypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, fmt.Errorf("读取目标地址失败: %w", err)
+		}
+		host = ip.String()
+	case atypDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, fmt.Errorf("读取目标域名失败: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, fmt.Errorf("读取目标域名失败: %w", err)
+		}
+		host = string(domain)
+	default:
+		writeReply5(conn, replyAddrNotSupported)
+		return nil, fmt.Errorf("不支持的地址类型: %d", header[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return nil, fmt.Errorf("读取目标端口失败: %w", err)
+	}
+
+	if header[1] != cmdConnect {
+		writeReply5(conn, replyCommandNotSupported)
+		return nil, fmt.Errorf("不支持的 SOCKS 命令: %d", header[1])
+	}
+
+	return &Request{
+		Version: version5,
+		Target:  net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))),
+		conn:    conn,
+	}, nil
+}
+
+// readRequest4 读取 SOCKS4/4a 请求，版本号已经读取
+// 请求格式：VN CD DSTPORT DSTIP USERID NULL [DOMAIN NULL]
+// DSTIP 为 0.0.0.x（x 不为 0）时是 SOCKS4a，目标域名跟在 USERID 之后
+func readRequest4(conn net.Conn) (*Request, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, fmt.Errorf("读取请求失败: %w", err)
+	}
+	port := binary.BigEndian.Uint16(header[1:3])
+	ip := net.IP(header[3:7])
+
+	// 用户名不做校验，只需要读完
+	if _, err := readString(conn); err != nil {
+		return nil, fmt.Errorf("读取用户名失败: %w", err)
+	}
+
+	host := ip.String()
+	if ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+		domain, err := readString(conn)
+		if err != nil {
+			return nil, fmt.Errorf("读取目标域名失败: %w", err)
+		}
+		host = domain
+	}
+
+	if header[0] != cmdConnect {
+		writeReply4(conn, reply4Rejected)
+		return nil, fmt.Errorf("不支持的 SOCKS 命令: %d", header[0])
+	}
+
+	return &Request{
+		Version: version4,
+		Target:  net.JoinHostPort(host, strconv.Itoa(int(port))),
+		conn:    conn,
+	}, nil
+}
+
+// Reply 向客户端发送请求的处理结果
+// 参数:
+//   err: 连接目标的错误，为 nil 时表示成功
+// 返回值:
+//   error: 如果发送应答失败则返回错误
+func (r *Request) Reply(err error) error {
+	if r.Version == version4 {
+		code := byte(reply4Granted)
+		if err != nil {
+			code = reply4Rejected
+		}
+		return writeReply4(r.conn, code)
+	}
+	return writeReply5(r.conn, replyCode(err))
+}
+
+// Connect 处理一个 SOCKS 客户端连接：完成握手，连接目标地址并应答
+// 参数:
+//   conn: 客户端连接
+//   dialer: 用于连接目标地址，通常是 *ssh.Client
+// 返回值:
+//   net.Conn: 到目标地址的连接，调用方负责在两个连接之间复制数据
+//   error: 如果握手或连接目标失败则返回错误
+func Connect(conn net.Conn, dialer Dialer) (net.Conn, error) {
+	// 握手阶段设置超时，完成后恢复
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	req, err := ReadRequest(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := dialer.Dial("tcp", req.Target)
+	if err != nil {
+		req.Reply(err)
+		return nil, fmt.Errorf("连接 %s 失败: %w", req.Target, err)
+	}
+	if err := req.Reply(nil); err != nil {
+		target.Close()
+		return nil, fmt.Errorf("发送应答失败: %w", err)
+	}
+	return target, nil
+}
+
+// replyCode 把连接目标的错误转换为 SOCKS5 应答码
+// SSH 服务器拒绝通道时根据拒绝原因区分
+func replyCode(err error) byte {
+	if err == nil {
+		return replySucceeded
+	}
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) {
+		switch openErr.Reason {
+		case ssh.Prohibited:
+			return replyNotAllowed
+		case ssh.ConnectionFailed:
+			return replyConnectionRefused
+		}
+	}
+	return replyGeneralFailure
+}
+
+// writeReply5 发送 SOCKS5 应答，绑定地址固定为 0.0.0.0:0
+func writeReply5(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{version5, code, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// writeReply4 发送 SOCKS4 应答
+func writeReply4(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{0x00, code, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// readString 读取以 NULL 结尾的字符串，最长 255 字节
+func readString(r io.Reader) (string, error) {
+	var buf []byte
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return "", err
+		}
+		if b[0] == 0 {
+			return string(buf), nil
+		}
+		if len(buf) >= 255 {
+			return "", errors.New("字符串过长")
+		}
+		buf = append(buf, b[0])
+	}
+}
+
+// hasByte 检查字节切片中是否包含指定的值
+func hasByte(list []byte, b byte) bool {
+	for _, v := range list {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+// socks_test 提供 SOCKS 代理协议的单元测试
+// 通过本地 HTTP 服务验证 CONNECT 之后的数据传输
+package socks
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// recordingDialer 记录收到的目标地址，并直接通过本地网络连接
+type recordingDialer struct {
+	mu      sync.Mutex
+	targets []string
+	err     error // 不为 nil 时所有连接都返回这个错误
+}
+
+// Dial 实现 Dialer 接口
+func (d *recordingDialer) Dial(network, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.targets = append(d.targets, addr)
+	d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return net.Dial(network, addr)
+}
+
+// Targets 返回收到的目标地址
+func (d *recordingDialer) Targets() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.targets...)
+}
+
+// startProxy 启动使用指定 Dialer 的 SOCKS 代理
+// 返回值:
+//   string: 代理监听地址
+func startProxy(t *testing.T, dialer Dialer) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动代理失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				target, err := Connect(conn, dialer)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startHTTPTarget 启动一个返回固定内容的 HTTP 服务
+// 返回值:
+//   int: 服务监听的端口
+func startHTTPTarget(t *testing.T) int {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello from target")
+	}))
+	t.Cleanup(server.Close)
+	_, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
+// socks5Request 构造 SOCKS5 握手和 CONNECT 请求
+func socks5Request(cmd, atyp byte, addr []byte, port int) []byte {
+	req := []byte{version5, 1, authNone, version5, cmd, 0x00, atyp}
+	if atyp == atypDomain {
+		req = append(req, byte(len(addr)))
+	}
+	req = append(req, addr...)
+	return binary.BigEndian.AppendUint16(req, uint16(port))
+}
+
+// socks4Request 构造 SOCKS4 请求，domain 不为空时使用 SOCKS4a
+func socks4Request(cmd byte, ip net.IP, domain string, port int) []byte {
+	req := []byte{version4, cmd}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if domain != "" {
+		ip = net.IPv4(0, 0, 0, 1)
+	}
+	req = append(req, ip.To4()...)
+	req = append(req, "user\x00"...)
+	if domain != "" {
+		req = append(req, domain+"\x00"...)
+	}
+	return req
+}
+
+// TestConnect 测试 SOCKS5、SOCKS4 和 SOCKS4a 的 CONNECT 请求
+func TestConnect(t *testing.T) {
+	port := startHTTPTarget(t)
+
+	tests := []struct {
+		name       string
+		request    []byte
+		replyLen   int    // 期望的应答长度（SOCKS5 包含认证应答）
+		reply      []byte // 期望的应答前缀
+		wantTarget string // Dialer 收到的目标地址
+	}{
+		{
+			name:       "SOCKS5 IPv4 地址",
+			request:    socks5Request(cmdConnect, atypIPv4, net.IPv4(127, 0, 0, 1).To4(), port),
+			replyLen:   12,
+			reply:      []byte{version5, authNone, version5, replySucceeded},
+			wantTarget: fmt.Sprintf("127.0.0.1:%d", port),
+		},
+		{
+			name:       "SOCKS5 域名不在本地解析",
+			request:    socks5Request(cmdConnect, atypDomain, []byte("localhost"), port),
+			replyLen:   12,
+			reply:      []byte{version5, authNone, version5, replySucceeded},
+			wantTarget: fmt.Sprintf("localhost:%d", port),
+		},
+		{
+			name:       "SOCKS4 IPv4 地址",
+			request:    socks4Request(cmdConnect, net.IPv4(127, 0, 0, 1), "", port),
+			replyLen:   8,
+			reply:      []byte{0x00, reply4Granted},
+			wantTarget: fmt.Sprintf("127.0.0.1:%d", port),
+		},
+		{
+			name:       "SOCKS4a 域名",
+			request:    socks4Request(cmdConnect, nil, "localhost", port),
+			replyLen:   8,
+			reply:      []byte{0x00, reply4Granted},
+			wantTarget: fmt.Sprintf("localhost:%d", port),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &recordingDialer{}
+			conn, err := net.Dial("tcp", startProxy(t, dialer))
+			if err != nil {
+				t.Fatalf("连接代理失败: %v", err)
+			}
+			defer conn.Close()
+
+			if _, err := conn.Write(tt.request); err != nil {
+				t.Fatalf("发送请求失败: %v", err)
+			}
+			reply := make([]byte, tt.replyLen)
+			if _, err := io.ReadFull(conn, reply); err != nil {
+				t.Fatalf("读取应答失败: %v", err)
+			}
+			if string(reply[:len(tt.reply)]) != string(tt.reply) {
+				t.Fatalf("应答 = %v, want 前缀 %v", reply, tt.reply)
+			}
+
+			// 通过代理发送 HTTP 请求
+			fmt.Fprintf(conn, "GET / HTTP/1.0\r\nHost: target\r\n\r\n")
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("读取 HTTP 响应失败: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "hello from target" {
+				t.Errorf("HTTP 响应 = %q, want %q", body, "hello from target")
+			}
+
+			if got := dialer.Targets(); len(got) != 1 || got[0] != tt.wantTarget {
+				t.Errorf("Dialer 收到的目标 = %v, want [%s]", got, tt.wantTarget)
+			}
+		})
+	}
+}
+
+// TestConnect_Errors 测试不受支持的请求和目标连接失败时的应答
+func TestConnect_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialErr error
+		request []byte
+		reply   []byte // 期望的完整应答前缀
+	}{
+		{
+			name:    "不支持的认证方式",
+			request: []byte{version5, 1, 0x02},
+			reply:   []byte{version5, authNoAcceptable},
+		},
+		{
+			name:    "SOCKS5 不支持 BIND",
+			request: socks5Request(0x02, atypIPv4, net.IPv4(127, 0, 0, 1).To4(), 80),
+			reply:   []byte{version5, authNone, version5, replyCommandNotSupported},
+		},
+		{
+			name:    "SOCKS5 不支持的地址类型",
+			request: []byte{version5, 1, authNone, version5, cmdConnect, 0x00, 0x09},
+			reply:   []byte{version5, authNone, version5, replyAddrNotSupported},
+		},
+		{
+			name:    "SOCKS4 不支持 BIND",
+			request: socks4Request(0x02, net.IPv4(127, 0, 0, 1), "", 80),
+			reply:   []byte{0x00, reply4Rejected},
+		},
+		{
+			name:    "SSH 服务器禁止连接",
+			dialErr: &ssh.OpenChannelError{Reason: ssh.Prohibited, Message: "denied"},
+			request: socks5Request(cmdConnect, atypDomain, []byte("example.com"), 80),
+			reply:   []byte{version5, authNone, version5, replyNotAllowed},
+		},
+		{
+			name:    "SSH 服务器连接目标失败",
+			dialErr: &ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "refused"},
+			request: socks5Request(cmdConnect, atypDomain, []byte("example.com"), 80),
+			reply:   []byte{version5, authNone, version5, replyConnectionRefused},
+		},
+		{
+			name:    "其他连接错误",
+			dialErr: errors.New("连接已断开"),
+			request: socks5Request(cmdConnect, atypDomain, []byte("example.com"), 80),
+			reply:   []byte{version5, authNone, version5, replyGeneralFailure},
+		},
+		{
+			name:    "SOCKS4a 连接失败",
+			dialErr: errors.New("连接已断开"),
+			request: socks4Request(cmdConnect, nil, "example.com", 80),
+			reply:   []byte{0x00, reply4Rejected},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", startProxy(t, &recordingDialer{err: tt.dialErr}))
+			if err != nil {
+				t.Fatalf("连接代理失败: %v", err)
+			}
+			defer conn.Close()
+
+			if _, err := conn.Write(tt.request); err != nil {
+				t.Fatalf("发送请求失败: %v", err)
+			}
+			reply := make([]byte, len(tt.reply))
+			if _, err := io.ReadFull(conn, reply); err != nil {
+				t.Fatalf("读取应答失败: %v", err)
+			}
+			if string(reply) != string(tt.reply) {
+				t.Errorf("应答 = %v, want %v", reply, tt.reply)
+			}
+
+			// 失败后代理应该关闭连接
+			if _, err := io.ReadAll(conn); err != nil {
+				t.Errorf("等待连接关闭失败: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package sshclient 的 ssh-agent 认证模块
+// 通过 SSH_AUTH_SOCK 指向的 Unix 套接字与本地 ssh-agent 通信
+// 私钥始终保存在 agent 中，不需要出现在磁盘上
+package sshclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrAgentUnavailable 表示没有可用的 ssh-agent（未设置 SSH_AUTH_SOCK）
+var ErrAgentUnavailable = errors.New("未设置 SSH_AUTH_SOCK 环境变量，无法连接 ssh-agent")
+
+// dialAgent 连接 SSH_AUTH_SOCK 指向的 ssh-agent
+// 返回值:
+//   net.Conn: 与 agent 的连接，认证结束后需要关闭
+//   error: 如果 agent 不可用或连接失败则返回错误
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, ErrAgentUnavailable
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("连接 ssh-agent 失败: %w", err)
+	}
+	return conn, nil
+}
+
+// agentSigners 返回读取 agent 中全部身份的函数
+// 认证时会依次尝试 agent 中保存的每一个身份
+// 参数:
+//   conn: 与 ssh-agent 的连接
+// 返回值:
+//   func() ([]ssh.Signer, error): 每次调用时从 agent 读取身份
+func agentSigners(conn net.Conn) func() ([]ssh.Signer, error) {
+	return agent.NewClient(conn).Signers
+}
@@ -0,0 +1,92 @@
+// agent_test 提供 ssh-agent 认证的单元测试
+// 使用内存中的 keyring 模拟 ssh-agent
+package sshclient
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"gossh/internal/config"
+)
+
+// startTestAgent 在临时 Unix 套接字上启动 ssh-agent，并设置 SSH_AUTH_SOCK
+// 参数:
+//   t: 测试对象
+//   keys: agent 中保存的私钥
+func startTestAgent(t *testing.T, keys ...interface{}) {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatalf("向 agent 添加密钥失败: %v", err)
+		}
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("监听 agent 套接字失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+// TestAddAuthMethods_AgentUnavailable 测试未设置 SSH_AUTH_SOCK 的情况
+func TestAddAuthMethods_AgentUnavailable(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	sshConfig := &ssh.ClientConfig{}
+	_, err := addAuthMethods(sshConfig, &config.SSHConfig{UseAgent: true}, nil)
+	if !errors.Is(err, ErrAgentUnavailable) {
+		t.Errorf("addAuthMethods() error = %v, want ErrAgentUnavailable", err)
+	}
+}
+
+// TestNewClient_AgentAuth 测试使用 agent 中的密钥完成认证
+func TestNewClient_AgentAuth(t *testing.T) {
+	// agent 中保存两个身份，只有第二个被服务器接受
+	_, otherKey := mustGenerateKey(t)
+	authorized, authorizedKey := mustGenerateKey(t)
+	startTestAgent(t, otherKey, authorizedKey)
+
+	server := startTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("未授权的密钥")
+		},
+	})
+
+	client, err := NewClient(&config.SSHConfig{
+		Host:          server.Host(),
+		Port:          server.Port(),
+		Username:      "tester",
+		UseAgent:      true,
+		HostKeyPolicy: config.HostKeyOff,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+}
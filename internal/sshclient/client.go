@@ -4,9 +4,11 @@
 package sshclient
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"time"
+	"io"
+	"net"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 
@@ -18,84 +20,262 @@ import (
 type Client struct {
 	config *config.SSHConfig // SSH 连接配置
 	conn   *ssh.Client       // SSH 连接对象
+	jumps  []*ssh.Client     // 跳板机连接，按连接顺序排列
+
+	mu       sync.Mutex // 保护 forwards
+	forwards []*Forward // 正在运行的端口转发
+
+	state     int32         // 连接健康状态，见 ConnState
+	lastAlive int64         // 最近一次确认服务器有响应的时间（UnixNano）
+	done      chan struct{} // 连接断开时关闭
 }
 
 // NewClient 创建一个新的 SSH 客户端
-// 根据提供的配置信息建立 SSH 连接
+// 根据提供的配置信息建立 SSH 连接，配置了跳板机时依次经过每一跳
 // 参数:
 //   cfg: SSH 连接配置信息
 // 返回值:
 //   *Client: 创建的客户端对象
 //   error: 如果连接失败则返回错误信息
 func NewClient(cfg *config.SSHConfig) (*Client, error) {
+	return NewClientContext(context.Background(), cfg)
+}
+
+// NewClientContext 创建一个新的 SSH 客户端，上下文取消时中止连接
+// 每一跳的 TCP 连接和 SSH 握手都受 cfg.ConnectTimeout 限制
+// 参数:
+//   ctx: 上下文，取消时中止正在进行的连接和握手
+//   cfg: SSH 连接配置信息
+// 返回值:
+//   *Client: 创建的客户端对象
+//   error: 如果连接失败、超时或上下文取消则返回错误信息
+func NewClientContext(ctx context.Context, cfg *config.SSHConfig) (*Client, error) {
+	return NewClientWithKeys(ctx, cfg, nil)
+}
+
+// NewClientWithKeys 创建一个新的 SSH 客户端，私钥从 keys 中读取
+// 用于并发连接多台主机，多个连接共用已经解析的私钥，不会同时提示输入密码
+// 参数:
+//   ctx: 上下文，取消时中止正在进行的连接和握手
+//   cfg: SSH 连接配置信息
+//   keys: 私钥缓存，为 nil 时每次连接都读取私钥文件
+// 返回值:
+//   *Client: 创建的客户端对象
+//   error: 如果连接失败、超时或上下文取消则返回错误信息
+func NewClientWithKeys(ctx context.Context, cfg *config.SSHConfig, keys *KeyCache) (*Client, error) {
 	// 验证配置信息是否有效
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %w", err)
 	}
 
+	// 依次连接每一个跳板机，后一跳通过前一跳建立 TCP 连接
+	var via *ssh.Client
+	var jumps []*ssh.Client
+	for _, hop := range cfg.JumpHosts {
+		jump, err := dial(ctx, via, hop, keys)
+		if err != nil {
+			closeAll(jumps)
+			return nil, fmt.Errorf("连接跳板机 %s 失败: %w", hop.GetAddress(), err)
+		}
+		jumps = append(jumps, jump)
+		via = jump
+	}
+
+	// 建立到目标主机的 SSH 连接
+	conn, err := dial(ctx, via, cfg, keys)
+	if err != nil {
+		closeAll(jumps)
+		return nil, err
+	}
+
+	// 创建客户端对象
+	client := &Client{
+		config: cfg,
+		conn:   conn,
+		jumps:  jumps,
+	}
+
+	// 监控连接状态，配置了保活间隔时定期发送保活请求
+	client.startMonitor()
+
+	return client, nil
+}
+
+// NewClientFromConn 用已经建立好的 SSH 连接创建客户端
+// 用于连接不是由 NewClient 建立的情况，例如通过连接复用的主连接打开的连接
+// 参数:
+//   cfg: 连接对应的配置，用于显示连接信息和保活参数
+//   conn: 已经完成握手的 SSH 连接，由返回的客户端负责关闭
+// 返回值:
+//   *Client: 创建的客户端对象
+func NewClientFromConn(cfg *config.SSHConfig, conn *ssh.Client) *Client {
+	client := &Client{
+		config: cfg,
+		conn:   conn,
+	}
+	client.startMonitor()
+	return client
+}
+
+// dial 建立单个 SSH 连接并完成认证
+// 参数:
+//   ctx: 上下文，取消时中止连接和握手
+//   via: 上一跳的 SSH 连接，为 nil 时直接通过网络连接
+//   cfg: 本次要连接的主机配置
+//   keys: 私钥缓存，为 nil 时直接读取私钥文件
+// 返回值:
+//   *ssh.Client: 建立好的 SSH 连接
+//   error: 如果连接或认证失败则返回错误信息
+func dial(ctx context.Context, via *ssh.Client, cfg *config.SSHConfig, keys *KeyCache) (*ssh.Client, error) {
+	// 根据配置的策略创建主机密钥校验器
+	verifier, err := newHostKeyVerifier(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("配置主机密钥校验失败: %w", err)
+	}
+
 	// 创建 SSH 客户端配置
+	timeout := cfg.GetConnectTimeout()
 	sshConfig := &ssh.ClientConfig{
-		User:            cfg.Username,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 注意：生产环境应该验证主机密钥
-		Timeout:         30 * time.Second,            // 连接超时时间
+		User:              cfg.Username,
+		HostKeyCallback:   verifier.Callback(),                        // 校验服务器身份
+		HostKeyAlgorithms: verifier.KnownAlgorithms(cfg.GetAddress()), // 优先使用已记录的密钥类型
+		Timeout:           timeout,                                    // 连接超时时间
 	}
 
 	// 根据配置添加认证方式
-	if err := addAuthMethods(sshConfig, cfg); err != nil {
+	agentConn, err := addAuthMethods(sshConfig, cfg, keys)
+	if err != nil {
 		return nil, fmt.Errorf("配置认证方式失败: %w", err)
 	}
+	if agentConn != nil {
+		defer agentConn.Close() // 认证完成后不再需要 agent 连接
+	}
 
-	// 建立 SSH 连接
-	conn, err := ssh.Dial("tcp", cfg.GetAddress(), sshConfig)
+	// 认证方式准备好之后再开始计时，输入私钥密码的时间不计入连接超时
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 没有跳板机时直接连接，否则通过上一跳打开到目标地址的 TCP 通道
+	var netConn net.Conn
+	if via == nil {
+		var dialer net.Dialer
+		netConn, err = dialer.DialContext(ctx, "tcp", cfg.GetAddress())
+		if err != nil {
+			return nil, fmt.Errorf("SSH 连接失败: %w", err)
+		}
+	} else {
+		netConn, err = via.DialContext(ctx, "tcp", cfg.GetAddress())
+		if err != nil {
+			return nil, fmt.Errorf("通过跳板机连接 %s 失败: %w", cfg.GetAddress(), err)
+		}
+	}
+
+	// 握手期间上下文取消或超时时关闭连接，让握手立即返回
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(netConn, cfg.GetAddress(), sshConfig)
+	if !stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, fmt.Errorf("SSH 连接失败: %w", ctx.Err())
+	}
 	if err != nil {
+		netConn.Close()
 		return nil, fmt.Errorf("SSH 连接失败: %w", err)
 	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-	// 创建客户端对象
-	client := &Client{
-		config: cfg,
-		conn:   conn,
+// closeAll 按相反顺序关闭一组 SSH 连接
+// 后建立的连接依赖先建立的连接，所以要先关闭后面的
+func closeAll(conns []*ssh.Client) {
+	for i := len(conns) - 1; i >= 0; i-- {
+		conns[i].Close()
 	}
-
-	return client, nil
 }
 
 // addAuthMethods 为 SSH 配置添加认证方式
-// 支持密码认证和密钥认证
+// 支持 ssh-agent 认证、密钥认证和密码认证，按此顺序尝试
+// agent 中的身份和密钥文件合并成一个公钥认证方式，同一种认证方式只会尝试一次，
+// 分开添加时 agent 中的密钥都被拒绝后不会再尝试密钥文件
 // 参数:
 //   sshConfig: SSH 客户端配置对象
 //   cfg: 用户提供的配置信息
+//   keys: 私钥缓存，为 nil 时直接读取私钥文件
 // 返回值:
+//   io.Closer: 启用 agent 认证时返回 agent 连接，握手结束后由调用方关闭；否则为 nil
 //   error: 如果配置认证方式失败则返回错误
-func addAuthMethods(sshConfig *ssh.ClientConfig, cfg *config.SSHConfig) error {
+func addAuthMethods(sshConfig *ssh.ClientConfig, cfg *config.SSHConfig, keys *KeyCache) (io.Closer, error) {
 	var authMethods []ssh.AuthMethod
+	var agentConn io.Closer
+	var sources []func() ([]ssh.Signer, error)
 
-	// 如果配置了密码，添加密码认证
-	if cfg.HasPasswordAuth() {
-		authMethods = append(authMethods, ssh.Password(cfg.Password))
+	// 如果启用了 ssh-agent，使用 agent 中的全部身份
+	if cfg.HasAgentAuth() {
+		conn, err := dialAgent()
+		if err != nil {
+			return nil, err
+		}
+		agentConn = conn
+		sources = append(sources, agentSigners(conn))
 	}
 
-	// 如果配置了密钥文件，添加密钥认证
-	if cfg.HasKeyAuth() {
-		// 读取私钥文件内容
-		keyData, err := ioutil.ReadFile(cfg.KeyFile)
-		if err != nil {
-			return fmt.Errorf("读取私钥文件失败: %w", err)
+	// 出错时关闭已经打开的 agent 连接
+	fail := func(err error) (io.Closer, error) {
+		if agentConn != nil {
+			agentConn.Close()
 		}
+		return nil, err
+	}
 
-		// 解析私钥
-		signer, err := ssh.ParsePrivateKey(keyData)
+	// 如果配置了密钥文件，在 agent 的身份之后尝试
+	if cfg.HasKeyAuth() {
+		// 读取并解析私钥，加密的私钥会提示输入密码
+		signer, err := keys.signer(cfg)
 		if err != nil {
-			return fmt.Errorf("解析私钥失败: %w", err)
+			return fail(err)
 		}
+		sources = append(sources, func() ([]ssh.Signer, error) {
+			return []ssh.Signer{signer}, nil
+		})
+	}
 
-		// 添加公钥认证方式
-		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	// 添加公钥认证方式
+	if len(sources) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeysCallback(joinSigners(sources)))
+	}
+
+	// 如果配置了密码，添加密码认证
+	if cfg.HasPasswordAuth() {
+		authMethods = append(authMethods, ssh.Password(cfg.Password))
 	}
 
 	// 将认证方式设置到 SSH 配置中
 	sshConfig.Auth = authMethods
-	return nil
+	return agentConn, nil
+}
+
+// joinSigners 把多个身份来源合并成一个，按顺序返回所有来源的身份
+// 某个来源出错时跳过它，只有全部来源都出错时才返回错误
+func joinSigners(sources []func() ([]ssh.Signer, error)) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		var signers []ssh.Signer
+		var firstErr error
+		for _, source := range sources {
+			s, err := source()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			signers = append(signers, s...)
+		}
+		if len(signers) == 0 && firstErr != nil {
+			return nil, firstErr
+		}
+		return signers, nil
+	}
 }
 
 // GetConnection 返回底层的 SSH 连接对象
@@ -114,36 +294,55 @@ func (c *Client) GetConfig() *config.SSHConfig {
 }
 
 // ExecuteCommand 在远程服务器上执行单个命令
-// 执行命令并返回输出结果
+// 执行命令并返回输出结果，是 Run 的简化版本
 // 参数:
 //   command: 要执行的命令字符串
 // 返回值:
-//   string: 命令的输出结果
-//   error: 如果执行失败则返回错误信息
+//   string: 命令的输出结果，标准输出在前，标准错误在后
+//   error: 如果执行失败则返回错误信息；命令以非零状态退出时返回 *ExitError，同时保留输出
 func (c *Client) ExecuteCommand(command string) (string, error) {
-	// 创建一个新的会话
-	session, err := c.conn.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("创建会话失败: %w", err)
+	return c.ExecuteCommandContext(context.Background(), command)
+}
+
+// ExecuteCommandContext 在远程服务器上执行单个命令，上下文取消时终止命令
+// 参数:
+//   ctx: 上下文，取消时向远程命令发送 KILL 信号并关闭会话
+//   command: 要执行的命令字符串
+// 返回值:
+//   string: 命令的输出结果，标准输出在前，标准错误在后
+//   error: 如果执行失败或被取消则返回错误信息；命令以非零状态退出时返回 *ExitError，同时保留输出
+func (c *Client) ExecuteCommandContext(ctx context.Context, command string) (string, error) {
+	result, err := c.Run(ctx, command, nil)
+	if result == nil {
+		return "", err
 	}
-	defer session.Close() // 使用完毕后关闭会话
 
-	// 执行命令并获取输出
-	output, err := session.CombinedOutput(command)
+	// 超时或取消时同样返回已经收到的输出
+	output := string(result.Stdout) + string(result.Stderr)
 	if err != nil {
-		return "", fmt.Errorf("执行命令失败: %w", err)
+		return output, err
 	}
-
-	return string(output), nil
+	if !result.Success() {
+		return output, &ExitError{Result: result}
+	}
+	return output, nil
 }
 
-// Close 关闭 SSH 连接
+// Close 关闭 SSH 连接以及所有跳板机连接
 // 释放网络资源，程序结束前应该调用此方法
 // 返回值:
 //   error: 如果关闭失败则返回错误信息
 func (c *Client) Close() error {
+	// 先停止端口转发，不再接受新连接
+	c.closeForwards()
+
+	var err error
 	if c.conn != nil {
-		return c.conn.Close()
+		err = c.conn.Close()
 	}
-	return nil
+
+	// 关闭目标连接后再关闭整条跳板机链路
+	closeAll(c.jumps)
+	c.jumps = nil
+	return err
 }
\ No newline at end of file
@@ -4,8 +4,15 @@
 package sshclient
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 
@@ -141,7 +148,7 @@ NhAAAAAwEAAQAAAQEA1234567890abcdef
 			}
 			
 			// 测试认证方式配置
-			err := addAuthMethods(sshConfig, tt.config)
+			_, err := addAuthMethods(sshConfig, tt.config, nil)
 			
 			if (err != nil) != tt.wantErr {
 				t.Errorf("addAuthMethods() error = %v, wantErr %v", err, tt.wantErr)
@@ -164,6 +171,40 @@ NhAAAAAwEAAQAAAQEA1234567890abcdef
 	}
 }
 
+// TestNewClient_AgentAndKeyFile 测试 agent 中的密钥被拒绝后继续尝试密钥文件
+func TestNewClient_AgentAndKeyFile(t *testing.T) {
+	_, agentKey := mustGenerateKey(t)
+	startTestAgent(t, agentKey)
+	keyFile, authorized := writeTestKey(t, "")
+
+	var offered int
+	server := startTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			offered++
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("未授权的密钥")
+		},
+	})
+
+	client, err := NewClient(&config.SSHConfig{
+		Host:          server.Host(),
+		Port:          server.Port(),
+		Username:      "tester",
+		KeyFile:       keyFile,
+		UseAgent:      true,
+		HostKeyPolicy: config.HostKeyOff,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+	if offered != 2 {
+		t.Errorf("服务器收到 %d 个公钥, want 2（先 agent 后密钥文件）", offered)
+	}
+}
+
 // TestClient_GetConfig 测试获取配置功能
 // 使用模拟客户端对象进行测试
 func TestClient_GetConfig(t *testing.T) {
@@ -226,6 +267,81 @@ func TestClient_Close(t *testing.T) {
 	}
 }
 
+// TestNewClientContext_Timeout 测试连接超时和上下文取消
+// 使用一个接受连接但从不发送 SSH 版本信息的服务，让握手一直阻塞
+func TestNewClientContext_Timeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// 只读取不应答，客户端放弃时连接关闭
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	_, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	newConfig := func(timeout time.Duration) *config.SSHConfig {
+		return &config.SSHConfig{
+			Host:           "127.0.0.1",
+			Port:           port,
+			Username:       "root",
+			Password:       "123456",
+			HostKeyPolicy:  config.HostKeyOff,
+			ConnectTimeout: timeout,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name:    "握手超时",
+			timeout: 200 * time.Millisecond,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "上下文取消",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(200*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			_, err := NewClientContext(ctx, newConfig(tt.timeout))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewClientContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("NewClientContext() 应该及时返回，耗时 %v", elapsed)
+			}
+		})
+	}
+}
+
 // BenchmarkConfigValidation 性能测试 - 配置验证
 // 测试配置验证的性能表现
 func BenchmarkConfigValidation(b *testing.B) {
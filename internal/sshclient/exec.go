@@ -0,0 +1,155 @@
+// Package sshclient 的命令执行模块
+// 在远程服务器上执行命令，分别收集标准输出、标准错误和退出状态
+// 让调用方可以区分"命令以非零状态退出"和"连接失败"
+// 也可以把输出实时写入调用方提供的 io.Writer，用于长时间运行的命令
+package sshclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RunOptions 是执行远程命令的可选参数
+type RunOptions struct {
+	Stdin   io.Reader         // 命令的标准输入，为 nil 时输入为空
+	Env     map[string]string // 额外的环境变量，服务器可能因为 AcceptEnv 限制而忽略
+	Timeout time.Duration     // 执行超时时间，为 0 时不限制
+
+	// Stdout 和 Stderr 设置后输出会在产生时实时写入，不再收集到 Result 中
+	// 两者在不同的 goroutine 中写入，使用同一个 Writer 时它需要支持并发写入
+	// 命令结束后如果 Writer 实现了 Flush() error（如 *LineWriter、*bufio.Writer）会自动调用
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Result 表示一次远程命令执行的结果
+type Result struct {
+	Command    string        // 执行的命令
+	Stdout     []byte        // 标准输出，设置了 RunOptions.Stdout 时为空
+	Stderr     []byte        // 标准错误，设置了 RunOptions.Stderr 时为空
+	ExitCode   int           // 退出码，命令被信号终止时为 128 加信号编号，未知时为 -1
+	ExitSignal string        // 终止命令的信号名，如 "TERM"、"KILL"，正常退出时为空
+	Duration   time.Duration // 执行耗时
+	TimedOut   bool          // 是否因为超时被终止
+}
+
+// Success 检查命令是否正常执行并以状态 0 退出
+// 返回值:
+//   bool: 如果退出码为 0 且没有超时则返回 true
+func (r *Result) Success() bool {
+	return r.ExitCode == 0 && !r.TimedOut
+}
+
+// ExitError 表示命令以非零状态退出或被信号终止
+// 只由 ExecuteCommand 返回，Run 通过 Result 报告退出状态
+type ExitError struct {
+	Result *Result // 命令的执行结果
+}
+
+// Error 实现 error 接口
+func (e *ExitError) Error() string {
+	if e.Result.ExitSignal != "" {
+		return fmt.Sprintf("命令被信号 %s 终止", e.Result.ExitSignal)
+	}
+	return fmt.Sprintf("命令退出码为 %d", e.Result.ExitCode)
+}
+
+// Run 在远程服务器上执行命令并等待结束
+// 命令以非零状态退出不算错误，退出码和信号记录在 Result 中；
+// 只有连接、会话或超时等问题才返回错误
+// 参数:
+//   ctx: 上下文，取消时终止远程命令
+//   cmd: 要执行的命令字符串
+//   opts: 可选参数，可以为 nil
+// 返回值:
+//   *Result: 执行结果，超时或取消时包含已经收到的输出
+//   error: 如果无法执行命令、连接中断、超时或上下文取消则返回错误
+func (c *Client) Run(ctx context.Context, cmd string, opts *RunOptions) (*Result, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("SSH 连接未建立")
+	}
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// 创建一个新的会话
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	defer session.Close() // 使用完毕后关闭会话
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	session.Stdin = opts.Stdin
+	if opts.Stdout != nil {
+		session.Stdout = opts.Stdout
+		defer flushWriter(opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		session.Stderr = opts.Stderr
+		defer flushWriter(opts.Stderr)
+	}
+
+	// 服务器拒绝设置环境变量时与 OpenSSH 一样忽略
+	for name, value := range opts.Env {
+		session.Setenv(name, value)
+	}
+
+	result := &Result{Command: cmd, ExitCode: -1}
+	start := time.Now()
+	if err := session.Start(cmd); err != nil {
+		return nil, fmt.Errorf("执行命令失败: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 先请求服务器终止命令，再关闭会话，等待输出复制结束
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		result.Duration = time.Since(start)
+		result.Stdout = stdout.Bytes()
+		result.Stderr = stderr.Bytes()
+		result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if result.TimedOut {
+			return result, fmt.Errorf("执行命令超时: %w", ctx.Err())
+		}
+		return result, fmt.Errorf("执行命令被取消: %w", ctx.Err())
+	}
+
+	result.Duration = time.Since(start)
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		result.ExitSignal = exitErr.Signal()
+	default:
+		// 包括 *ssh.ExitMissingError：会话结束但服务器没有报告退出状态
+		return result, fmt.Errorf("执行命令失败: %w", err)
+	}
+	return result, nil
+}
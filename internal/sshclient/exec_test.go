@@ -0,0 +1,166 @@
+// exec_test 提供远程命令执行的单元测试
+// 测试服务器通过本地的 sh 执行命令
+package sshclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestClient_Run 测试标准输出、标准错误和退出状态的收集
+func TestClient_Run(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	tests := []struct {
+		name       string
+		cmd        string
+		opts       *RunOptions
+		wantStdout string
+		wantStderr string
+		wantCode   int
+		wantSignal string
+	}{
+		{
+			name:       "成功执行",
+			cmd:        "echo out; echo err >&2",
+			wantStdout: "out\n",
+			wantStderr: "err\n",
+		},
+		{
+			name:       "非零退出码",
+			cmd:        "echo partial; exit 3",
+			wantStdout: "partial\n",
+			wantCode:   3,
+		},
+		{
+			name:     "grep 没有匹配",
+			cmd:      "echo foo | grep bar",
+			wantCode: 1,
+		},
+		{
+			name:       "被信号终止",
+			cmd:        "kill -TERM $$",
+			wantCode:   128 + 15,
+			wantSignal: "TERM",
+		},
+		{
+			name:       "标准输入",
+			cmd:        "cat",
+			opts:       &RunOptions{Stdin: strings.NewReader("hello")},
+			wantStdout: "hello",
+		},
+		{
+			name:       "环境变量",
+			cmd:        "echo $GOSSH_TEST",
+			opts:       &RunOptions{Env: map[string]string{"GOSSH_TEST": "value"}},
+			wantStdout: "value\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := client.Run(context.Background(), tt.cmd, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if string(result.Stdout) != tt.wantStdout {
+				t.Errorf("Result.Stdout = %q, want %q", result.Stdout, tt.wantStdout)
+			}
+			if string(result.Stderr) != tt.wantStderr {
+				t.Errorf("Result.Stderr = %q, want %q", result.Stderr, tt.wantStderr)
+			}
+			if result.ExitCode != tt.wantCode {
+				t.Errorf("Result.ExitCode = %d, want %d", result.ExitCode, tt.wantCode)
+			}
+			if result.ExitSignal != tt.wantSignal {
+				t.Errorf("Result.ExitSignal = %q, want %q", result.ExitSignal, tt.wantSignal)
+			}
+			if result.Success() != (tt.wantCode == 0) {
+				t.Errorf("Result.Success() = %v, want %v", result.Success(), tt.wantCode == 0)
+			}
+			if result.TimedOut {
+				t.Error("Result.TimedOut = true, want false")
+			}
+		})
+	}
+}
+
+// TestClient_Run_Timeout 测试超时和取消时终止远程命令并保留已收到的输出
+func TestClient_Run_Timeout(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	start := time.Now()
+	result, err := client.Run(context.Background(), "echo started; sleep 10", &RunOptions{Timeout: 300 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("超时后应该立即返回，耗时 %v", elapsed)
+	}
+	if !result.TimedOut {
+		t.Error("Result.TimedOut = false, want true")
+	}
+	if string(result.Stdout) != "started\n" {
+		t.Errorf("Result.Stdout = %q, want %q", result.Stdout, "started\n")
+	}
+
+	// 取消上下文不算超时
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	result, err = client.Run(ctx, "sleep 10", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if result.TimedOut {
+		t.Error("取消时 Result.TimedOut = true, want false")
+	}
+
+	// 连接仍然可用
+	if _, err := client.Run(context.Background(), "true", nil); err != nil {
+		t.Errorf("超时后再次执行命令失败: %v", err)
+	}
+}
+
+// TestClient_ExecuteCommand 测试 ExecuteCommand 在非零退出时保留输出
+func TestClient_ExecuteCommand(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	output, err := client.ExecuteCommand("echo hello")
+	if err != nil || output != "hello\n" {
+		t.Errorf("ExecuteCommand() = %q, %v, want %q, nil", output, err, "hello\n")
+	}
+
+	output, err = client.ExecuteCommand("echo out; echo err >&2; exit 2")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("ExecuteCommand() error = %v, want *ExitError", err)
+	}
+	if exitErr.Result.ExitCode != 2 {
+		t.Errorf("ExitError.Result.ExitCode = %d, want 2", exitErr.Result.ExitCode)
+	}
+	if output != "out\nerr\n" {
+		t.Errorf("ExecuteCommand() output = %q, want %q", output, "out\nerr\n")
+	}
+	if !contains(err.Error(), "2") {
+		t.Errorf("错误信息 %q 应该包含退出码", err.Error())
+	}
+}
+
+// TestClient_ExecuteCommandContext 测试取消时终止命令并返回已收到的输出
+func TestClient_ExecuteCommandContext(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	output, err := client.ExecuteCommandContext(ctx, "echo started; sleep 10")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ExecuteCommandContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if output != "started\n" {
+		t.Errorf("ExecuteCommandContext() output = %q, want %q", output, "started\n")
+	}
+}
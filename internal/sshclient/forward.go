@@ -0,0 +1,367 @@
+// Package sshclient 的端口转发模块
+// 提供本地端口转发（-L）、远程端口转发（-R）和动态端口转发（-D）
+// 本地转发把本地端口收到的连接通过 SSH 连接转发到远程地址，远程转发方向相反
+// 动态转发在本地运行 SOCKS 代理，目标地址由每个连接的 SOCKS 请求决定
+package sshclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"gossh/internal/socks"
+)
+
+// ForwardType 表示端口转发的方向
+type ForwardType string
+
+const (
+	// LocalForwardType 本地转发：本地监听，从服务器端连接目标
+	LocalForwardType ForwardType = "local"
+	// RemoteForwardType 远程转发：服务器端监听，从本地连接目标
+	RemoteForwardType ForwardType = "remote"
+	// DynamicForwardType 动态转发：本地运行 SOCKS 代理，从服务器端连接请求的目标
+	DynamicForwardType ForwardType = "dynamic"
+)
+
+// dynamicTarget 是动态转发的 TargetAddr，目标由 SOCKS 请求决定
+const dynamicTarget = "socks"
+
+// Forward 表示一条正在运行的端口转发
+// 记录连接数量，并在上下文取消或调用 Close 时停止
+type Forward struct {
+	Type       ForwardType // 转发方向
+	ListenAddr string      // 实际监听的地址（端口为 0 时是分配后的端口）
+	TargetAddr string      // 转发目标地址
+
+	listener net.Listener                     // 接受连接的监听器
+	dial     func(net.Conn) (net.Conn, error) // 为接受的连接建立到转发目标的连接
+	onClose  func()                           // 转发停止时的回调，用于从客户端注销
+
+	active int64 // 当前活动的连接数
+	total  int64 // 累计接受的连接数
+	failed int64 // 连接目标失败的次数
+
+	mu        sync.Mutex            // 保护 conns
+	conns     map[net.Conn]struct{} // 当前活动的连接，关闭时需要断开
+	wg        sync.WaitGroup        // 等待所有连接处理结束
+	done      chan struct{}         // 转发停止时关闭
+	served    chan struct{}         // serve 循环退出时关闭
+	closeOnce sync.Once             // 保证只关闭一次
+}
+
+// newForward 创建端口转发对象，调用 start 后开始接受连接
+// 参数:
+//   typ: 转发方向
+//   listener: 已经创建好的监听器
+//   target: 转发目标地址
+//   dial: 为接受的连接建立到转发目标的连接，动态转发在这里完成 SOCKS 握手
+// 返回值:
+//   *Forward: 正在运行的转发
+func newForward(typ ForwardType, listener net.Listener, target string, dial func(net.Conn) (net.Conn, error)) *Forward {
+	return &Forward{
+		Type:       typ,
+		ListenAddr: listener.Addr().String(),
+		TargetAddr: target,
+		listener:   listener,
+		dial:       dial,
+		conns:      make(map[net.Conn]struct{}),
+		done:       make(chan struct{}),
+		served:     make(chan struct{}),
+	}
+}
+
+// start 开始接受连接，上下文取消时停止转发
+func (f *Forward) start(ctx context.Context) {
+	// 上下文取消时停止转发
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-f.done:
+		}
+	}()
+
+	go f.serve()
+}
+
+// serve 循环接受连接，直到监听器被关闭
+func (f *Forward) serve() {
+	defer close(f.served)
+	defer f.Close()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&f.total, 1)
+		f.wg.Add(1)
+		go f.handle(conn)
+	}
+}
+
+// handle 处理一个连接：连接转发目标并双向复制数据
+func (f *Forward) handle(conn net.Conn) {
+	defer f.wg.Done()
+
+	target, err := f.dial(conn)
+	if err != nil {
+		atomic.AddInt64(&f.failed, 1)
+		conn.Close()
+		return
+	}
+
+	// 转发已经停止时不再处理新连接
+	if !f.track(conn, target) {
+		conn.Close()
+		target.Close()
+		return
+	}
+	atomic.AddInt64(&f.active, 1)
+	defer atomic.AddInt64(&f.active, -1)
+
+	pipe(conn, target)
+	f.untrack(conn, target)
+}
+
+// track 记录活动连接，转发已经停止时返回 false
+func (f *Forward) track(conns ...net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return false
+	default:
+	}
+	for _, c := range conns {
+		f.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// untrack 移除已经结束的连接
+func (f *Forward) untrack(conns ...net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range conns {
+		delete(f.conns, c)
+	}
+}
+
+// Close 停止转发：不再接受新连接，并断开所有活动连接
+// 返回值:
+//   error: 关闭监听器时的错误
+func (f *Forward) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		f.mu.Lock()
+		close(f.done)
+		for c := range f.conns {
+			c.Close()
+		}
+		f.mu.Unlock()
+
+		err = f.listener.Close()
+		if f.onClose != nil {
+			f.onClose()
+		}
+	})
+	return err
+}
+
+// Wait 等待转发停止，并且所有连接处理结束
+func (f *Forward) Wait() {
+	<-f.served
+	f.wg.Wait()
+}
+
+// Done 返回一个在转发停止时关闭的通道
+func (f *Forward) Done() <-chan struct{} {
+	return f.done
+}
+
+// Active 返回当前活动的连接数
+func (f *Forward) Active() int64 {
+	return atomic.LoadInt64(&f.active)
+}
+
+// Total 返回累计接受的连接数
+func (f *Forward) Total() int64 {
+	return atomic.LoadInt64(&f.total)
+}
+
+// Failed 返回连接转发目标失败的次数
+func (f *Forward) Failed() int64 {
+	return atomic.LoadInt64(&f.failed)
+}
+
+// String 返回转发的描述，如 "local 127.0.0.1:8080 -> db:5432"
+func (f *Forward) String() string {
+	return fmt.Sprintf("%s %s -> %s", f.Type, f.ListenAddr, f.TargetAddr)
+}
+
+// LocalForward 启动本地端口转发
+// 在本地监听 localAddr，每个连接都通过 SSH 连接转发到服务器端可以访问的 remoteAddr
+// 参数:
+//   ctx: 上下文，取消时停止转发并断开所有连接
+//   localAddr: 本地监听地址，如 "localhost:8080"，端口为 0 时自动分配
+//   remoteAddr: 从服务器端连接的目标地址，如 "db.internal:5432"
+// 返回值:
+//   *Forward: 正在运行的转发，可以查询连接数或手动关闭
+//   error: 如果本地端口监听失败则返回错误
+func (c *Client) LocalForward(ctx context.Context, localAddr, remoteAddr string) (*Forward, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("SSH 连接未建立")
+	}
+
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("监听本地地址 %s 失败: %w", localAddr, err)
+	}
+
+	f := newForward(LocalForwardType, listener, remoteAddr, func(net.Conn) (net.Conn, error) {
+		return c.conn.Dial("tcp", remoteAddr)
+	})
+	c.addForward(f)
+	f.start(ctx)
+	return f, nil
+}
+
+// forwardDeniedMessage 是 x/crypto/ssh 在服务器拒绝 tcpip-forward 请求时返回的错误信息
+// x/crypto/ssh 没有为这种情况导出错误类型，只能按信息区分拒绝和其他错误
+const forwardDeniedMessage = "ssh: tcpip-forward request denied by peer"
+
+// RemoteForwardError 表示服务器拒绝了远程端口转发的监听请求
+// 常见原因是服务器配置了 GatewayPorts/AllowTcpForwarding 限制，或者端口已被占用
+type RemoteForwardError struct {
+	ListenAddr string // 请求服务器监听的地址
+	Err        error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *RemoteForwardError) Error() string {
+	return fmt.Sprintf("服务器拒绝在 %s 上监听（可能受 GatewayPorts/AllowTcpForwarding 限制或端口已被占用）: %v", e.ListenAddr, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *RemoteForwardError) Unwrap() error {
+	return e.Err
+}
+
+// RemoteForward 启动远程端口转发
+// 请求服务器监听 remoteAddr（tcpip-forward），每个连接都转发到本地可以访问的 localAddr
+// 转发停止时会向服务器发送 cancel-tcpip-forward 请求，释放服务器上的端口
+// 参数:
+//   ctx: 上下文，取消时停止转发并断开所有连接
+//   remoteAddr: 服务器端监听地址，如 "localhost:8080"，端口为 0 时由服务器分配
+//   localAddr: 从本地连接的目标地址，如 "localhost:3000"
+// 返回值:
+//   *Forward: 正在运行的转发，ListenAddr 包含服务器实际分配的端口
+//   error: 服务器拒绝监听时返回 *RemoteForwardError，地址无效或连接断开等其他错误原样返回
+func (c *Client) RemoteForward(ctx context.Context, remoteAddr, localAddr string) (*Forward, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("SSH 连接未建立")
+	}
+
+	listener, err := c.conn.Listen("tcp", remoteAddr)
+	if err != nil {
+		if err.Error() == forwardDeniedMessage {
+			return nil, &RemoteForwardError{ListenAddr: remoteAddr, Err: err}
+		}
+		return nil, err
+	}
+
+	f := newForward(RemoteForwardType, listener, localAddr, func(net.Conn) (net.Conn, error) {
+		return net.Dial("tcp", localAddr)
+	})
+	c.addForward(f)
+	f.start(ctx)
+	return f, nil
+}
+
+// DynamicForward 启动动态端口转发
+// 在本地监听 localAddr 并运行 SOCKS5/SOCKS4a 代理，每个 CONNECT 请求都通过 SSH 连接到目标
+// 请求中的域名原样交给服务器解析，不会在本地进行 DNS 查询
+// 参数:
+//   ctx: 上下文，取消时停止转发并断开所有连接
+//   localAddr: 本地监听地址，如 "localhost:1080"，端口为 0 时自动分配
+// 返回值:
+//   *Forward: 正在运行的转发，Failed 统计握手失败和目标连接失败的次数
+//   error: 如果本地端口监听失败则返回错误
+func (c *Client) DynamicForward(ctx context.Context, localAddr string) (*Forward, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("SSH 连接未建立")
+	}
+
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("监听本地地址 %s 失败: %w", localAddr, err)
+	}
+
+	f := newForward(DynamicForwardType, listener, dynamicTarget, func(conn net.Conn) (net.Conn, error) {
+		return socks.Connect(conn, c.conn)
+	})
+	c.addForward(f)
+	f.start(ctx)
+	return f, nil
+}
+
+// addForward 把转发登记到客户端，转发停止时自动注销
+func (c *Client) addForward(f *Forward) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forwards = append(c.forwards, f)
+	f.onClose = func() { c.removeForward(f) }
+}
+
+// removeForward 从客户端注销已经停止的转发
+func (c *Client) removeForward(f *Forward) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, existing := range c.forwards {
+		if existing == f {
+			c.forwards = append(c.forwards[:i], c.forwards[i+1:]...)
+			return
+		}
+	}
+}
+
+// Forwards 返回客户端上所有正在运行的端口转发
+// 返回值:
+//   []*Forward: 转发列表的副本
+func (c *Client) Forwards() []*Forward {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Forward(nil), c.forwards...)
+}
+
+// closeForwards 停止客户端上所有的端口转发
+func (c *Client) closeForwards() {
+	for _, f := range c.Forwards() {
+		f.Close()
+	}
+}
+
+// pipe 在两个连接之间双向复制数据，两个方向都结束后关闭连接
+// 一个方向读到 EOF 时只关闭对端的写入，让另一个方向继续传输剩余数据
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+	a.Close()
+	b.Close()
+}
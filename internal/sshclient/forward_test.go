@@ -0,0 +1,335 @@
+// forward_test 提供端口转发的单元测试
+// 通过进程内 SSH 服务器把连接转发到本地的 echo 服务
+package sshclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestClient 启动测试服务器并返回已连接的客户端
+func newTestClient(t *testing.T) (*Client, *testServer) {
+	t.Helper()
+	server := startTestServer(t, passwordServerConfig("tester", "secret"))
+	client, err := NewClient(testHostConfig(server, "tester", "secret"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+// startEchoServer 启动一个把收到的数据原样返回的 TCP 服务
+// 返回值:
+//   string: 监听地址
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动 echo 服务失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// waitFor 在超时之前反复检查条件
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestClient_LocalForward 测试本地端口转发的数据传输和连接计数
+func TestClient_LocalForward(t *testing.T) {
+	client, server := newTestClient(t)
+	echoAddr := startEchoServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	forward, err := client.LocalForward(ctx, "127.0.0.1:0", echoAddr)
+	if err != nil {
+		t.Fatalf("LocalForward() error = %v", err)
+	}
+	if got := client.Forwards(); len(got) != 1 || got[0] != forward {
+		t.Errorf("Client.Forwards() = %v, want [%v]", got, forward)
+	}
+
+	// 通过转发端口发送数据，应该收到 echo 的回应
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接转发端口失败: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Fatalf("读取回应 = %q, %v, want hello", line, err)
+	}
+
+	if forward.Total() != 1 || forward.Active() != 1 {
+		t.Errorf("连接计数 total = %d, active = %d, want 1, 1", forward.Total(), forward.Active())
+	}
+	if got := server.Forwarded(); len(got) != 1 || got[0] != echoAddr {
+		t.Errorf("服务器转发目标 = %v, want [%s]", got, echoAddr)
+	}
+
+	// 客户端关闭连接后活动连接数归零
+	conn.Close()
+	waitFor(t, "活动连接数归零", func() bool { return forward.Active() == 0 })
+
+	// 取消上下文后停止转发，并从客户端注销
+	cancel()
+	forward.Wait()
+	if _, err := net.Dial("tcp", forward.ListenAddr); err == nil {
+		t.Error("取消后转发端口仍然可以连接")
+	}
+	if got := client.Forwards(); len(got) != 0 {
+		t.Errorf("取消后 Client.Forwards() = %v, want empty", got)
+	}
+}
+
+// TestClient_LocalForward_Shutdown 测试停止转发时断开活动连接
+func TestClient_LocalForward_Shutdown(t *testing.T) {
+	client, _ := newTestClient(t)
+	echoAddr := startEchoServer(t)
+
+	forward, err := client.LocalForward(context.Background(), "127.0.0.1:0", echoAddr)
+	if err != nil {
+		t.Fatalf("LocalForward() error = %v", err)
+	}
+
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接转发端口失败: %v", err)
+	}
+	defer conn.Close()
+	waitFor(t, "连接建立", func() bool { return forward.Active() == 1 })
+
+	// 关闭客户端会停止所有转发
+	client.Close()
+	forward.Wait()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("停止转发后读取 error = %v, want EOF", err)
+	}
+}
+
+// TestClient_LocalForward_Errors 测试监听失败和目标不可达
+func TestClient_LocalForward_Errors(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	// 端口已被占用
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer busy.Close()
+	if _, err := client.LocalForward(context.Background(), busy.Addr().String(), "127.0.0.1:1"); err == nil {
+		t.Error("端口被占用时 LocalForward() 应该返回错误")
+	}
+
+	// 目标不可达时记录失败次数
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	forward, err := client.LocalForward(context.Background(), "127.0.0.1:0", closedAddr)
+	if err != nil {
+		t.Fatalf("LocalForward() error = %v", err)
+	}
+	defer forward.Close()
+
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接转发端口失败: %v", err)
+	}
+	defer conn.Close()
+	waitFor(t, "记录失败次数", func() bool { return forward.Failed() == 1 })
+}
+
+// TestClient_RemoteForward 测试远程端口转发：服务器分配端口、数据传输和取消请求
+func TestClient_RemoteForward(t *testing.T) {
+	client, server := newTestClient(t)
+	echoAddr := startEchoServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 端口为 0 时由服务器分配，并通过 ListenAddr 返回
+	forward, err := client.RemoteForward(ctx, "127.0.0.1:0", echoAddr)
+	if err != nil {
+		t.Fatalf("RemoteForward() error = %v", err)
+	}
+	_, port, _ := net.SplitHostPort(forward.ListenAddr)
+	if port == "0" || port == "" {
+		t.Fatalf("RemoteForward() ListenAddr = %s, want allocated port", forward.ListenAddr)
+	}
+
+	// 连接服务器上的端口，数据应该被转发到本地的 echo 服务
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接远程转发端口失败: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("remote\n")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "remote\n" {
+		t.Fatalf("读取回应 = %q, %v, want remote", line, err)
+	}
+	if forward.Total() != 1 {
+		t.Errorf("Total() = %d, want 1", forward.Total())
+	}
+
+	// 停止转发时应该向服务器发送 cancel-tcpip-forward
+	cancel()
+	forward.Wait()
+	waitFor(t, "服务器收到取消请求", func() bool {
+		got := server.Cancelled()
+		return len(got) == 1 && got[0] == forward.ListenAddr
+	})
+	if _, err := net.Dial("tcp", forward.ListenAddr); err == nil {
+		t.Error("取消后服务器端口仍然可以连接")
+	}
+}
+
+// TestClient_RemoteForward_Denied 测试服务器拒绝监听时返回类型化的错误
+func TestClient_RemoteForward_Denied(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	// 测试服务器只允许监听回环地址，类似 GatewayPorts no
+	_, err := client.RemoteForward(context.Background(), "0.0.0.0:0", "127.0.0.1:1")
+	var bindErr *RemoteForwardError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("RemoteForward() error = %v, want *RemoteForwardError", err)
+	}
+	if bindErr.ListenAddr != "0.0.0.0:0" {
+		t.Errorf("RemoteForwardError.ListenAddr = %s, want 0.0.0.0:0", bindErr.ListenAddr)
+	}
+	if got := client.Forwards(); len(got) != 0 {
+		t.Errorf("失败的转发不应该被登记，got %v", got)
+	}
+}
+
+// TestClient_RemoteForward_OtherErrors 测试连接断开和地址无效时不返回 *RemoteForwardError
+func TestClient_RemoteForward_OtherErrors(t *testing.T) {
+	client, _ := newTestClient(t)
+	_, err := client.RemoteForward(context.Background(), "127.0.0.1:bad", "127.0.0.1:1")
+	var bindErr *RemoteForwardError
+	if err == nil || errors.As(err, &bindErr) {
+		t.Errorf("地址无效时 RemoteForward() error = %v, want 非 *RemoteForwardError 的错误", err)
+	}
+
+	client.GetConnection().Close()
+	_, err = client.RemoteForward(context.Background(), "127.0.0.1:0", "127.0.0.1:1")
+	if err == nil || errors.As(err, &bindErr) {
+		t.Errorf("连接断开时 RemoteForward() error = %v, want 非 *RemoteForwardError 的错误", err)
+	}
+}
+
+// TestClient_DynamicForward 测试 SOCKS 动态转发：域名交给服务器解析，数据经过 SSH 通道
+func TestClient_DynamicForward(t *testing.T) {
+	client, server := newTestClient(t)
+	echoAddr := startEchoServer(t)
+	_, echoPort := splitPort(t, echoAddr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	forward, err := client.DynamicForward(ctx, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("DynamicForward() error = %v", err)
+	}
+	if forward.Type != DynamicForwardType {
+		t.Errorf("Forward.Type = %s, want %s", forward.Type, DynamicForwardType)
+	}
+
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接 SOCKS 代理失败: %v", err)
+	}
+	defer conn.Close()
+
+	// SOCKS5 握手，使用域名作为目标
+	req := []byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x03, byte(len("localhost"))}
+	req = append(req, "localhost"...)
+	req = append(req, byte(echoPort>>8), byte(echoPort))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("发送 SOCKS 请求失败: %v", err)
+	}
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("读取 SOCKS 应答失败: %v", err)
+	}
+	if reply[1] != 0x00 || reply[3] != 0x00 {
+		t.Fatalf("SOCKS 应答 = %v, want 成功", reply)
+	}
+
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("ping\n"))
+	if line, err := reader.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("经过代理读取 = %q, %v, want %q", line, err, "ping\n")
+	}
+
+	// 域名原样发送给服务器，没有在本地解析
+	want := net.JoinHostPort("localhost", strconv.Itoa(echoPort))
+	if got := server.Forwarded(); len(got) != 1 || got[0] != want {
+		t.Errorf("服务器收到的目标 = %v, want [%s]", got, want)
+	}
+
+	// 目标不可达时返回失败应答并计入 Failed
+	bad, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("连接 SOCKS 代理失败: %v", err)
+	}
+	defer bad.Close()
+	bad.Write([]byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x01, 127, 0, 0, 1, 0, 1})
+	if _, err := io.ReadFull(bad, reply); err != nil {
+		t.Fatalf("读取 SOCKS 应答失败: %v", err)
+	}
+	if reply[3] == 0x00 {
+		t.Errorf("连接不可达的目标应该失败，应答 = %v", reply)
+	}
+	waitFor(t, "失败计数", func() bool { return forward.Failed() == 1 })
+}
+
+// splitPort 拆分地址中的主机和端口
+func splitPort(t *testing.T, addr string) (string, int) {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("拆分地址 %s 失败: %v", addr, err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
@@ -0,0 +1,231 @@
+// Package sshclient 的主机密钥校验模块
+// 基于 OpenSSH 格式的 known_hosts 文件验证服务器身份
+// 防止连接被中间人劫持
+package sshclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"gossh/internal/config"
+)
+
+// HostKeyMismatchError 表示服务器提供的主机密钥与 known_hosts 中记录的不一致
+// 这通常意味着服务器重装过系统，或者连接正在被中间人劫持
+type HostKeyMismatchError struct {
+	Host      string   // 连接的主机地址，格式为 "host:port"
+	File      string   // 记录主机密钥的 known_hosts 文件
+	Expected  []string // known_hosts 中记录的密钥指纹（SHA256）
+	Presented string   // 服务器实际提供的密钥指纹（SHA256）
+	KeyType   string   // 服务器实际提供的密钥类型
+}
+
+// Error 实现 error 接口，返回包含指纹信息的错误描述
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("主机 %s 的密钥与 %s 中的记录不一致（可能存在中间人攻击）: 期望 %s，实际 %s %s",
+		e.Host, e.File, strings.Join(e.Expected, ", "), e.KeyType, e.Presented)
+}
+
+// UnknownHostKeyError 表示严格模式下遇到了 known_hosts 中没有记录的主机
+type UnknownHostKeyError struct {
+	Host        string // 连接的主机地址，格式为 "host:port"
+	File        string // 查找的 known_hosts 文件
+	Fingerprint string // 服务器提供的密钥指纹（SHA256）
+	KeyType     string // 服务器提供的密钥类型
+}
+
+// Error 实现 error 接口，返回包含指纹信息的错误描述
+func (e *UnknownHostKeyError) Error() string {
+	return fmt.Sprintf("主机 %s 不在 %s 中（%s %s），严格模式下拒绝连接",
+		e.Host, e.File, e.KeyType, e.Fingerprint)
+}
+
+// hostKeyVerifier 根据配置的策略校验主机密钥
+// 需要时会把新主机的密钥追加到 known_hosts 文件
+type hostKeyVerifier struct {
+	policy config.HostKeyPolicy // 校验策略
+	file   string               // known_hosts 文件路径
+	mu     sync.Mutex           // 保护 known_hosts 文件的读写
+}
+
+// newHostKeyVerifier 根据配置创建主机密钥校验器
+// 参数:
+//   cfg: SSH 连接配置
+// 返回值:
+//   *hostKeyVerifier: 校验器对象
+//   error: 如果无法确定 known_hosts 文件位置则返回错误
+func newHostKeyVerifier(cfg *config.SSHConfig) (*hostKeyVerifier, error) {
+	policy := cfg.GetHostKeyPolicy()
+	file := cfg.GetKnownHostsFile()
+	if policy != config.HostKeyOff && file == "" {
+		return nil, errors.New("无法确定 known_hosts 文件路径")
+	}
+	return &hostKeyVerifier{policy: policy, file: file}, nil
+}
+
+// loadCallback 从 known_hosts 文件加载校验回调
+// 文件不存在时返回 nil 回调，表示没有任何已知主机
+func (v *hostKeyVerifier) loadCallback() (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(v.file); os.IsNotExist(err) {
+		return nil, nil
+	}
+	callback, err := knownhosts.New(v.file)
+	if err != nil {
+		return nil, fmt.Errorf("读取 known_hosts 文件失败: %w", err)
+	}
+	return callback, nil
+}
+
+// Callback 返回供 ssh.ClientConfig 使用的主机密钥回调函数
+// 返回值:
+//   ssh.HostKeyCallback: 主机密钥回调函数
+func (v *hostKeyVerifier) Callback() ssh.HostKeyCallback {
+	if v.policy == config.HostKeyOff {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return v.check
+}
+
+// check 校验服务器提供的主机密钥
+// 参数:
+//   hostname: 连接时使用的地址（host:port）
+//   remote: 服务器的实际网络地址
+//   key: 服务器提供的主机公钥
+// 返回值:
+//   error: 校验失败时返回 HostKeyMismatchError 或 UnknownHostKeyError
+func (v *hostKeyVerifier) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	callback, err := v.loadCallback()
+	if err != nil {
+		return err
+	}
+
+	if callback != nil {
+		err = callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			// 吊销的密钥或文件格式错误，直接拒绝
+			return fmt.Errorf("主机密钥校验失败: %w", err)
+		}
+		if len(keyErr.Want) > 0 {
+			// 已经记录过这个主机，但密钥不同
+			expected := make([]string, 0, len(keyErr.Want))
+			for _, known := range keyErr.Want {
+				expected = append(expected, fmt.Sprintf("%s %s (%s:%d)",
+					known.Key.Type(), ssh.FingerprintSHA256(known.Key), known.Filename, known.Line))
+			}
+			return &HostKeyMismatchError{
+				Host:      hostname,
+				File:      v.file,
+				Expected:  expected,
+				Presented: ssh.FingerprintSHA256(key),
+				KeyType:   key.Type(),
+			}
+		}
+	}
+
+	// 走到这里说明主机未知
+	if v.policy == config.HostKeyStrict {
+		return &UnknownHostKeyError{
+			Host:        hostname,
+			File:        v.file,
+			Fingerprint: ssh.FingerprintSHA256(key),
+			KeyType:     key.Type(),
+		}
+	}
+
+	// 首次信任模式：记录新主机的密钥
+	if err := v.appendKey(hostname, key); err != nil {
+		return fmt.Errorf("写入 known_hosts 文件失败: %w", err)
+	}
+	return nil
+}
+
+// appendKey 把主机密钥追加到 known_hosts 文件
+// 如果文件或目录不存在会自动创建，权限与 OpenSSH 保持一致
+func (v *hostKeyVerifier) appendKey(hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(v.file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(v.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+// KnownAlgorithms 返回 known_hosts 中为指定主机记录的密钥算法
+// 连接时优先协商这些算法，避免服务器提供另一种类型的密钥而被误判为不一致
+// 参数:
+//   hostname: 连接地址（host:port）
+// 返回值:
+//   []string: 主机密钥算法列表，主机未知时返回 nil
+func (v *hostKeyVerifier) KnownAlgorithms(hostname string) []string {
+	if v.policy == config.HostKeyOff {
+		return nil
+	}
+
+	v.mu.Lock()
+	callback, err := v.loadCallback()
+	v.mu.Unlock()
+	if err != nil || callback == nil {
+		return nil
+	}
+
+	// 用一个随机生成的密钥探测，校验失败时 KeyError 会列出所有已知密钥
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(hostname, &net.TCPAddr{IP: net.IPv4zero}, probe), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, known := range keyErr.Want {
+		for _, algo := range hostKeyAlgorithmsFor(known.Key.Type()) {
+			if !seen[algo] {
+				seen[algo] = true
+				algorithms = append(algorithms, algo)
+			}
+		}
+	}
+	return algorithms
+}
+
+// hostKeyAlgorithmsFor 返回某种密钥类型可以使用的签名算法
+// RSA 密钥可以使用 SHA-2 签名算法，其余类型与密钥类型同名
+func hostKeyAlgorithmsFor(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
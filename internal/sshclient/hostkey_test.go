@@ -0,0 +1,190 @@
+// hostkey_test 提供主机密钥校验的单元测试
+// 使用临时的 known_hosts 文件和随机生成的密钥，不需要网络连接
+package sshclient
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"gossh/internal/config"
+)
+
+// newTestPublicKey 生成一个用于测试的 ed25519 公钥
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	key, _ := mustGenerateKey(t)
+	return key
+}
+
+// writeKnownHosts 把若干行写入临时 known_hosts 文件并返回路径
+func writeKnownHosts(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("写入 known_hosts 失败: %v", err)
+	}
+	return path
+}
+
+// testRemote 是校验时使用的远程地址
+var testRemote = &net.TCPAddr{IP: net.ParseIP("192.168.1.100"), Port: 22}
+
+// TestHostKeyVerifier_Strict 测试严格模式
+func TestHostKeyVerifier_Strict(t *testing.T) {
+	known := newTestPublicKey(t)
+	path := writeKnownHosts(t, knownhosts.Line([]string{"example.com"}, known))
+
+	v, err := newHostKeyVerifier(&config.SSHConfig{HostKeyPolicy: config.HostKeyStrict, KnownHostsFile: path})
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier() error = %v", err)
+	}
+	callback := v.Callback()
+
+	// 已知主机、密钥一致
+	if err := callback("example.com:22", testRemote, known); err != nil {
+		t.Errorf("已知主机校验失败: %v", err)
+	}
+
+	// 未知主机应该被拒绝，并且不写入文件
+	var unknownErr *UnknownHostKeyError
+	err = callback("other.com:22", testRemote, newTestPublicKey(t))
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("未知主机 error = %v, want *UnknownHostKeyError", err)
+	}
+	if unknownErr.Host != "other.com:22" {
+		t.Errorf("UnknownHostKeyError.Host = %v, want other.com:22", unknownErr.Host)
+	}
+	if err := callback("other.com:22", testRemote, newTestPublicKey(t)); err == nil {
+		t.Error("严格模式不应该记录未知主机")
+	}
+}
+
+// TestHostKeyVerifier_AcceptNew 测试首次信任模式
+func TestHostKeyVerifier_AcceptNew(t *testing.T) {
+	// 使用不存在的目录，验证会自动创建
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	v, err := newHostKeyVerifier(&config.SSHConfig{KnownHostsFile: path})
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier() error = %v", err)
+	}
+	callback := v.Callback()
+
+	first := newTestPublicKey(t)
+	if err := callback("example.com:2222", testRemote, first); err != nil {
+		t.Fatalf("首次连接应该被接受: %v", err)
+	}
+	if err := callback("example.com:2222", testRemote, first); err != nil {
+		t.Errorf("再次连接同一密钥应该通过: %v", err)
+	}
+
+	// 密钥变化时应该返回包含指纹的不一致错误
+	second := newTestPublicKey(t)
+	var mismatch *HostKeyMismatchError
+	err = callback("example.com:2222", testRemote, second)
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("密钥变化 error = %v, want *HostKeyMismatchError", err)
+	}
+	if mismatch.Presented != ssh.FingerprintSHA256(second) {
+		t.Errorf("Presented = %v, want %v", mismatch.Presented, ssh.FingerprintSHA256(second))
+	}
+	if len(mismatch.Expected) != 1 || !contains(mismatch.Expected[0], ssh.FingerprintSHA256(first)) {
+		t.Errorf("Expected = %v, want fingerprint %v", mismatch.Expected, ssh.FingerprintSHA256(first))
+	}
+
+	// 写入的记录应该使用 [host]:port 格式
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 known_hosts 失败: %v", err)
+	}
+	if !contains(string(data), "[example.com]:2222 ") {
+		t.Errorf("known_hosts 内容 = %q, want [example.com]:2222 entry", data)
+	}
+}
+
+// TestHostKeyVerifier_EntryFormats 测试哈希主机名和非标准端口的记录
+func TestHostKeyVerifier_EntryFormats(t *testing.T) {
+	hashedKey := newTestPublicKey(t)
+	portKey := newTestPublicKey(t)
+	path := writeKnownHosts(t,
+		"# 注释行会被忽略",
+		knownhosts.Line([]string{knownhosts.HashHostname("hashed.example.com")}, hashedKey),
+		knownhosts.Line([]string{"[port.example.com]:2222"}, portKey),
+	)
+
+	v, err := newHostKeyVerifier(&config.SSHConfig{HostKeyPolicy: config.HostKeyStrict, KnownHostsFile: path})
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier() error = %v", err)
+	}
+	callback := v.Callback()
+
+	tests := []struct {
+		name     string
+		hostname string
+		key      ssh.PublicKey
+		wantErr  bool
+	}{
+		{name: "哈希主机名匹配", hostname: "hashed.example.com:22", key: hashedKey},
+		{name: "哈希主机名密钥错误", hostname: "hashed.example.com:22", key: portKey, wantErr: true},
+		{name: "非标准端口匹配", hostname: "port.example.com:2222", key: portKey},
+		{name: "端口不同视为未知主机", hostname: "port.example.com:22", key: portKey, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := callback(tt.hostname, testRemote, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("callback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestHostKeyVerifier_Off 测试关闭校验
+func TestHostKeyVerifier_Off(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	v, err := newHostKeyVerifier(&config.SSHConfig{HostKeyPolicy: config.HostKeyOff, KnownHostsFile: path})
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier() error = %v", err)
+	}
+	if err := v.Callback()("example.com:22", testRemote, newTestPublicKey(t)); err != nil {
+		t.Errorf("关闭校验时不应该返回错误: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("关闭校验时不应该写入 known_hosts")
+	}
+	if algos := v.KnownAlgorithms("example.com:22"); algos != nil {
+		t.Errorf("KnownAlgorithms() = %v, want nil", algos)
+	}
+}
+
+// TestHostKeyVerifier_KnownAlgorithms 测试已知主机的算法协商列表
+func TestHostKeyVerifier_KnownAlgorithms(t *testing.T) {
+	path := writeKnownHosts(t, knownhosts.Line([]string{"example.com"}, newTestPublicKey(t)))
+	v, err := newHostKeyVerifier(&config.SSHConfig{KnownHostsFile: path})
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier() error = %v", err)
+	}
+
+	algos := v.KnownAlgorithms("example.com:22")
+	if len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("KnownAlgorithms() = %v, want [%s]", algos, ssh.KeyAlgoED25519)
+	}
+	if algos := v.KnownAlgorithms("unknown.com:22"); algos != nil {
+		t.Errorf("未知主机 KnownAlgorithms() = %v, want nil", algos)
+	}
+
+	rsa := hostKeyAlgorithmsFor(ssh.KeyAlgoRSA)
+	if len(rsa) != 3 || rsa[0] != ssh.KeyAlgoRSASHA512 {
+		t.Errorf("hostKeyAlgorithmsFor(ssh-rsa) = %v", rsa)
+	}
+}
@@ -0,0 +1,77 @@
+// jump_test 提供跳板机链路的单元测试
+// 使用多个进程内 SSH 服务器模拟跳板机和目标主机
+package sshclient
+
+import (
+	"testing"
+
+	"gossh/internal/config"
+)
+
+// testHostConfig 返回连接测试服务器的配置
+func testHostConfig(server *testServer, user, password string) *config.SSHConfig {
+	return &config.SSHConfig{
+		Host:          server.Host(),
+		Port:          server.Port(),
+		Username:      user,
+		Password:      password,
+		HostKeyPolicy: config.HostKeyOff,
+	}
+}
+
+// TestNewClient_JumpHosts 测试经过两台跳板机连接目标主机
+func TestNewClient_JumpHosts(t *testing.T) {
+	target := startTestServer(t, passwordServerConfig("app", "app-pass"))
+	bastion2 := startTestServer(t, passwordServerConfig("jump2", "jump2-pass"))
+	bastion1 := startTestServer(t, passwordServerConfig("jump1", "jump1-pass"))
+
+	cfg := testHostConfig(target, "app", "app-pass")
+	cfg.JumpHosts = []*config.SSHConfig{
+		testHostConfig(bastion1, "jump1", "jump1-pass"),
+		testHostConfig(bastion2, "jump2", "jump2-pass"),
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// 第一台跳板机转发到第二台，第二台转发到目标主机
+	if got := bastion1.Forwarded(); len(got) != 1 || got[0] != bastion2.Addr() {
+		t.Errorf("bastion1 转发目标 = %v, want [%s]", got, bastion2.Addr())
+	}
+	if got := bastion2.Forwarded(); len(got) != 1 || got[0] != target.Addr() {
+		t.Errorf("bastion2 转发目标 = %v, want [%s]", got, target.Addr())
+	}
+	if len(client.jumps) != 2 {
+		t.Fatalf("跳板机连接数 = %d, want 2", len(client.jumps))
+	}
+
+	// 关闭客户端时整条链路都应该关闭
+	jumps := client.jumps
+	if err := client.Close(); err != nil {
+		t.Errorf("Client.Close() error = %v", err)
+	}
+	for i, jump := range jumps {
+		if _, _, err := jump.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+			t.Errorf("跳板机 %d 的连接没有被关闭", i+1)
+		}
+	}
+}
+
+// TestNewClient_JumpHostFailure 测试跳板机认证失败时的错误
+func TestNewClient_JumpHostFailure(t *testing.T) {
+	target := startTestServer(t, passwordServerConfig("app", "app-pass"))
+	bastion := startTestServer(t, passwordServerConfig("jump", "jump-pass"))
+
+	cfg := testHostConfig(target, "app", "app-pass")
+	cfg.JumpHosts = []*config.SSHConfig{testHostConfig(bastion, "jump", "wrong")}
+
+	_, err := NewClient(cfg)
+	if err == nil || !contains(err.Error(), "连接跳板机 "+bastion.Addr()+" 失败") {
+		t.Errorf("NewClient() error = %v, want jump host failure", err)
+	}
+	if got := bastion.Forwarded(); len(got) != 0 {
+		t.Errorf("认证失败时不应该转发，got %v", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Package sshclient 的保活模块
+// 定期发送 keepalive@openssh.com 请求，类似 OpenSSH 的 ServerAliveInterval/ServerAliveCountMax
+// 连续多次没有应答时主动断开连接，避免经过 NAT 的空闲连接无声无息地失效
+package sshclient
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// keepaliveRequest 是 OpenSSH 使用的保活请求类型，服务器不认识时也会应答（失败），同样说明连接正常
+const keepaliveRequest = "keepalive@openssh.com"
+
+// ConnState 表示连接的健康状态
+type ConnState int32
+
+const (
+	// StateConnected 连接正常
+	StateConnected ConnState = iota
+	// StateUnresponsive 最近的保活请求没有应答，连接可能已经中断
+	StateUnresponsive
+	// StateDisconnected 连接已经断开
+	StateDisconnected
+)
+
+// String 返回状态的名称
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateUnresponsive:
+		return "unresponsive"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// startMonitor 开始监控连接：连接断开时关闭 done，配置了保活间隔时定期发送保活请求
+func (c *Client) startMonitor() {
+	c.done = make(chan struct{})
+	c.touch()
+
+	go func() {
+		c.conn.Wait()
+		atomic.StoreInt32(&c.state, int32(StateDisconnected))
+		close(c.done)
+	}()
+
+	if interval := c.config.ServerAliveInterval; interval > 0 {
+		go c.keepalive(interval, c.config.GetServerAliveCountMax())
+	}
+}
+
+// keepalive 每隔 interval 发送一次保活请求
+// 同一时间只有一个请求在等待应答，连续 countMax 个间隔都没有收到应答时关闭连接
+func (c *Client) keepalive(interval time.Duration, countMax int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	replies := make(chan error, 1)
+	pending := false
+	missed := 0
+	for {
+		select {
+		case <-c.done:
+			return
+		case err := <-replies:
+			pending = false
+			if err != nil {
+				return // 连接已经关闭，由监控 goroutine 更新状态
+			}
+			missed = 0
+			c.touch()
+		case <-ticker.C:
+			if pending {
+				missed++
+				atomic.CompareAndSwapInt32(&c.state, int32(StateConnected), int32(StateUnresponsive))
+				if missed >= countMax {
+					c.conn.Close()
+					return
+				}
+				continue
+			}
+			pending = true
+			go func() {
+				_, _, err := c.conn.SendRequest(keepaliveRequest, true, nil)
+				replies <- err
+			}()
+		}
+	}
+}
+
+// touch 记录服务器有响应，并把状态恢复为正常
+func (c *Client) touch() {
+	atomic.StoreInt64(&c.lastAlive, time.Now().UnixNano())
+	atomic.CompareAndSwapInt32(&c.state, int32(StateUnresponsive), int32(StateConnected))
+}
+
+// State 返回连接当前的健康状态
+// 返回值:
+//   ConnState: 连接状态
+func (c *Client) State() ConnState {
+	if c.done == nil {
+		return StateDisconnected
+	}
+	return ConnState(atomic.LoadInt32(&c.state))
+}
+
+// LastAlive 返回最近一次确认服务器有响应的时间
+// 连接建立时和每次收到保活应答时更新
+// 返回值:
+//   time.Time: 最近一次响应的时间
+func (c *Client) LastAlive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastAlive))
+}
+
+// Done 返回一个在连接断开时关闭的通道
+// 无论是调用 Close、服务器断开还是保活超时，通道都会被关闭
+// 返回值:
+//   <-chan struct{}: 连接断开时关闭的通道，客户端没有建立连接时为 nil
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
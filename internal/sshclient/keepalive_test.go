@@ -0,0 +1,159 @@
+// keepalive_test 提供保活和连接状态的单元测试
+// 通过可以冻结的 TCP 代理模拟没有任何响应的网络
+package sshclient
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// freezingProxy 是一个 TCP 代理，冻结后丢弃双向的所有数据，但不断开连接
+type freezingProxy struct {
+	listener net.Listener
+	target   string
+	frozen   int32
+}
+
+// startFreezingProxy 启动转发到 target 的代理，测试结束时自动关闭
+func startFreezingProxy(t *testing.T, target string) *freezingProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动代理失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	p := &freezingProxy{listener: listener, target: target}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.handle(conn)
+		}
+	}()
+	return p
+}
+
+// handle 把连接转发到目标地址
+func (p *freezingProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	go p.copy(upstream, conn)
+	p.copy(conn, upstream)
+}
+
+// copy 复制数据，冻结后读到的数据直接丢弃
+func (p *freezingProxy) copy(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 && atomic.LoadInt32(&p.frozen) == 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Freeze 开始丢弃所有数据
+func (p *freezingProxy) Freeze() {
+	atomic.StoreInt32(&p.frozen, 1)
+}
+
+// Port 返回代理监听的端口
+func (p *freezingProxy) Port() int {
+	_, port, _ := net.SplitHostPort(p.listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// TestClient_Keepalive 测试正常的连接保持 connected 状态并更新最近响应时间
+func TestClient_Keepalive(t *testing.T) {
+	server := startTestServer(t, passwordServerConfig("tester", "secret"))
+	cfg := testHostConfig(server, "tester", "secret")
+	cfg.ServerAliveInterval = 20 * time.Millisecond
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	first := client.LastAlive()
+	// 测试服务器拒绝 keepalive@openssh.com 请求，拒绝同样说明连接正常
+	waitFor(t, "收到保活应答", func() bool { return client.LastAlive().After(first) })
+	if got := client.State(); got != StateConnected {
+		t.Errorf("State() = %v, want %v", got, StateConnected)
+	}
+
+	client.Close()
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() 之后 Done() 没有关闭")
+	}
+	if got := client.State(); got != StateDisconnected {
+		t.Errorf("关闭后 State() = %v, want %v", got, StateDisconnected)
+	}
+}
+
+// TestClient_KeepaliveTimeout 测试网络没有响应时保活超时并断开连接
+func TestClient_KeepaliveTimeout(t *testing.T) {
+	server := startTestServer(t, passwordServerConfig("tester", "secret"))
+	proxy := startFreezingProxy(t, server.Addr())
+
+	cfg := testHostConfig(server, "tester", "secret")
+	cfg.Port = proxy.Port()
+	cfg.ServerAliveInterval = 50 * time.Millisecond
+	cfg.ServerAliveCountMax = 2
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	proxy.Freeze()
+	waitFor(t, "连接变为无响应", func() bool { return client.State() != StateConnected })
+
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("保活超时后连接没有断开")
+	}
+	if got := client.State(); got != StateDisconnected {
+		t.Errorf("State() = %v, want %v", got, StateDisconnected)
+	}
+}
+
+// TestConnState_String 测试连接状态的名称
+func TestConnState_String(t *testing.T) {
+	tests := []struct {
+		state ConnState
+		want  string
+	}{
+		{StateConnected, "connected"},
+		{StateUnresponsive, "unresponsive"},
+		{StateDisconnected, "disconnected"},
+		{ConnState(99), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("ConnState(%d).String() = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
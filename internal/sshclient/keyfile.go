@@ -0,0 +1,196 @@
+// Package sshclient 的私钥加载模块
+// 负责读取私钥文件，并处理带密码保护的私钥
+package sshclient
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	"gossh/internal/config"
+)
+
+// PassphraseEnv 是提供私钥密码的环境变量名，便于在脚本中使用加密私钥
+const PassphraseEnv = "GOSSH_KEY_PASSPHRASE"
+
+// maxPassphraseAttempts 是交互式输入私钥密码的最大尝试次数
+const maxPassphraseAttempts = 3
+
+// PrivateKeyError 表示私钥文件无法使用
+// 通过 WrongPassphrase 区分"密码错误"和"文件格式无效"两种情况
+type PrivateKeyError struct {
+	File            string // 私钥文件路径
+	WrongPassphrase bool   // true 表示密码错误，false 表示文件格式无效
+	Err             error  // 底层的解析错误
+}
+
+// Error 实现 error 接口
+func (e *PrivateKeyError) Error() string {
+	if e.WrongPassphrase {
+		return fmt.Sprintf("私钥 %s 的密码错误", e.File)
+	}
+	return fmt.Sprintf("解析私钥失败: %s: %v", e.File, e.Err)
+}
+
+// Unwrap 返回底层的解析错误
+func (e *PrivateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// promptPassphrase 在终端上提示用户输入私钥密码
+// 定义为变量以便在测试中替换
+var promptPassphrase = func(file string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("私钥 %s 已加密，但没有提供密码且当前不是交互式终端（可设置 %s 环境变量）", file, PassphraseEnv)
+	}
+
+	fmt.Fprintf(os.Stderr, "请输入私钥 %s 的密码: ", file)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr) // 输入密码时不回显换行，这里补上
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥密码失败: %w", err)
+	}
+	return passphrase, nil
+}
+
+// loadPrivateKey 读取并解析配置中的私钥文件
+// 私钥加密时依次使用配置中的密码、环境变量中的密码，最后在终端提示输入
+// 参数:
+//   cfg: SSH 连接配置
+// 返回值:
+//   ssh.Signer: 可用于认证的签名器
+//   error: 读取失败或 *PrivateKeyError
+func loadPrivateKey(cfg *config.SSHConfig) (ssh.Signer, error) {
+	// 读取私钥文件内容
+	keyData, err := ioutil.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥文件失败: %w", err)
+	}
+
+	// 先按未加密的私钥解析
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err == nil {
+		return signer, nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, &PrivateKeyError{File: cfg.KeyFile, Err: err}
+	}
+
+	// 使用预先提供的密码，密码错误时不再提示
+	passphrase := cfg.KeyPassphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(PassphraseEnv)
+	}
+	if passphrase != "" {
+		return parseWithPassphrase(cfg.KeyFile, keyData, []byte(passphrase))
+	}
+
+	// 交互式输入密码，允许重试几次
+	for attempt := 1; ; attempt++ {
+		input, err := promptPassphrase(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := parseWithPassphrase(cfg.KeyFile, keyData, input)
+		var keyErr *PrivateKeyError
+		if err == nil || !errors.As(err, &keyErr) || !keyErr.WrongPassphrase || attempt >= maxPassphraseAttempts {
+			return signer, err
+		}
+		fmt.Fprintln(os.Stderr, "密码错误，请重试")
+	}
+}
+
+// KeyCache 缓存已经解析的私钥，同一个私钥文件和配置中的密码只读取和解密一次
+// 并发连接多台主机时共用一个 KeyCache，加密的私钥只在终端上提示一次密码
+// 可以在多个 goroutine 中并发使用，nil 表示不缓存
+type KeyCache struct {
+	mu      sync.Mutex
+	entries map[keyCacheKey]keyCacheEntry
+}
+
+// keyCacheKey 标识一个私钥文件和它的密码来源
+// 配置中的密码不同时分别解析，避免一台主机的密码错误影响使用同一个文件的其他主机
+type keyCacheKey struct {
+	file       string
+	passphrase string // 配置中的密码，为空时使用环境变量或在终端提示输入
+}
+
+// keyCacheEntry 是一个私钥文件的解析结果，失败时缓存错误，避免再次提示输入密码
+type keyCacheEntry struct {
+	signer ssh.Signer
+	err    error
+}
+
+// NewKeyCache 创建空的私钥缓存
+// 返回值:
+//   *KeyCache: 创建的私钥缓存
+func NewKeyCache() *KeyCache {
+	return &KeyCache{entries: make(map[keyCacheKey]keyCacheEntry)}
+}
+
+// Preload 解析配置和所有跳板机使用的私钥并缓存
+// 在并发连接之前调用，之后的连接直接使用缓存的私钥，不再读取文件或提示输入密码
+// 参数:
+//   cfg: SSH 连接配置，没有配置私钥时什么也不做
+// 返回值:
+//   error: 读取失败或 *PrivateKeyError
+func (k *KeyCache) Preload(cfg *config.SSHConfig) error {
+	for _, hop := range cfg.JumpHosts {
+		if hop.HasKeyAuth() {
+			if _, err := k.signer(hop); err != nil {
+				return err
+			}
+		}
+	}
+	if !cfg.HasKeyAuth() {
+		return nil
+	}
+	_, err := k.signer(cfg)
+	return err
+}
+
+// signer 返回配置中私钥文件的签名器，没有缓存时读取并解析
+// 私钥文件相同但配置中的密码不同时分别缓存
+// 解析期间持有锁，其他连接等待同一次密码输入的结果，不会同时提示
+func (k *KeyCache) signer(cfg *config.SSHConfig) (ssh.Signer, error) {
+	if k == nil {
+		return loadPrivateKey(cfg)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key := keyCacheKey{file: cfg.KeyFile, passphrase: cfg.KeyPassphrase}
+	if entry, ok := k.entries[key]; ok {
+		return entry.signer, entry.err
+	}
+	signer, err := loadPrivateKey(cfg)
+	k.entries[key] = keyCacheEntry{signer: signer, err: err}
+	return signer, err
+}
+
+// parseWithPassphrase 使用密码解析加密的私钥
+// 参数:
+//   file: 私钥文件路径，用于错误信息
+//   keyData: 私钥文件内容
+//   passphrase: 私钥密码
+// 返回值:
+//   ssh.Signer: 可用于认证的签名器
+//   error: 解析失败时返回 *PrivateKeyError
+func parseWithPassphrase(file string, keyData, passphrase []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(keyData, passphrase)
+	if err != nil {
+		return nil, &PrivateKeyError{
+			File:            file,
+			WrongPassphrase: errors.Is(err, x509.IncorrectPasswordError),
+			Err:             err,
+		}
+	}
+	return signer, nil
+}
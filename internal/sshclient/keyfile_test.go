@@ -0,0 +1,186 @@
+// keyfile_test 提供私钥加载的单元测试
+// 覆盖未加密私钥、加密私钥的各种密码来源以及错误类型
+package sshclient
+
+import (
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"gossh/internal/config"
+)
+
+// writeTestKey 生成私钥并写入临时文件
+// 参数:
+//   t: 测试对象
+//   passphrase: 私钥密码，为空时写入未加密的私钥
+// 返回值:
+//   string: 私钥文件路径
+//   ssh.PublicKey: 对应的公钥
+func writeTestKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv := mustGenerateKey(t)
+
+	var block *pem.Block
+	var err error
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("序列化私钥失败: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("写入私钥失败: %v", err)
+	}
+	return path, pub
+}
+
+// stubPrompt 替换终端密码提示，返回预设的输入并记录调用次数
+func stubPrompt(t *testing.T, inputs ...string) *int {
+	t.Helper()
+	calls := 0
+	original := promptPassphrase
+	promptPassphrase = func(file string) ([]byte, error) {
+		if calls >= len(inputs) {
+			return nil, errors.New("没有更多输入")
+		}
+		calls++
+		return []byte(inputs[calls-1]), nil
+	}
+	t.Cleanup(func() { promptPassphrase = original })
+	return &calls
+}
+
+// TestLoadPrivateKey 测试各种私钥和密码组合
+func TestLoadPrivateKey(t *testing.T) {
+	plainKey, plainPub := writeTestKey(t, "")
+	encryptedKey, encryptedPub := writeTestKey(t, "secret")
+
+	tests := []struct {
+		name         string
+		cfg          *config.SSHConfig
+		env          string
+		prompts      []string
+		want         ssh.PublicKey
+		wantPrompts  int
+		wantWrongPwd bool
+	}{
+		{name: "未加密私钥", cfg: &config.SSHConfig{KeyFile: plainKey}, want: plainPub},
+		{name: "配置中的密码", cfg: &config.SSHConfig{KeyFile: encryptedKey, KeyPassphrase: "secret"}, want: encryptedPub},
+		{name: "环境变量中的密码", cfg: &config.SSHConfig{KeyFile: encryptedKey}, env: "secret", want: encryptedPub},
+		{name: "配置中的密码错误时不再提示", cfg: &config.SSHConfig{KeyFile: encryptedKey, KeyPassphrase: "wrong"}, wantWrongPwd: true},
+		{name: "终端输入密码", cfg: &config.SSHConfig{KeyFile: encryptedKey}, prompts: []string{"secret"}, want: encryptedPub, wantPrompts: 1},
+		{name: "终端输入错误后重试", cfg: &config.SSHConfig{KeyFile: encryptedKey}, prompts: []string{"wrong", "secret"}, want: encryptedPub, wantPrompts: 2},
+		{name: "终端多次输入错误", cfg: &config.SSHConfig{KeyFile: encryptedKey}, prompts: []string{"a", "b", "c", "secret"}, wantPrompts: maxPassphraseAttempts, wantWrongPwd: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PassphraseEnv, tt.env)
+			calls := stubPrompt(t, tt.prompts...)
+
+			signer, err := loadPrivateKey(tt.cfg)
+			if *calls != tt.wantPrompts {
+				t.Errorf("密码提示次数 = %d, want %d", *calls, tt.wantPrompts)
+			}
+
+			if tt.wantWrongPwd {
+				var keyErr *PrivateKeyError
+				if !errors.As(err, &keyErr) || !keyErr.WrongPassphrase {
+					t.Fatalf("loadPrivateKey() error = %v, want wrong passphrase", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadPrivateKey() error = %v", err)
+			}
+			if string(signer.PublicKey().Marshal()) != string(tt.want.Marshal()) {
+				t.Error("loadPrivateKey() 返回的公钥不匹配")
+			}
+		})
+	}
+}
+
+// TestLoadPrivateKey_BadFormat 测试格式错误的私钥
+func TestLoadPrivateKey_BadFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad_key")
+	if err := os.WriteFile(path, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("写入测试文件失败: %v", err)
+	}
+
+	_, err := loadPrivateKey(&config.SSHConfig{KeyFile: path})
+	var keyErr *PrivateKeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("loadPrivateKey() error = %v, want *PrivateKeyError", err)
+	}
+	if keyErr.WrongPassphrase {
+		t.Error("格式错误不应该被识别为密码错误")
+	}
+	if !contains(err.Error(), "解析私钥失败") {
+		t.Errorf("loadPrivateKey() error = %v, want error containing 解析私钥失败", err)
+	}
+}
+
+// TestKeyCache 测试并发获取同一个加密私钥时只提示一次密码，失败的结果也会被缓存
+func TestKeyCache(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	encryptedKey, encryptedPub := writeTestKey(t, "secret")
+	calls := stubPrompt(t, "secret")
+
+	keys := NewKeyCache()
+	cfg := &config.SSHConfig{KeyFile: encryptedKey}
+	if err := keys.Preload(&config.SSHConfig{JumpHosts: []*config.SSHConfig{cfg}}); err != nil {
+		t.Fatalf("Preload() error = %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			signer, err := keys.signer(cfg)
+			if err != nil {
+				t.Errorf("signer() error = %v", err)
+				return
+			}
+			if string(signer.PublicKey().Marshal()) != string(encryptedPub.Marshal()) {
+				t.Error("signer() 返回的公钥不匹配")
+			}
+		}()
+	}
+	wg.Wait()
+	if *calls != 1 {
+		t.Errorf("密码提示次数 = %d, want 1", *calls)
+	}
+
+	// 密码输入失败后不再提示
+	otherKey, _ := writeTestKey(t, "other")
+	calls = stubPrompt(t, "a", "b", "c", "d")
+	other := &config.SSHConfig{KeyFile: otherKey}
+	for i := 0; i < 2; i++ {
+		var keyErr *PrivateKeyError
+		if err := keys.Preload(other); !errors.As(err, &keyErr) || !keyErr.WrongPassphrase {
+			t.Fatalf("Preload() error = %v, want wrong passphrase", err)
+		}
+	}
+	if *calls != maxPassphraseAttempts {
+		t.Errorf("密码提示次数 = %d, want %d", *calls, maxPassphraseAttempts)
+	}
+
+	// 同一个私钥文件，配置中的密码不同时分别解析
+	var keyErr *PrivateKeyError
+	if _, err := keys.signer(&config.SSHConfig{KeyFile: otherKey, KeyPassphrase: "wrong"}); !errors.As(err, &keyErr) || !keyErr.WrongPassphrase {
+		t.Fatalf("signer() error = %v, want wrong passphrase", err)
+	}
+	if _, err := keys.signer(&config.SSHConfig{KeyFile: otherKey, KeyPassphrase: "other"}); err != nil {
+		t.Errorf("signer() 使用正确的密码 error = %v", err)
+	}
+}
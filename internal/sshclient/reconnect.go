@@ -0,0 +1,337 @@
+// Package sshclient 的自动重连模块
+// ReconnectingClient 在连接断开后自动重新建立连接，并恢复通过它启动的端口转发
+// 连接状态的变化以事件的形式通知调用方
+package sshclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gossh/internal/config"
+)
+
+// 重连等待时间的默认值
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// EventType 表示重连过程中的事件类型
+type EventType int
+
+const (
+	// EventDisconnected 连接断开
+	EventDisconnected EventType = iota
+	// EventReconnecting 开始一次重连
+	EventReconnecting
+	// EventReconnected 重连成功
+	EventReconnected
+	// EventReconnectFailed 一次重连失败，稍后重试
+	EventReconnectFailed
+	// EventForwardRestored 端口转发在新连接上恢复
+	EventForwardRestored
+	// EventForwardFailed 端口转发恢复失败
+	EventForwardFailed
+	// EventGaveUp 达到最大重连次数，不再重连
+	EventGaveUp
+)
+
+// String 返回事件类型的名称
+func (t EventType) String() string {
+	switch t {
+	case EventDisconnected:
+		return "disconnected"
+	case EventReconnecting:
+		return "reconnecting"
+	case EventReconnected:
+		return "reconnected"
+	case EventReconnectFailed:
+		return "reconnect-failed"
+	case EventForwardRestored:
+		return "forward-restored"
+	case EventForwardFailed:
+		return "forward-failed"
+	case EventGaveUp:
+		return "gave-up"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 表示一次连接状态的变化
+type Event struct {
+	Type    EventType // 事件类型
+	Attempt int       // 本轮重连的第几次尝试，从 1 开始
+	Forward string    // 相关端口转发的描述，只用于转发事件
+	Err     error     // 断开、重连失败或转发失败的原因
+}
+
+// String 返回事件的描述
+func (e Event) String() string {
+	s := e.Type.String()
+	if e.Attempt > 0 {
+		s += fmt.Sprintf(" #%d", e.Attempt)
+	}
+	if e.Forward != "" {
+		s += " " + e.Forward
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// ReconnectOptions 是自动重连的参数
+type ReconnectOptions struct {
+	MaxAttempts int           // 每次断开后最多尝试的次数，为 0 时不限制
+	MinBackoff  time.Duration // 重连失败后第一次等待的时间，之后每次翻倍，为 0 时使用 1 秒
+	MaxBackoff  time.Duration // 等待时间的上限，为 0 时使用 30 秒
+	OnEvent     func(Event)   // 连接状态变化时在后台 goroutine 中调用，可以为 nil
+}
+
+// ReconnectingClient 是可以自动重连的 SSH 客户端
+// 底层连接断开后（包括保活超时）会按指数退避重新连接，并恢复通过它启动的端口转发
+// 已经在执行的命令不会被恢复，需要调用方根据事件自行重试
+type ReconnectingClient struct {
+	config *config.SSHConfig
+	opts   ReconnectOptions
+	ctx    context.Context    // 控制整个生命周期，取消时停止重连
+	cancel context.CancelFunc // 由 Close 调用
+
+	mu       sync.Mutex    // 保护 client 和 forwards
+	client   *Client       // 当前的连接
+	forwards []forwardSpec // 需要在重连后恢复的端口转发
+	err      error         // 放弃重连的原因
+	done     chan struct{} // 不再重连时关闭
+}
+
+// forwardSpec 记录重连后需要恢复的端口转发
+type forwardSpec struct {
+	typ    ForwardType
+	listen string // 实际监听的地址，重连后继续使用相同的端口
+	target string
+}
+
+// NewReconnectingClient 建立连接并返回可以自动重连的客户端
+// 第一次连接失败时直接返回错误，不会重试
+// 参数:
+//   ctx: 上下文，取消时停止重连并关闭连接
+//   cfg: SSH 连接配置，通常同时设置 ServerAliveInterval 以便及时发现断开
+//   opts: 重连参数
+// 返回值:
+//   *ReconnectingClient: 已经连接的客户端
+//   error: 如果第一次连接失败则返回错误
+func NewReconnectingClient(ctx context.Context, cfg *config.SSHConfig, opts ReconnectOptions) (*ReconnectingClient, error) {
+	client, err := NewClientContext(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+
+	r := &ReconnectingClient{
+		config: cfg,
+		opts:   opts,
+		client: client,
+		done:   make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	go r.loop()
+	return r, nil
+}
+
+// Client 返回当前的底层连接
+// 重连之后会返回新的连接，调用方不应该长期保存返回值
+// 连接断开后、重连成功之前返回的仍然是已经断开的旧连接，在它上面的操作会失败，
+// 调用方可以等待 EventReconnected 事件之后重试
+// 返回值:
+//   *Client: 当前的连接
+func (r *ReconnectingClient) Client() *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.client
+}
+
+// Run 在当前连接上执行命令，参数和返回值与 Client.Run 相同
+// 正在重连时不会等待重连完成，而是在已经断开的旧连接上返回错误
+func (r *ReconnectingClient) Run(ctx context.Context, cmd string, opts *RunOptions) (*Result, error) {
+	return r.Client().Run(ctx, cmd, opts)
+}
+
+// LocalForward 启动本地端口转发，重连后在相同的本地端口上恢复
+// 参数和返回值与 Client.LocalForward 相同，返回的 Forward 只代表当前连接上的转发，重连后由新的转发代替
+func (r *ReconnectingClient) LocalForward(localAddr, remoteAddr string) (*Forward, error) {
+	return r.startForward(forwardSpec{typ: LocalForwardType, listen: localAddr, target: remoteAddr})
+}
+
+// RemoteForward 启动远程端口转发，重连后重新请求服务器监听相同的端口
+// 参数和返回值与 Client.RemoteForward 相同，返回的 Forward 只代表当前连接上的转发，重连后由新的转发代替
+func (r *ReconnectingClient) RemoteForward(remoteAddr, localAddr string) (*Forward, error) {
+	return r.startForward(forwardSpec{typ: RemoteForwardType, listen: remoteAddr, target: localAddr})
+}
+
+// DynamicForward 启动 SOCKS 动态转发，重连后在相同的本地端口上恢复
+// 参数和返回值与 Client.DynamicForward 相同，返回的 Forward 只代表当前连接上的转发，重连后由新的转发代替
+func (r *ReconnectingClient) DynamicForward(localAddr string) (*Forward, error) {
+	return r.startForward(forwardSpec{typ: DynamicForwardType, listen: localAddr})
+}
+
+// startForward 在当前连接上启动转发，并记录下来以便重连后恢复
+// 启动远程转发需要等待服务器的应答，所以启动期间不持有锁，避免阻塞其他调用和重连
+func (r *ReconnectingClient) startForward(spec forwardSpec) (*Forward, error) {
+	client := r.Client()
+	f, err := client.startForward(r.ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录实际监听的地址，端口为 0 时重连后仍然使用分配到的端口
+	spec.listen = f.ListenAddr
+	r.mu.Lock()
+	r.forwards = append(r.forwards, spec)
+	current := r.client
+	r.mu.Unlock()
+
+	// 启动期间已经重连时，重连时恢复的转发中可能没有这一个，在新连接上补上
+	if current != client {
+		r.restoreForwards(current, []forwardSpec{spec})
+	}
+	return f, nil
+}
+
+// Close 停止重连并关闭当前连接
+// 返回值:
+//   error: 始终为 nil
+func (r *ReconnectingClient) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Done 返回一个在不再重连时关闭的通道：调用了 Close、上下文取消或者放弃重连
+func (r *ReconnectingClient) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err 返回放弃重连的原因，仍在运行或正常关闭时为 nil
+func (r *ReconnectingClient) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// loop 等待连接断开并重连，直到上下文取消或放弃重连
+func (r *ReconnectingClient) loop() {
+	defer close(r.done)
+	for {
+		client := r.Client()
+		select {
+		case <-r.ctx.Done():
+			client.Close()
+			return
+		case <-client.Done():
+		}
+
+		// 关闭旧连接上的转发，释放本地端口
+		client.Close()
+		r.emit(Event{Type: EventDisconnected})
+
+		newClient, err := r.redial()
+		if err != nil {
+			if r.ctx.Err() == nil {
+				r.mu.Lock()
+				r.err = err
+				r.mu.Unlock()
+				r.emit(Event{Type: EventGaveUp, Err: err})
+			}
+			return
+		}
+
+		r.mu.Lock()
+		r.client = newClient
+		specs := append([]forwardSpec(nil), r.forwards...)
+		r.mu.Unlock()
+
+		r.restoreForwards(newClient, specs)
+	}
+}
+
+// redial 按指数退避重新连接
+// 返回值:
+//   *Client: 新的连接
+//   error: 达到最大尝试次数或上下文取消时返回最后一次的错误
+func (r *ReconnectingClient) redial() (*Client, error) {
+	backoff := r.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		r.emit(Event{Type: EventReconnecting, Attempt: attempt})
+		client, err := NewClientContext(r.ctx, r.config)
+		if err == nil {
+			r.emit(Event{Type: EventReconnected, Attempt: attempt})
+			return client, nil
+		}
+		if r.ctx.Err() != nil {
+			return nil, r.ctx.Err()
+		}
+		r.emit(Event{Type: EventReconnectFailed, Attempt: attempt, Err: err})
+		if r.opts.MaxAttempts > 0 && attempt >= r.opts.MaxAttempts {
+			return nil, fmt.Errorf("重连 %d 次都失败: %w", attempt, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-r.ctx.Done():
+			return nil, r.ctx.Err()
+		}
+		backoff *= 2
+		if backoff > r.opts.MaxBackoff {
+			backoff = r.opts.MaxBackoff
+		}
+	}
+}
+
+// restoreForwards 在新连接上恢复端口转发
+func (r *ReconnectingClient) restoreForwards(client *Client, specs []forwardSpec) {
+	for _, spec := range specs {
+		f, err := client.startForward(r.ctx, spec)
+		if err != nil {
+			r.emit(Event{Type: EventForwardFailed, Forward: spec.String(), Err: err})
+			continue
+		}
+		r.emit(Event{Type: EventForwardRestored, Forward: f.String()})
+	}
+}
+
+// emit 通知调用方发生了事件
+func (r *ReconnectingClient) emit(e Event) {
+	if r.opts.OnEvent != nil {
+		r.opts.OnEvent(e)
+	}
+}
+
+// String 返回转发的描述，格式与 Forward.String 相同
+func (s forwardSpec) String() string {
+	if s.typ == DynamicForwardType {
+		return fmt.Sprintf("%s %s -> %s", s.typ, s.listen, dynamicTarget)
+	}
+	return fmt.Sprintf("%s %s -> %s", s.typ, s.listen, s.target)
+}
+
+// startForward 按照记录的参数在客户端上启动端口转发
+func (c *Client) startForward(ctx context.Context, spec forwardSpec) (*Forward, error) {
+	switch spec.typ {
+	case LocalForwardType:
+		return c.LocalForward(ctx, spec.listen, spec.target)
+	case RemoteForwardType:
+		return c.RemoteForward(ctx, spec.listen, spec.target)
+	case DynamicForwardType:
+		return c.DynamicForward(ctx, spec.listen)
+	default:
+		return nil, fmt.Errorf("未知的端口转发类型: %s", spec.typ)
+	}
+}
@@ -0,0 +1,151 @@
+// reconnect_test 提供自动重连的单元测试
+// 通过关闭测试服务器上的连接模拟网络中断
+package sshclient
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// collectEvents 返回接收重连事件的回调和通道
+func collectEvents() (func(Event), <-chan Event) {
+	events := make(chan Event, 100)
+	return func(e Event) { events <- e }, events
+}
+
+// waitEvent 等待指定类型的事件，返回之前收到的其他事件会被丢弃
+func waitEvent(t *testing.T, events <-chan Event, typ EventType) Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == typ {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("等待 %v 事件超时", typ)
+		}
+	}
+}
+
+// TestReconnectingClient 测试断开后自动重连，并在相同端口上恢复本地转发
+func TestReconnectingClient(t *testing.T) {
+	server := startTestServer(t, passwordServerConfig("tester", "secret"))
+	echoAddr := startEchoServer(t)
+	onEvent, events := collectEvents()
+
+	client, err := NewReconnectingClient(context.Background(), testHostConfig(server, "tester", "secret"), ReconnectOptions{
+		MinBackoff: 10 * time.Millisecond,
+		OnEvent:    onEvent,
+	})
+	if err != nil {
+		t.Fatalf("NewReconnectingClient() error = %v", err)
+	}
+	defer client.Close()
+
+	forward, err := client.LocalForward("127.0.0.1:0", echoAddr)
+	if err != nil {
+		t.Fatalf("LocalForward() error = %v", err)
+	}
+	first := client.Client()
+
+	server.DropConnections()
+	waitEvent(t, events, EventDisconnected)
+	waitEvent(t, events, EventReconnected)
+	restored := waitEvent(t, events, EventForwardRestored)
+	if !contains(restored.Forward, forward.ListenAddr) {
+		t.Errorf("恢复的转发 = %s, want 监听 %s", restored.Forward, forward.ListenAddr)
+	}
+	if client.Client() == first {
+		t.Error("重连后 Client() 仍然返回旧连接")
+	}
+
+	// 转发在原来的端口上继续工作
+	conn, err := net.Dial("tcp", forward.ListenAddr)
+	if err != nil {
+		t.Fatalf("重连后连接转发端口失败: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("again\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "again\n" {
+		t.Fatalf("重连后读取回应 = %q, %v, want again", line, err)
+	}
+
+	// 新连接上可以执行命令
+	result, err := client.Run(context.Background(), "echo ok", nil)
+	if err != nil || string(result.Stdout) != "ok\n" {
+		t.Fatalf("重连后 Run() = %v, %v", result, err)
+	}
+
+	client.Close()
+	select {
+	case <-client.Done():
+	default:
+		t.Error("Close() 之后 Done() 没有关闭")
+	}
+	if client.Err() != nil {
+		t.Errorf("正常关闭后 Err() = %v, want nil", client.Err())
+	}
+}
+
+// TestReconnectingClient_GiveUp 测试服务器无法连接时达到最大次数后放弃
+func TestReconnectingClient_GiveUp(t *testing.T) {
+	server := startTestServer(t, passwordServerConfig("tester", "secret"))
+	onEvent, events := collectEvents()
+
+	client, err := NewReconnectingClient(context.Background(), testHostConfig(server, "tester", "secret"), ReconnectOptions{
+		MaxAttempts: 2,
+		MinBackoff:  10 * time.Millisecond,
+		OnEvent:     onEvent,
+	})
+	if err != nil {
+		t.Fatalf("NewReconnectingClient() error = %v", err)
+	}
+	defer client.Close()
+
+	server.listener.Close()
+	server.DropConnections()
+
+	gaveUp := waitEvent(t, events, EventGaveUp)
+	if gaveUp.Err == nil || !contains(gaveUp.Err.Error(), "重连 2 次都失败") {
+		t.Errorf("放弃事件的错误 = %v, want 包含 重连 2 次都失败", gaveUp.Err)
+	}
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("放弃重连后 Done() 没有关闭")
+	}
+	if client.Err() == nil {
+		t.Error("放弃重连后 Err() = nil")
+	}
+}
+
+// TestEvent_String 测试事件的描述
+func TestEvent_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{name: "断开", event: Event{Type: EventDisconnected}, want: "disconnected"},
+		{name: "重连次数", event: Event{Type: EventReconnecting, Attempt: 3}, want: "reconnecting #3"},
+		{
+			name:  "转发失败",
+			event: Event{Type: EventForwardFailed, Forward: "local 127.0.0.1:8080 -> db:5432", Err: context.Canceled},
+			want:  "forward-failed local 127.0.0.1:8080 -> db:5432: context canceled",
+		},
+		{name: "未知类型", event: Event{Type: EventType(99)}, want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package sshclient 的流式输出模块
+// 把远程命令的输出在产生时交给调用方，而不是等命令结束后一次返回
+package sshclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Stream 执行远程命令，标准输出和标准错误在产生时实时写入 stdout 和 stderr
+// 适合部署脚本等长时间运行的命令，例如把进度实时输出到 CI 日志
+// 参数:
+//   ctx: 上下文，取消时终止远程命令
+//   cmd: 要执行的命令字符串
+//   stdout: 接收标准输出，为 nil 时收集到 Result.Stdout
+//   stderr: 接收标准错误，为 nil 时收集到 Result.Stderr
+//   stdin: 命令的标准输入，为 nil 时输入为空
+// 返回值:
+//   *Result: 执行结果，包含退出码、信号和耗时
+//   error: 如果无法执行命令、连接中断、超时或上下文取消则返回错误
+func (c *Client) Stream(ctx context.Context, cmd string, stdout, stderr io.Writer, stdin io.Reader) (*Result, error) {
+	return c.Run(ctx, cmd, &RunOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+}
+
+// LineWriter 把写入的数据按行拆分，每一行调用一次回调函数
+// 回调收到的行不包含结尾的换行符，可以安全地在多个 goroutine 中写入
+type LineWriter struct {
+	mu     sync.Mutex        // 保护 buf，并保证回调按顺序调用
+	buf    []byte            // 还没有遇到换行符的数据
+	onLine func(line string) // 每一行调用一次
+}
+
+// NewLineWriter 创建按行回调的 Writer
+// 参数:
+//   onLine: 每收到完整的一行调用一次，不包含换行符
+// 返回值:
+//   *LineWriter: 可以作为 Stream 或 RunOptions 的输出
+func NewLineWriter(onLine func(line string)) *LineWriter {
+	return &LineWriter{onLine: onLine}
+}
+
+// Write 实现 io.Writer 接口，遇到换行符时调用回调
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 把最后一段没有换行符的数据作为一行交给回调
+// 命令结束时 Run 会自动调用
+// 返回值:
+//   error: 始终为 nil
+func (w *LineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
+
+// flushWriter 如果 Writer 支持 Flush 则刷新缓冲的数据
+func flushWriter(w io.Writer) {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+}
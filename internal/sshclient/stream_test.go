@@ -0,0 +1,105 @@
+// stream_test 提供流式命令执行的单元测试
+package sshclient
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLineWriter 测试按行拆分写入的数据
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{name: "完整的行", writes: []string{"a\nb\n"}, want: []string{"a", "b"}},
+		{name: "跨多次写入的行", writes: []string{"he", "llo\nwor", "ld\n"}, want: []string{"hello", "world"}},
+		{name: "最后一行没有换行符", writes: []string{"a\nb"}, want: []string{"a", "b"}},
+		{name: "Windows 换行符", writes: []string{"a\r\nb\r\n"}, want: []string{"a", "b"}},
+		{name: "空行", writes: []string{"\n\n"}, want: []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			w := NewLineWriter(func(line string) { got = append(got, line) })
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(s))
+				}
+			}
+			w.Flush()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("收到的行 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClient_Stream 测试输出在命令结束之前就已经送达
+func TestClient_Stream(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	type line struct {
+		text string
+		at   time.Time
+	}
+	var mu sync.Mutex
+	var stdoutLines, stderrLines []line
+	record := func(lines *[]line) *LineWriter {
+		return NewLineWriter(func(text string) {
+			mu.Lock()
+			defer mu.Unlock()
+			*lines = append(*lines, line{text: text, at: time.Now()})
+		})
+	}
+
+	result, err := client.Stream(context.Background(),
+		"echo step1; echo warn >&2; sleep 0.5; printf step2; exit 4",
+		record(&stdoutLines), record(&stderrLines), nil)
+	finished := time.Now()
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if result.ExitCode != 4 {
+		t.Errorf("Result.ExitCode = %d, want 4", result.ExitCode)
+	}
+	if len(result.Stdout) != 0 || len(result.Stderr) != 0 {
+		t.Errorf("流式输出不应该收集到 Result，got stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+
+	if len(stdoutLines) != 2 || stdoutLines[0].text != "step1" || stdoutLines[1].text != "step2" {
+		t.Fatalf("标准输出的行 = %+v, want [step1 step2]", stdoutLines)
+	}
+	if len(stderrLines) != 1 || stderrLines[0].text != "warn" {
+		t.Fatalf("标准错误的行 = %+v, want [warn]", stderrLines)
+	}
+
+	// 第一行应该在 sleep 之前送达，而不是等命令结束
+	if early := finished.Sub(stdoutLines[0].at); early < 300*time.Millisecond {
+		t.Errorf("第一行只比命令结束早 %v，输出没有实时送达", early)
+	}
+}
+
+// TestClient_Stream_Stdin 测试流式执行时提供标准输入
+func TestClient_Stream_Stdin(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	var out strings.Builder
+	result, err := client.Stream(context.Background(), "tr a-z A-Z", &out, nil, strings.NewReader("hello\n"))
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if !result.Success() {
+		t.Errorf("Result.ExitCode = %d, want 0", result.ExitCode)
+	}
+	if out.String() != "HELLO\n" {
+		t.Errorf("标准输出 = %q, want %q", out.String(), "HELLO\n")
+	}
+}
@@ -0,0 +1,431 @@
+// testserver_test 提供测试用的进程内 SSH 服务器
+// 让需要真实握手的测试不依赖外部环境
+package sshclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer 是一个只在测试中使用的 SSH 服务器
+type testServer struct {
+	listener net.Listener      // 监听 127.0.0.1 的随机端口
+	config   *ssh.ServerConfig // 服务器配置，包含认证回调和主机密钥
+	hostKey  ssh.Signer        // 服务器的主机密钥
+
+	mu        sync.Mutex              // 保护下面的记录
+	forwarded []string                // 收到的 direct-tcpip 请求的目标地址
+	listeners map[string]net.Listener // tcpip-forward 请求创建的监听器
+	cancelled []string                // 收到的 cancel-tcpip-forward 请求的地址
+	conns     map[net.Conn]struct{}   // 当前打开的连接，用于模拟网络中断
+}
+
+// tcpipForwardPayload 是 tcpip-forward 和 cancel-tcpip-forward 请求的负载（RFC 4254 7.1）
+type tcpipForwardPayload struct {
+	Addr string
+	Port uint32
+}
+
+// forwardedTCPIPPayload 是 forwarded-tcpip 通道的负载（RFC 4254 7.2）
+type forwardedTCPIPPayload struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// directTCPIPPayload 是 direct-tcpip 通道请求的负载（RFC 4254 7.2）
+type directTCPIPPayload struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+// passwordServerConfig 返回只接受指定用户名和密码的服务器配置
+func passwordServerConfig(user, password string) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("用户名或密码错误")
+		},
+	}
+}
+
+// mustGenerateKey 生成一对用于测试的 ed25519 密钥
+// 返回值:
+//   ssh.PublicKey: SSH 格式的公钥
+//   ed25519.PrivateKey: 私钥
+func mustGenerateKey(t *testing.T) (ssh.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成测试密钥失败: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("转换测试公钥失败: %v", err)
+	}
+	return key, priv
+}
+
+// newTestSigner 生成一个用于测试的 ed25519 签名器
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv := mustGenerateKey(t)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("创建签名器失败: %v", err)
+	}
+	return signer
+}
+
+// startTestServer 启动进程内 SSH 服务器，测试结束时自动关闭
+// 参数:
+//   t: 测试对象
+//   config: 服务器配置，只需要设置认证回调，主机密钥会自动添加
+// 返回值:
+//   *testServer: 服务器对象
+func startTestServer(t *testing.T, config *ssh.ServerConfig) *testServer {
+	t.Helper()
+	hostKey := newTestSigner(t)
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动测试服务器失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &testServer{listener: listener, config: config, hostKey: hostKey}
+	go s.serve()
+	return s
+}
+
+// serve 接受连接并完成握手
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle 处理单个连接：握手后按通道类型分发
+func (s *testServer) handle(conn net.Conn) {
+	s.mu.Lock()
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go s.handleGlobalRequests(sconn, reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(newChannel)
+		case "direct-tcpip":
+			go s.handleDirectTCPIP(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "测试服务器不支持该通道类型")
+		}
+	}
+}
+
+// testSignals 是测试服务器支持的信号，名称与 RFC 4254 6.10 一致
+var testSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// handleSession 处理会话通道：支持 env、exec 和 signal 请求
+// 命令通过本地的 sh -c 执行，结束后发送 exit-status 或 exit-signal
+func (s *testServer) handleSession(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	var env []string
+	var cmd *exec.Cmd
+	exited := make(chan struct{})
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var payload struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			env = append(env, payload.Name+"="+payload.Value)
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || cmd != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			cmd = exec.Command("sh", "-c", payload.Command)
+			cmd.Env = append(os.Environ(), env...)
+			cmd.Stdin = channel
+			cmd.Stdout = channel
+			cmd.Stderr = channel.Stderr()
+			cmd.WaitDelay = time.Second
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			go func() {
+				sendExitStatus(channel, cmd.Wait())
+				channel.Close()
+				close(exited)
+			}()
+		case "signal":
+			var payload struct{ Signal string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if sig, ok := testSignals[payload.Signal]; ok && cmd != nil {
+				cmd.Process.Signal(sig)
+			}
+		default:
+			req.Reply(false, nil)
+		}
+	}
+
+	// 客户端关闭通道时结束仍在运行的命令
+	if cmd != nil {
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// sendExitStatus 根据命令的结束状态发送 exit-status 或 exit-signal
+func sendExitStatus(channel ssh.Channel, err error) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		return
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		for name, sig := range testSignals {
+			if sig == status.Signal() {
+				channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+					Signal     string
+					CoreDumped bool
+					Error      string
+					Lang       string
+				}{Signal: name}))
+				return
+			}
+		}
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(exitErr.ExitCode())}))
+}
+
+// handleDirectTCPIP 处理端口转发通道：连接目标地址并双向复制数据
+func (s *testServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload directTCPIPPayload
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "无效的请求")
+		return
+	}
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+
+	s.mu.Lock()
+	s.forwarded = append(s.forwarded, addr)
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(channel, target)
+		channel.CloseWrite()
+	}()
+	io.Copy(target, channel)
+	target.Close()
+	channel.Close()
+}
+
+// handleGlobalRequests 处理远程端口转发请求
+// 与 OpenSSH 默认的 GatewayPorts no 类似，只允许监听回环地址，连接断开时关闭这个连接创建的监听器
+func (s *testServer) handleGlobalRequests(sconn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	var owned []string
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, key := range owned {
+			if listener, ok := s.listeners[key]; ok {
+				listener.Close()
+				delete(s.listeners, key)
+			}
+		}
+	}()
+
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			var payload tcpipForwardPayload
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Addr != "127.0.0.1" {
+				req.Reply(false, nil)
+				continue
+			}
+			listener, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			_, portStr, _ := net.SplitHostPort(listener.Addr().String())
+			port, _ := strconv.Atoi(portStr)
+			key := net.JoinHostPort(payload.Addr, portStr)
+
+			s.mu.Lock()
+			if s.listeners == nil {
+				s.listeners = make(map[string]net.Listener)
+			}
+			s.listeners[key] = listener
+			s.mu.Unlock()
+			owned = append(owned, key)
+
+			// 端口为 0 时需要在回复中告诉客户端实际分配的端口
+			var reply []byte
+			if payload.Port == 0 {
+				reply = ssh.Marshal(struct{ Port uint32 }{uint32(port)})
+			}
+			req.Reply(true, reply)
+			go s.acceptForwarded(sconn, listener, payload.Addr, uint32(port))
+		case "cancel-tcpip-forward":
+			var payload tcpipForwardPayload
+			ssh.Unmarshal(req.Payload, &payload)
+			key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+
+			s.mu.Lock()
+			s.cancelled = append(s.cancelled, key)
+			listener := s.listeners[key]
+			delete(s.listeners, key)
+			s.mu.Unlock()
+
+			if listener != nil {
+				listener.Close()
+			}
+			req.Reply(listener != nil, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// acceptForwarded 接受远程转发端口上的连接，并通过 forwarded-tcpip 通道交给客户端
+func (s *testServer) acceptForwarded(sconn *ssh.ServerConn, listener net.Listener, addr string, port uint32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			origin := conn.RemoteAddr().(*net.TCPAddr)
+			payload := ssh.Marshal(&forwardedTCPIPPayload{
+				Addr:       addr,
+				Port:       port,
+				OriginAddr: origin.IP.String(),
+				OriginPort: uint32(origin.Port),
+			})
+			channel, reqs, err := sconn.OpenChannel("forwarded-tcpip", payload)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			go func() {
+				io.Copy(channel, conn)
+				channel.CloseWrite()
+			}()
+			io.Copy(conn, channel)
+			channel.Close()
+		}()
+	}
+}
+
+// DropConnections 直接关闭所有已经建立的 TCP 连接，模拟网络中断
+// 服务器继续监听，客户端可以重新连接
+func (s *testServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Cancelled 返回服务器收到的 cancel-tcpip-forward 请求的地址
+func (s *testServer) Cancelled() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.cancelled...)
+}
+
+// Forwarded 返回服务器收到的所有转发目标地址
+func (s *testServer) Forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.forwarded...)
+}
+
+// Host 返回服务器监听的主机地址
+func (s *testServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Addr 返回服务器监听的地址，格式为 "host:port"
+func (s *testServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Port 返回服务器监听的端口
+func (s *testServer) Port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
@@ -0,0 +1,163 @@
+// Package ui 的 check-file 扩展模块
+// pkg/sftp 不能发送任意的扩展请求，这里在单独的 sftp 子系统通道上实现协议的最小子集：
+// 初始化后发送一个 check-file-name 请求，让服务器计算整个文件的哈希，
+// 参见 draft-ietf-secsh-filexfer-extensions 第 3 节
+package ui
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP 协议中用到的数据包类型
+const (
+	fxpInit          = 1
+	fxpVersion       = 2
+	fxpStatus        = 101
+	fxpExtended      = 200
+	fxpExtendedReply = 201
+)
+
+// maxCheckFilePacket 是接受的响应数据包的最大长度
+const maxCheckFilePacket = 256 * 1024
+
+// checkFileSHA256 通过 check-file 扩展让服务器计算远程文件的 SHA-256
+// 参数:
+//   conn: SSH 连接，在其上打开新的 sftp 子系统通道
+//   remotePath: 远程文件路径
+// 返回值:
+//   []byte: 文件的 SHA-256 哈希
+//   error: 如果无法打开通道、服务器拒绝请求或响应无效则返回错误
+func checkFileSHA256(conn *ssh.Client, remotePath string) ([]byte, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取会话输入失败: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取会话输出失败: %w", err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, fmt.Errorf("启动 sftp 子系统失败: %w", err)
+	}
+	return checkFile(struct {
+		io.Reader
+		io.Writer
+	}{stdout, stdin}, remotePath)
+}
+
+// checkFile 在 SFTP 连接上发送 check-file-name 请求，返回整个文件的 SHA-256
+// 参数:
+//   rw: 尚未初始化的 SFTP 连接
+//   remotePath: 远程文件路径
+func checkFile(rw io.ReadWriter, remotePath string) ([]byte, error) {
+	if err := writeFxpPacket(rw, fxpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, _, err := readFxpPacket(rw)
+	if err != nil {
+		return nil, err
+	}
+	if typ != fxpVersion {
+		return nil, fmt.Errorf("SFTP 初始化失败: 收到类型为 %d 的数据包", typ)
+	}
+
+	// 起始位置和长度为 0 表示整个文件，块大小为 0 表示只计算一个哈希
+	const id = 1
+	req := binary.BigEndian.AppendUint32(nil, id)
+	req = appendFxpString(req, "check-file-name")
+	req = appendFxpString(req, remotePath)
+	req = appendFxpString(req, "sha256")
+	req = binary.BigEndian.AppendUint64(req, 0)
+	req = binary.BigEndian.AppendUint64(req, 0)
+	req = binary.BigEndian.AppendUint32(req, 0)
+	if err := writeFxpPacket(rw, fxpExtended, req); err != nil {
+		return nil, err
+	}
+
+	typ, data, err := readFxpPacket(rw)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case fxpExtendedReply:
+		// uint32 id, string "check-file", string 使用的算法, 剩余部分为哈希
+		if len(data) < 4 || binary.BigEndian.Uint32(data) != id {
+			return nil, errors.New("check-file 响应无效")
+		}
+		name, rest, ok := readFxpString(data[4:])
+		if !ok || name != "check-file" {
+			return nil, errors.New("check-file 响应无效")
+		}
+		algo, sum, ok := readFxpString(rest)
+		if !ok || algo != "sha256" || len(sum) != 32 {
+			return nil, fmt.Errorf("check-file 响应无效: 算法 %q，哈希长度 %d", algo, len(sum))
+		}
+		return sum, nil
+	case fxpStatus:
+		// uint32 id, uint32 错误码, string 错误信息
+		if len(data) < 8 {
+			return nil, errors.New("服务器拒绝了 check-file 请求")
+		}
+		msg, _, _ := readFxpString(data[8:])
+		return nil, fmt.Errorf("服务器拒绝了 check-file 请求: 错误码 %d %s", binary.BigEndian.Uint32(data[4:]), msg)
+	default:
+		return nil, fmt.Errorf("check-file 请求收到类型为 %d 的数据包", typ)
+	}
+}
+
+// writeFxpPacket 发送一个 SFTP 数据包: uint32 长度, byte 类型, 数据
+func writeFxpPacket(w io.Writer, typ byte, data []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(data)+1))
+	packet = append(packet, typ)
+	packet = append(packet, data...)
+	if _, err := w.Write(packet); err != nil {
+		return fmt.Errorf("发送 SFTP 数据包失败: %w", err)
+	}
+	return nil
+}
+
+// readFxpPacket 读取一个 SFTP 数据包，返回类型和数据
+func readFxpPacket(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("读取 SFTP 数据包失败: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > maxCheckFilePacket {
+		return 0, nil, fmt.Errorf("SFTP 数据包长度无效: %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("读取 SFTP 数据包失败: %w", err)
+	}
+	return header[4], data, nil
+}
+
+// appendFxpString 追加一个 SFTP 字符串: uint32 长度, 内容
+func appendFxpString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// readFxpString 读取一个 SFTP 字符串，返回内容和剩余的数据
+func readFxpString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, false
+	}
+	return string(b[4 : 4+n]), b[4+n:], true
+}
@@ -0,0 +1,135 @@
+// Package ui 的传输校验模块
+// 传输完成后分别计算本地和远程文件的 SHA-256 并比较。远程哈希优先通过 SFTP 的
+// check-file 扩展由服务器计算，服务器不支持时在远程执行 sha256sum，都不需要把文件再传一遍
+package ui
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/sftp"
+
+	"gossh/internal/sshclient"
+)
+
+// ChecksumMismatchError 表示传输后本地和远程文件的 SHA-256 不一致
+type ChecksumMismatchError struct {
+	LocalPath  string // 本地文件路径
+	RemotePath string // 远程文件路径
+	Local      []byte // 本地文件的 SHA-256
+	Remote     []byte // 远程文件的 SHA-256
+}
+
+// Error 实现 error 接口
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("校验失败: 本地文件 %s 的 SHA-256 为 %x，远程文件 %s 的 SHA-256 为 %x",
+		e.LocalPath, e.Local, e.RemotePath, e.Remote)
+}
+
+// remoteHasher 计算远程文件的 SHA-256
+type remoteHasher func(remotePath string) ([]byte, error)
+
+// newRemoteHasher 创建计算远程文件 SHA-256 的函数
+// 服务器声明支持 check-file 扩展时优先使用它，失败（如不支持 sha256 算法）时改为执行 sha256sum
+// 参数:
+//   client: SSH 客户端对象，用于打开新的通道
+//   sftpClient: 已经建立的 SFTP 客户端，用于查询服务器支持的扩展
+func newRemoteHasher(client *sshclient.Client, sftpClient *sftp.Client) remoteHasher {
+	_, hasCheckFile := sftpClient.HasExtension("check-file")
+	return func(remotePath string) ([]byte, error) {
+		if hasCheckFile {
+			if sum, err := checkFileSHA256(client.GetConnection(), remotePath); err == nil {
+				return sum, nil
+			}
+		}
+		return execSHA256(client, remotePath)
+	}
+}
+
+// execSHA256 在远程服务器上执行 sha256sum 计算文件的 SHA-256
+func execSHA256(client *sshclient.Client, remotePath string) ([]byte, error) {
+	result, err := client.Run(context.Background(), "sha256sum -- "+shellQuote(remotePath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("执行 sha256sum 失败: %w", err)
+	}
+	if !result.Success() {
+		return nil, fmt.Errorf("执行 sha256sum 失败: 退出码 %d %s", result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+	}
+	return parseSHA256Sum(result.Stdout)
+}
+
+// parseSHA256Sum 从 sha256sum 的输出中取出哈希
+// 文件名中有换行或反斜杠时，sha256sum 会在行首加上 "\"
+func parseSHA256Sum(output []byte) ([]byte, error) {
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return nil, errors.New("sha256sum 没有输出")
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(fields[0], "\\"))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("无法解析 sha256sum 的输出: %q", fields[0])
+	}
+	return sum, nil
+}
+
+// shellQuote 用单引号包裹字符串，使它在远程 shell 中作为一个参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// localSHA256 计算本地文件的 SHA-256
+func localSHA256(localPath string) ([]byte, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyChecksum 在传输完成后比较本地和远程文件的 SHA-256，opts.Checksum 为 false 时什么也不做
+// 参数:
+//   opts: 传输选项
+//   localPath: 本地文件路径
+//   remotePath: 远程文件路径
+// 返回值:
+//   error: 哈希不一致时返回 *ChecksumMismatchError，无法计算哈希时返回相应的错误
+func verifyChecksum(opts TransferOptions, localPath, remotePath string) error {
+	if !opts.Checksum {
+		return nil
+	}
+	if opts.hasher == nil {
+		return errors.New("无法计算远程文件的哈希: 没有可用的 SSH 连接")
+	}
+	localSum, err := localSHA256(localPath)
+	if err != nil {
+		return fmt.Errorf("计算本地文件的哈希失败: %w", err)
+	}
+	remoteSum, err := opts.hasher(remotePath)
+	if err != nil {
+		return fmt.Errorf("计算远程文件的哈希失败: %w", err)
+	}
+	if !bytes.Equal(localSum, remoteSum) {
+		return &ChecksumMismatchError{LocalPath: localPath, RemotePath: remotePath, Local: localSum, Remote: remoteSum}
+	}
+	return nil
+}
+
+// withHasher 在需要校验且还没有计算远程哈希的方法时，设置基于 client 的方法
+func withHasher(opts TransferOptions, client *sshclient.Client, sftpClient *sftp.Client) TransferOptions {
+	if opts.Checksum && opts.hasher == nil {
+		opts.hasher = newRemoteHasher(client, sftpClient)
+	}
+	return opts
+}
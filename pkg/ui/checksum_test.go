@@ -0,0 +1,278 @@
+// checksum_test 提供传输校验的单元测试
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCheckFileServer 在 conn 上模拟支持 check-file 扩展的 SFTP 服务器
+// 收到请求后检查请求的路径和算法，然后发送 reply 构造的响应
+func fakeCheckFileServer(t *testing.T, conn net.Conn, wantPath string, reply func(id uint32) (byte, []byte)) {
+	defer conn.Close()
+	typ, _, err := readFxpPacket(conn)
+	if err != nil || typ != fxpInit {
+		t.Errorf("INIT = (%d, %v), want 类型 %d", typ, err, fxpInit)
+		return
+	}
+	if err := writeFxpPacket(conn, fxpVersion, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		t.Errorf("发送 VERSION 失败: %v", err)
+		return
+	}
+
+	typ, data, err := readFxpPacket(conn)
+	if err != nil || typ != fxpExtended || len(data) < 4 {
+		t.Errorf("EXTENDED = (%d, %v), want 类型 %d", typ, err, fxpExtended)
+		return
+	}
+	id := binary.BigEndian.Uint32(data)
+	name, rest, _ := readFxpString(data[4:])
+	path, rest, _ := readFxpString(rest)
+	algo, _, _ := readFxpString(rest)
+	if name != "check-file-name" || path != wantPath || algo != "sha256" {
+		t.Errorf("请求 = %q %q %q, want check-file-name %q sha256", name, path, algo, wantPath)
+	}
+	typ, data = reply(id)
+	writeFxpPacket(conn, typ, data)
+}
+
+// TestCheckFile 测试通过 check-file 扩展获取远程文件的哈希
+func TestCheckFile(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	tests := []struct {
+		name    string
+		reply   func(id uint32) (byte, []byte)
+		wantErr string
+	}{
+		{
+			name: "成功",
+			reply: func(id uint32) (byte, []byte) {
+				data := binary.BigEndian.AppendUint32(nil, id)
+				data = appendFxpString(data, "check-file")
+				data = appendFxpString(data, "sha256")
+				return fxpExtendedReply, append(data, sum[:]...)
+			},
+		},
+		{
+			name: "服务器不支持",
+			reply: func(id uint32) (byte, []byte) {
+				data := binary.BigEndian.AppendUint32(nil, id)
+				data = binary.BigEndian.AppendUint32(data, 8)
+				return fxpStatus, appendFxpString(data, "Operation unsupported")
+			},
+			wantErr: "错误码 8 Operation unsupported",
+		},
+		{
+			name: "使用了其他算法",
+			reply: func(id uint32) (byte, []byte) {
+				data := binary.BigEndian.AppendUint32(nil, id)
+				data = appendFxpString(data, "check-file")
+				data = appendFxpString(data, "md5")
+				return fxpExtendedReply, append(data, make([]byte, 16)...)
+			},
+			wantErr: "响应无效",
+		},
+		{
+			name: "请求编号不匹配",
+			reply: func(id uint32) (byte, []byte) {
+				data := binary.BigEndian.AppendUint32(nil, id+1)
+				data = appendFxpString(data, "check-file")
+				data = appendFxpString(data, "sha256")
+				return fxpExtendedReply, append(data, sum[:]...)
+			},
+			wantErr: "响应无效",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				fakeCheckFileServer(t, server, "/data/a b.txt", tt.reply)
+			}()
+
+			got, err := checkFile(client, "/data/a b.txt")
+			<-done
+			if tt.wantErr != "" {
+				if err == nil || !contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkFile() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkFile() error = %v", err)
+			}
+			if !bytes.Equal(got, sum[:]) {
+				t.Errorf("checkFile() = %x, want %x", got, sum)
+			}
+		})
+	}
+}
+
+// TestParseSHA256Sum 测试 sha256sum 输出的解析
+func TestParseSHA256Sum(t *testing.T) {
+	const hash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+	}{
+		{name: "普通文件名", output: hash + "  /data/a.txt\n"},
+		{name: "转义的文件名", output: "\\" + hash + "  /data/a\\nb.txt\n"},
+		{name: "没有输出", output: "", wantErr: true},
+		{name: "不是哈希", output: "sha256sum: /data/a.txt: No such file or directory\n", wantErr: true},
+		{name: "长度不对", output: hash[:32] + "  a.txt\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSHA256Sum([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSHA256Sum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != string(sha256Of("hello")) {
+				t.Errorf("parseSHA256Sum() = %x, want %s", got, hash)
+			}
+		})
+	}
+}
+
+// TestShellQuote 测试远程 shell 参数的引用
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/data/a.txt", want: "'/data/a.txt'"},
+		{in: "a b; rm -rf ~", want: "'a b; rm -rf ~'"},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "", want: "''"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// sha256Of 返回字符串的 SHA-256
+func sha256Of(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+// sftpHasher 通过 SFTP 读取远程文件计算哈希，代替测试服务器上没有的 check-file 和 sha256sum
+func sftpHasher(shell *sftpShell) remoteHasher {
+	return func(remotePath string) ([]byte, error) {
+		file, err := shell.client.Open(remotePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, file); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+}
+
+// TestVerifyChecksum 测试传输后的哈希比较
+func TestVerifyChecksum(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	fixed := func(sum []byte, err error) remoteHasher {
+		return func(string) ([]byte, error) { return sum, err }
+	}
+
+	tests := []struct {
+		name         string
+		opts         TransferOptions
+		wantErr      string
+		wantMismatch bool
+	}{
+		{name: "未要求校验", opts: TransferOptions{hasher: fixed(nil, errors.New("不应该调用"))}},
+		{name: "一致", opts: TransferOptions{Checksum: true, hasher: fixed(sha256Of("hello"), nil)}},
+		{name: "不一致", opts: TransferOptions{Checksum: true, hasher: fixed(sha256Of("hell"), nil)}, wantErr: "校验失败", wantMismatch: true},
+		{name: "无法计算远程哈希", opts: TransferOptions{Checksum: true, hasher: fixed(nil, errors.New("退出码 127"))}, wantErr: "退出码 127"},
+		{name: "没有 SSH 连接", opts: TransferOptions{Checksum: true}, wantErr: "没有可用的 SSH 连接"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChecksum(tt.opts, local, "/data/a.txt")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verifyChecksum() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verifyChecksum() error = %v, want %s", err, tt.wantErr)
+			}
+			var mismatch *ChecksumMismatchError
+			if errors.As(err, &mismatch) != tt.wantMismatch {
+				t.Fatalf("errors.As(*ChecksumMismatchError) = %v, want %v", !tt.wantMismatch, tt.wantMismatch)
+			}
+			if tt.wantMismatch && (mismatch.RemotePath != "/data/a.txt" || !bytes.Equal(mismatch.Local, sha256Of("hello"))) {
+				t.Errorf("ChecksumMismatchError = %+v", mismatch)
+			}
+		})
+	}
+}
+
+// TestChecksumCommands 测试交互命令的 -checksum 选项
+func TestChecksumCommands(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	shell.hasher = sftpHasher(shell)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(local, []byte("some data"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+
+	commands := []struct {
+		name    string
+		command func(*sftpShell, []string) error
+		args    []string
+	}{
+		{name: "put", command: uploadFileCommand, args: []string{"-checksum", local}},
+		{name: "get", command: downloadFileCommand, args: []string{"-checksum", "notes.txt", dir}},
+		{name: "reput", command: resumeUploadCommand, args: []string{"-checksum", local}},
+		{name: "reget", command: resumeDownloadCommand, args: []string{"-verify", "-checksum", "notes.txt", dir}},
+		{name: "put -r", command: uploadFileCommand, args: []string{"-r", "-checksum", dir, "backup"}},
+	}
+	for _, tt := range commands {
+		if err := tt.command(shell, tt.args); err != nil {
+			t.Fatalf("%s error = %v", tt.name, err)
+		}
+	}
+	if got := readRemote(t, shell, "/home/tester/backup/data.txt"); got != "some data" {
+		t.Errorf("远程文件的内容 = %q, want \"some data\"", got)
+	}
+
+	// 远程哈希与本地不一致时传输失败
+	shell.hasher = func(string) ([]byte, error) { return sha256Of("other"), nil }
+	err := downloadFileCommand(shell, []string{"-checksum", "notes.txt", filepath.Join(dir, "copy.txt")})
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("get error = %v, want *ChecksumMismatchError", err)
+	}
+	if !bytes.Equal(mismatch.Local, sha256Of("old notes")) {
+		t.Errorf("本地哈希 = %x, want %x", mismatch.Local, sha256Of("old notes"))
+	}
+
+	// 没有 -checksum 时不计算哈希
+	if err := uploadFileCommand(shell, []string{local, "plain.txt"}); err != nil {
+		t.Errorf("put error = %v", err)
+	}
+}
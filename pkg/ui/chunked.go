@@ -0,0 +1,149 @@
+// Package ui 的并发分块传输模块
+// 高延迟链路上逐块读写一个 SFTP 文件时，大部分时间在等待每个请求的往返。
+// 这里把文件分成固定大小的块，由多个 goroutine 同时用 ReadAt/WriteAt 复制，
+// 让多个请求同时在链路上传输
+package ui
+
+import (
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultConcurrency 是默认同时传输的块数，与 pkg/sftp 每个文件默认的最大并发请求数相同
+	DefaultConcurrency = 64
+	// DefaultChunkSize 是默认的块大小，与 SFTP 默认的最大数据包大小相同，每块只需要一个请求
+	DefaultChunkSize = 32 * 1024
+	// MaxConcurrency 是允许的最大并发块数，续传按它计算需要重新传输的长度
+	MaxConcurrency = 128
+	// MaxChunkSize 是允许的最大块大小，续传按它计算需要重新传输的长度
+	MaxChunkSize = 256 * 1024
+)
+
+// truncateWriterAt 是可以按偏移量写入并截断的文件，*os.File 和 *sftp.File 都满足
+type truncateWriterAt interface {
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// chunkParams 返回实际使用的并发数和块大小，未设置时使用默认值，超过上限时使用上限
+func chunkParams(opts TransferOptions) (int, int64) {
+	concurrency, chunkSize := opts.Concurrency, opts.ChunkSize
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return min(concurrency, MaxConcurrency), min(chunkSize, MaxChunkSize)
+}
+
+// copyChunks 把 src 中 [offset, size) 的内容并发复制到 dst 的相同位置
+// 只分配连续写完的块之后 concurrency 个块以内的块，某个块卡住时其他 goroutine 等待，
+// 保证中断时空洞只出现在已写入部分末尾的 concurrency 个块以内
+// 出错时停止分配新的块，并把 dst 截断到连续写完的部分，
+// 这样目标文件的大小总是等于已经正确传输的长度，之后可以续传
+// 参数:
+//   dst: 目标文件
+//   src: 源文件
+//   offset: 开始复制的位置，续传时为目标文件已有的大小
+//   size: 源文件的大小
+//   opts: 传输选项，其中 Concurrency 和 ChunkSize 决定并发数和块大小
+//   tracker: 进度统计，可以为 nil
+// 返回值:
+//   int64: 连续写完的字节数，不包括 offset 之前的部分
+//   error: 如果读取或写入失败则返回第一个错误
+func copyChunks(dst truncateWriterAt, src io.ReaderAt, offset, size int64, opts TransferOptions, tracker *progressTracker) (int64, error) {
+	concurrency, chunkSize := chunkParams(opts)
+	if size <= offset {
+		return 0, nil
+	}
+	chunks := (size - offset + chunkSize - 1) / chunkSize
+	if int64(concurrency) > chunks {
+		concurrency = int(chunks)
+	}
+
+	var (
+		mu       sync.Mutex
+		next     int64              // 下一个要分配的块
+		done     = map[int64]bool{} // 已经写完的块
+		prefix   int64              // 从第 0 块开始连续写完的块数
+		firstErr error
+		wg       sync.WaitGroup
+		ready    = sync.NewCond(&mu) // 连续写完的块数增加或者出错时通知等待分配的 goroutine
+	)
+	// take 分配下一个块，超出窗口时等待前面的块写完，出错后或者没有剩余的块时返回 -1
+	take := func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		for firstErr == nil && next < chunks && next >= prefix+int64(concurrency) {
+			ready.Wait()
+		}
+		if firstErr != nil || next >= chunks {
+			return -1
+		}
+		next++
+		return next - 1
+	}
+	// complete 记录一个块的结果，并更新连续写完的块数
+	complete := func(chunk int64, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		defer ready.Broadcast()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		done[chunk] = true
+		for done[prefix] {
+			delete(done, prefix)
+			prefix++
+		}
+	}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, chunkSize)
+			for chunk := take(); chunk >= 0; chunk = take() {
+				off := offset + chunk*chunkSize
+				b := buf
+				if remain := size - off; remain < chunkSize {
+					b = buf[:remain]
+				}
+				complete(chunk, copyChunk(dst, src, b, off, tracker))
+			}
+		}()
+	}
+	wg.Wait()
+
+	written := prefix * chunkSize
+	if offset+written > size {
+		written = size - offset
+	}
+	if firstErr != nil {
+		// 丢弃连续部分之后零散写完的块，失败时尽力而为，连接已经断开时无法截断远程文件
+		dst.Truncate(offset + written)
+		return written, firstErr
+	}
+	return written, nil
+}
+
+// copyChunk 把 src 中从 off 开始的 len(b) 个字节复制到 dst 的相同位置
+func copyChunk(dst io.WriterAt, src io.ReaderAt, b []byte, off int64, tracker *progressTracker) error {
+	n, err := src.ReadAt(b, off)
+	if n < len(b) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF // 源文件在传输过程中变小了
+		}
+		return err
+	}
+	if _, err := dst.WriteAt(b, off); err != nil {
+		return err
+	}
+	tracker.add(len(b))
+	return nil
+}
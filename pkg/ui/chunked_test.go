@@ -0,0 +1,374 @@
+// chunked_test 提供并发分块传输的单元测试和基准测试
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// latencyConn 把每次写入的数据延迟 delay 之后再发送，模拟高延迟的链路
+type latencyConn struct {
+	net.Conn
+	delay time.Duration
+	queue chan delayedWrite
+	done  chan struct{}
+	once  sync.Once
+}
+
+// delayedWrite 是等待发送的一次写入
+type delayedWrite struct {
+	data []byte
+	at   time.Time
+}
+
+func newLatencyConn(conn net.Conn, delay time.Duration) *latencyConn {
+	l := &latencyConn{Conn: conn, delay: delay, queue: make(chan delayedWrite, 1024), done: make(chan struct{})}
+	go l.run()
+	return l
+}
+
+func (l *latencyConn) run() {
+	for {
+		select {
+		case w := <-l.queue:
+			time.Sleep(time.Until(w.at))
+			if _, err := l.Conn.Write(w.data); err != nil {
+				return
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *latencyConn) Write(p []byte) (int, error) {
+	w := delayedWrite{data: append([]byte(nil), p...), at: time.Now().Add(l.delay)}
+	select {
+	case l.queue <- w:
+		return len(p), nil
+	case <-l.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (l *latencyConn) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.Conn.Close()
+}
+
+// newLatencySFTPClient 启动以临时目录为工作目录的 SFTP 服务器，客户端和服务器之间的单向延迟为 delay
+// 内存中的 SFTP 服务器会模拟写入的耗时，不适合比较吞吐量，这里使用本地文件系统
+func newLatencySFTPClient(tb testing.TB, delay time.Duration) *sftp.Client {
+	tb.Helper()
+	serverConn, clientConn := net.Pipe()
+	server, err := sftp.NewServer(newLatencyConn(serverConn, delay), sftp.WithServerWorkingDirectory(tb.TempDir()))
+	if err != nil {
+		tb.Fatalf("创建 SFTP 服务器失败: %v", err)
+	}
+	go server.Serve()
+	tb.Cleanup(func() { server.Close() })
+
+	client, err := sftp.NewClientPipe(clientConn, newLatencyConn(clientConn, delay))
+	if err != nil {
+		tb.Fatalf("创建 SFTP 客户端失败: %v", err)
+	}
+	tb.Cleanup(func() { client.Close() })
+	return client
+}
+
+// benchmarkSize 是基准测试传输的文件大小
+const benchmarkSize = 4 << 20
+
+// benchmarkFile 在临时目录中创建 benchmarkSize 字节的文件
+func benchmarkFile(b *testing.B) string {
+	b.Helper()
+	p := filepath.Join(b.TempDir(), "data.bin")
+	if err := os.WriteFile(p, bytes.Repeat([]byte("0123456789abcdef"), benchmarkSize/16), 0644); err != nil {
+		b.Fatalf("创建本地文件失败: %v", err)
+	}
+	return p
+}
+
+// BenchmarkUpload 比较逐块上传（File.ReadFrom）和并发分块上传在 1ms 单向延迟下的吞吐量
+func BenchmarkUpload(b *testing.B) {
+	local := benchmarkFile(b)
+	run := func(b *testing.B, upload func(*sftp.File, *os.File) error) {
+		client := newLatencySFTPClient(b, time.Millisecond)
+		b.SetBytes(benchmarkSize)
+		for i := 0; i < b.N; i++ {
+			localFile, err := os.Open(local)
+			if err != nil {
+				b.Fatal(err)
+			}
+			remoteFile, err := client.Create("data.bin")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := upload(remoteFile, localFile); err != nil {
+				b.Fatal(err)
+			}
+			remoteFile.Close()
+			localFile.Close()
+		}
+	}
+	b.Run("ReadFrom", func(b *testing.B) {
+		run(b, func(dst *sftp.File, src *os.File) error {
+			_, err := dst.ReadFrom(src)
+			return err
+		})
+	})
+	b.Run("Chunked", func(b *testing.B) {
+		run(b, func(dst *sftp.File, src *os.File) error {
+			_, err := copyChunks(dst, src, 0, benchmarkSize, TransferOptions{}, nil)
+			return err
+		})
+	})
+}
+
+// BenchmarkDownload 比较 File.WriteTo 和并发分块下载在 1ms 单向延迟下的吞吐量
+func BenchmarkDownload(b *testing.B) {
+	local := benchmarkFile(b)
+	client := newLatencySFTPClient(b, time.Millisecond)
+	localFile, err := os.Open(local)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer localFile.Close()
+	remoteFile, err := client.Create("data.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := copyChunks(remoteFile, localFile, 0, benchmarkSize, TransferOptions{}, nil); err != nil {
+		b.Fatal(err)
+	}
+	remoteFile.Close()
+
+	run := func(b *testing.B, download func(*os.File, *sftp.File) error) {
+		b.SetBytes(benchmarkSize)
+		for i := 0; i < b.N; i++ {
+			remoteFile, err := client.Open("data.bin")
+			if err != nil {
+				b.Fatal(err)
+			}
+			localFile, err := os.Create(filepath.Join(b.TempDir(), "copy.bin"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := download(localFile, remoteFile); err != nil {
+				b.Fatal(err)
+			}
+			localFile.Close()
+			remoteFile.Close()
+		}
+	}
+	b.Run("WriteTo", func(b *testing.B) {
+		run(b, func(dst *os.File, src *sftp.File) error {
+			_, err := src.WriteTo(dst)
+			return err
+		})
+	})
+	b.Run("Chunked", func(b *testing.B) {
+		run(b, func(dst *os.File, src *sftp.File) error {
+			_, err := copyChunks(dst, src, 0, benchmarkSize, TransferOptions{}, nil)
+			return err
+		})
+	})
+}
+
+// failingReaderAt 从 failAt 开始的读取都返回错误
+type failingReaderAt struct {
+	data   []byte
+	failAt int64
+}
+
+func (f *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.failAt {
+		return 0, errors.New("读取失败")
+	}
+	return bytes.NewReader(f.data).ReadAt(p, off)
+}
+
+// TestCopyChunks 测试分块复制的范围和结果
+func TestCopyChunks(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	tests := []struct {
+		name     string
+		existing string // 目标文件已有的内容
+		offset   int64
+		opts     TransferOptions
+	}{
+		{name: "默认参数", opts: TransferOptions{}},
+		{name: "多个块并发", opts: TransferOptions{Concurrency: 4, ChunkSize: 5}},
+		{name: "逐块复制", opts: TransferOptions{Concurrency: 1, ChunkSize: 7}},
+		{name: "从中间继续", existing: "0123456789", offset: 10, opts: TransferOptions{Concurrency: 3, ChunkSize: 4}},
+		{name: "已经完整", existing: string(data), offset: int64(len(data)), opts: TransferOptions{ChunkSize: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "dst")
+			if err := os.WriteFile(p, []byte(tt.existing), 0644); err != nil {
+				t.Fatalf("创建目标文件失败: %v", err)
+			}
+			dst, err := os.OpenFile(p, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("打开目标文件失败: %v", err)
+			}
+			defer dst.Close()
+
+			n, err := copyChunks(dst, bytes.NewReader(data), tt.offset, int64(len(data)), tt.opts, nil)
+			if err != nil {
+				t.Fatalf("copyChunks() error = %v", err)
+			}
+			if want := int64(len(data)) - tt.offset; n != want {
+				t.Errorf("copyChunks() = %d, want %d", n, want)
+			}
+			if got, _ := os.ReadFile(p); string(got) != string(data) {
+				t.Errorf("目标文件的内容 = %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+// TestCopyChunks_Error 测试出错时把目标文件截断到连续写完的部分
+func TestCopyChunks_Error(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+	for _, concurrency := range []int{1, 4} {
+		p := filepath.Join(t.TempDir(), "dst")
+		dst, err := os.Create(p)
+		if err != nil {
+			t.Fatalf("创建目标文件失败: %v", err)
+		}
+		src := &failingReaderAt{data: data, failAt: 45}
+		n, err := copyChunks(dst, src, 0, int64(len(data)), TransferOptions{Concurrency: concurrency, ChunkSize: 10}, nil)
+		dst.Close()
+		if err == nil {
+			t.Fatalf("并发数 %d: copyChunks() error = nil, want 读取失败", concurrency)
+		}
+		// 第 5 块 [40, 50) 读取失败，之前的 4 块是连续写完的
+		if n != 40 {
+			t.Errorf("并发数 %d: copyChunks() = %d, want 40", concurrency, n)
+		}
+		if info, err := os.Stat(p); err != nil || info.Size() != n {
+			t.Errorf("并发数 %d: 目标文件大小 = %v (%v), want %d", concurrency, info.Size(), err, n)
+		}
+	}
+}
+
+// blockingReaderAt 读取 blockAt 所在的块时等待 release 关闭
+type blockingReaderAt struct {
+	data    []byte
+	blockAt int64
+	blocked chan struct{} // 开始等待时关闭
+	release chan struct{}
+}
+
+func (b *blockingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off == b.blockAt {
+		close(b.blocked)
+		<-b.release
+	}
+	return bytes.NewReader(b.data).ReadAt(p, off)
+}
+
+// recordingWriterAt 在内存中保存写入的内容，并记录写到的最大偏移量
+type recordingWriterAt struct {
+	mu   sync.Mutex
+	data []byte
+	end  int64
+}
+
+func (w *recordingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if need := off + int64(len(p)); need > int64(len(w.data)) {
+		w.data = append(w.data, make([]byte, need-int64(len(w.data)))...)
+	}
+	copy(w.data[off:], p)
+	w.end = max(w.end, off+int64(len(p)))
+	return len(p), nil
+}
+
+func (w *recordingWriterAt) Truncate(size int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.data = w.data[:size]
+	return nil
+}
+
+// maxEnd 返回写到的最大偏移量
+func (w *recordingWriterAt) maxEnd() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.end
+}
+
+// TestCopyChunks_Window 测试一个块卡住时，其他块不会写到窗口之外
+func TestCopyChunks_Window(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	opts := TransferOptions{Concurrency: 3, ChunkSize: 10}
+	src := &blockingReaderAt{data: data, blockAt: 20, blocked: make(chan struct{}), release: make(chan struct{})}
+	dst := &recordingWriterAt{}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := copyChunks(dst, src, 0, int64(len(data)), opts, nil)
+		result <- err
+	}()
+
+	// 第 2 块卡住时连续写完 2 块，窗口是第 2 到第 4 块，最多写到 50
+	<-src.blocked
+	time.Sleep(50 * time.Millisecond)
+	if end := dst.maxEnd(); end > 50 {
+		t.Errorf("第 2 块卡住时写到了 %d, want 不超过 50", end)
+	}
+
+	close(src.release)
+	if err := <-result; err != nil {
+		t.Fatalf("copyChunks() error = %v", err)
+	}
+	if !bytes.Equal(dst.data, data) {
+		t.Errorf("目标的内容 = %q, want %q", dst.data, data)
+	}
+}
+
+// TestTransferChunked 测试通过 SFTP 分块上传和下载
+func TestTransferChunked(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	opts := TransferOptions{Concurrency: 3, ChunkSize: 1000}
+	data := make([]byte, 10500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	local := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	localFile, err := os.Open(local)
+	if err != nil {
+		t.Fatalf("打开本地文件失败: %v", err)
+	}
+	defer localFile.Close()
+
+	if n, err := uploadFile(shell.client, localFile, "/home/tester/data.bin", opts); err != nil || n != int64(len(data)) {
+		t.Fatalf("uploadFile() = (%d, %v), want %d", n, err, len(data))
+	}
+	if got := readRemote(t, shell, "/home/tester/data.bin"); got != string(data) {
+		t.Error("上传后远程文件的内容不一致")
+	}
+
+	copyPath := filepath.Join(t.TempDir(), "copy.bin")
+	if n, err := downloadFile(shell.client, "/home/tester/data.bin", copyPath, opts); err != nil || n != int64(len(data)) {
+		t.Fatalf("downloadFile() = (%d, %v), want %d", n, err, len(data))
+	}
+	if got, _ := os.ReadFile(copyPath); !bytes.Equal(got, data) {
+		t.Error("下载后本地文件的内容不一致")
+	}
+}
@@ -0,0 +1,408 @@
+// Package ui 的转义命令模块
+// 与 OpenSSH 一样，在行首输入转义字符（默认 ~）再输入一个字符可以控制会话：
+// ~. 断开连接、~^Z 挂起、~# 列出端口转发、~C 打开命令行、~? 显示帮助
+// 网络中断导致会话没有响应时，~. 是不关闭终端就能退出的唯一办法
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/term"
+
+	"gossh/internal/config"
+	"gossh/internal/sshclient"
+)
+
+// DefaultEscapeChar 是默认的转义字符
+const DefaultEscapeChar byte = '~'
+
+// ErrEscapeDisconnect 表示用户通过 ~. 断开了连接
+var ErrEscapeDisconnect = errors.New("已通过转义命令断开连接")
+
+// ParseEscapeChar 解析转义字符参数
+// 参数:
+//   s: 单个字符（如 "~"）、控制字符（如 "^]"）或者 "none"
+// 返回值:
+//   byte: 转义字符，"none" 时为 0 表示禁用转义命令
+//   error: 如果格式无效则返回错误
+func ParseEscapeChar(s string) (byte, error) {
+	switch {
+	case s == "none":
+		return 0, nil
+	case len(s) == 1 && s[0] >= 0x20 && s[0] < 0x7f:
+		return s[0], nil
+	case len(s) == 2 && s[0] == '^':
+		c := s[1]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c >= '@' && c <= '_' {
+			return c & 0x1f, nil
+		}
+	}
+	return 0, fmt.Errorf("无效的转义字符: %s", s)
+}
+
+// escapeCharName 返回转义字符的显示形式，控制字符显示为 "^X"
+func escapeCharName(c byte) string {
+	if c < 0x20 {
+		return "^" + string(rune(c+'@'))
+	}
+	return string(rune(c))
+}
+
+// escapeAction 表示识别出的转义命令
+type escapeAction int
+
+const (
+	escapeNone         escapeAction = iota // 没有转义命令
+	escapeDisconnect                       // ~. 断开连接
+	escapeSuspend                          // ~^Z 挂起
+	escapeListForwards                     // ~# 列出端口转发
+	escapeCommandLine                      // ~C 打开命令行
+	escapeHelp                             // ~? 显示帮助
+)
+
+// escapeScanner 在输入中识别转义命令
+// 转义字符只在行首（会话开始或者回车、换行之后）才有特殊含义
+type escapeScanner struct {
+	char      byte // 转义字符
+	lineStart bool // 下一个字符是否在行首
+	pending   bool // 已经在行首收到转义字符，等待下一个字符
+}
+
+// newEscapeScanner 创建识别指定转义字符的扫描器
+func newEscapeScanner(char byte) *escapeScanner {
+	return &escapeScanner{char: char, lineStart: true}
+}
+
+// scan 处理一段输入，遇到转义命令时立即返回
+// 转义字符后面跟着的不是命令时，与 OpenSSH 一样把两个字符都发送出去；连续两个转义字符发送一个
+// 参数:
+//   in: 从终端读到的输入
+// 返回值:
+//   []byte: 需要发送到远程的数据
+//   escapeAction: 识别出的转义命令，没有时为 escapeNone
+//   []byte: 转义命令之后还没有处理的输入
+func (s *escapeScanner) scan(in []byte) ([]byte, escapeAction, []byte) {
+	var out []byte
+	for i, b := range in {
+		if s.pending {
+			s.pending = false
+			var action escapeAction
+			switch b {
+			case '.':
+				action = escapeDisconnect
+			case 0x1a: // Ctrl+Z
+				action = escapeSuspend
+			case '#':
+				action = escapeListForwards
+			case 'C':
+				action = escapeCommandLine
+			case '?':
+				action = escapeHelp
+			case s.char:
+				out = append(out, b)
+				s.lineStart = false
+				continue
+			default:
+				out = append(out, s.char, b)
+				s.lineStart = b == '\r' || b == '\n'
+				continue
+			}
+			s.lineStart = true
+			return out, action, in[i+1:]
+		}
+
+		if s.lineStart && b == s.char {
+			s.pending = true
+			continue
+		}
+		out = append(out, b)
+		s.lineStart = b == '\r' || b == '\n'
+	}
+	return out, escapeNone, nil
+}
+
+// forwardCommand 是 ~C 命令行中的一条端口转发命令
+type forwardCommand struct {
+	cancel bool                  // true 表示取消转发（-K）
+	typ    sshclient.ForwardType // 转发类型
+	listen string                // 监听地址，取消时为要取消的地址
+	target string                // 转发目标，只用于添加本地和远程转发
+}
+
+// parseForwardCommand 解析 ~C 命令行
+// 格式与 OpenSSH 相同：-L、-R、-D 添加转发，-KL、-KR、-KD 取消转发，选项和参数之间可以有空格
+// 参数:
+//   line: 用户输入的命令行
+// 返回值:
+//   *forwardCommand: 解析后的命令
+//   error: 如果格式无效则返回错误
+func parseForwardCommand(line string) (*forwardCommand, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "-") || len(line) < 2 {
+		return nil, fmt.Errorf("不支持的命令: %s（输入 ? 查看帮助）", line)
+	}
+
+	cmd := &forwardCommand{}
+	opt := line[1:]
+	if strings.HasPrefix(opt, "K") {
+		cmd.cancel = true
+		opt = opt[1:]
+	}
+	if opt == "" {
+		return nil, fmt.Errorf("不支持的命令: %s（输入 ? 查看帮助）", line)
+	}
+	letter, arg := opt[0], strings.TrimSpace(opt[1:])
+	if arg == "" {
+		return nil, fmt.Errorf("命令 %s 缺少转发参数", line)
+	}
+
+	switch letter {
+	case 'L':
+		cmd.typ = sshclient.LocalForwardType
+	case 'R':
+		cmd.typ = sshclient.RemoteForwardType
+	case 'D':
+		cmd.typ = sshclient.DynamicForwardType
+	default:
+		return nil, fmt.Errorf("不支持的命令: %s（输入 ? 查看帮助）", line)
+	}
+
+	// 取消转发和动态转发的参数格式都是 [bind_address:]port
+	if cmd.cancel || cmd.typ == sshclient.DynamicForwardType {
+		addr, err := config.ParseDynamicForwardSpec(arg)
+		if err != nil {
+			return nil, err
+		}
+		cmd.listen = addr
+		return cmd, nil
+	}
+
+	spec, err := config.ParseForwardSpec(arg)
+	if err != nil {
+		return nil, err
+	}
+	cmd.listen, cmd.target = spec.ListenAddr, spec.TargetAddr
+	return cmd, nil
+}
+
+// matchListenAddr 判断转发实际的监听地址是否与用户输入的地址相同
+// 端口必须相同；用户输入的主机是 localhost 时匹配任何回环地址
+func matchListenAddr(actual, want string) bool {
+	actualHost, actualPort, err := net.SplitHostPort(actual)
+	if err != nil {
+		return false
+	}
+	wantHost, wantPort, err := net.SplitHostPort(want)
+	if err != nil || actualPort != wantPort {
+		return false
+	}
+	if actualHost == wantHost {
+		return true
+	}
+	ip := net.ParseIP(actualHost)
+	return wantHost == "localhost" && ip != nil && ip.IsLoopback()
+}
+
+// runForwardCommand 在客户端上执行端口转发命令
+// 新的转发一直运行到连接关闭
+// 返回值:
+//   string: 给用户看的结果
+//   error: 如果转发失败或者找不到要取消的转发则返回错误
+func runForwardCommand(client *sshclient.Client, cmd *forwardCommand) (string, error) {
+	if cmd.cancel {
+		for _, f := range client.Forwards() {
+			if f.Type == cmd.typ && matchListenAddr(f.ListenAddr, cmd.listen) {
+				f.Close()
+				return fmt.Sprintf("已取消 %s", f), nil
+			}
+		}
+		return "", fmt.Errorf("没有找到监听 %s 的 %s 转发", cmd.listen, cmd.typ)
+	}
+
+	var f *sshclient.Forward
+	var err error
+	switch cmd.typ {
+	case sshclient.LocalForwardType:
+		f, err = client.LocalForward(context.Background(), cmd.listen, cmd.target)
+	case sshclient.RemoteForwardType:
+		f, err = client.RemoteForward(context.Background(), cmd.listen, cmd.target)
+	default:
+		f, err = client.DynamicForward(context.Background(), cmd.listen)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已启动 %s", f), nil
+}
+
+// escapeHelpText 返回 ~? 显示的帮助
+func escapeHelpText(c byte) string {
+	e := escapeCharName(c)
+	return "支持的转义命令（只在行首有效）:\n" +
+		"  " + e + ".   断开连接\n" +
+		"  " + e + "^Z  挂起程序\n" +
+		"  " + e + "#   列出端口转发\n" +
+		"  " + e + "C   打开命令行，添加或取消端口转发\n" +
+		"  " + e + "?   显示这个帮助\n" +
+		"  " + e + e + "   发送转义字符本身\n"
+}
+
+// commandLineHelp 是 ~C 命令行中输入 ? 时显示的帮助
+const commandLineHelp = "命令:\n" +
+	"  -L[bind_address:]port:host:hostport  添加本地端口转发\n" +
+	"  -R[bind_address:]port:host:hostport  添加远程端口转发\n" +
+	"  -D[bind_address:]port                添加动态端口转发\n" +
+	"  -KL[bind_address:]port               取消本地端口转发\n" +
+	"  -KR[bind_address:]port               取消远程端口转发\n" +
+	"  -KD[bind_address:]port               取消动态端口转发\n"
+
+// escapeInput 把本地终端的输入发送到远程，并处理其中的转义命令
+type escapeInput struct {
+	client  *sshclient.Client
+	resize  func(width, height int) // 把新的窗口大小通知远程
+	term    *rawTerminal
+	scanner *escapeScanner
+	stdin   io.Reader
+	output  io.Writer // 提示信息的输出，通常是标准错误
+
+	disconnected int32 // 是否已经通过 ~. 断开连接
+}
+
+// copy 把输入复制到远程，直到输入结束、远程关闭或者用户断开连接
+// 参数:
+//   remote: 会话的标准输入，返回时关闭
+func (e *escapeInput) copy(remote io.WriteCloser) {
+	defer remote.Close()
+	buf := make([]byte, 4096)
+	for {
+		n, err := e.stdin.Read(buf)
+		data := buf[:n]
+		for len(data) > 0 {
+			out, action, rest := e.scanner.scan(data)
+			if len(out) > 0 {
+				if _, err := remote.Write(out); err != nil {
+					return
+				}
+			}
+			switch action {
+			case escapeNone:
+			case escapeDisconnect:
+				// 只关闭 SSH 连接让会话结束，客户端由调用方关闭
+				atomic.StoreInt32(&e.disconnected, 1)
+				e.client.GetConnection().Close()
+				return
+			case escapeCommandLine:
+				rest = e.commandLine(rest)
+			default:
+				e.handle(action)
+			}
+			data = rest
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Disconnected 检查用户是否通过 ~. 断开了连接
+func (e *escapeInput) Disconnected() bool {
+	return atomic.LoadInt32(&e.disconnected) == 1
+}
+
+// handle 执行不需要读取额外输入的转义命令
+func (e *escapeInput) handle(action escapeAction) {
+	switch action {
+	case escapeSuspend:
+		e.term.Restore()
+		if err := suspendSelf(); err != nil {
+			e.printf("\n%v\n", err)
+		}
+		// 从挂起恢复后窗口大小可能已经变化
+		e.term.Raw()
+		if width, height, err := term.GetSize(e.term.fd); err == nil {
+			e.resize(width, height)
+		}
+	case escapeListForwards:
+		forwards := e.client.Forwards()
+		if len(forwards) == 0 {
+			e.printf("\n没有端口转发\n")
+			return
+		}
+		e.printf("\n端口转发:\n")
+		for _, f := range forwards {
+			e.printf("  %s (活动连接 %d，总连接 %d)\n", f, f.Active(), f.Total())
+		}
+	case escapeHelp:
+		e.printf("\n%s", escapeHelpText(e.scanner.char))
+	}
+}
+
+// commandLine 临时恢复终端，读取并执行一行 ~C 命令
+// 参数:
+//   pending: 转义命令之后已经读到的输入，作为命令行的开头
+// 返回值:
+//   []byte: 命令行之后剩余的输入
+func (e *escapeInput) commandLine(pending []byte) []byte {
+	e.term.Restore()
+	defer e.term.Raw()
+
+	fmt.Fprint(e.output, "\nssh> ")
+	line, rest := e.readLine(pending)
+	line = strings.TrimSpace(line)
+	switch line {
+	case "":
+	case "?", "help":
+		fmt.Fprint(e.output, commandLineHelp)
+	default:
+		msg := ""
+		cmd, err := parseForwardCommand(line)
+		if err == nil {
+			msg, err = runForwardCommand(e.client, cmd)
+		}
+		if err != nil {
+			fmt.Fprintf(e.output, "错误: %v\n", err)
+		} else {
+			fmt.Fprintln(e.output, msg)
+		}
+	}
+	return rest
+}
+
+// readLine 读取一行输入，不会读取换行之后的数据，避免吞掉之后发送给远程的输入
+func (e *escapeInput) readLine(pending []byte) (string, []byte) {
+	var line []byte
+	one := make([]byte, 1)
+	for {
+		if len(pending) == 0 {
+			n, err := e.stdin.Read(one)
+			if n == 0 {
+				if err != nil {
+					return string(line), nil
+				}
+				continue
+			}
+			pending = one[:n]
+		}
+		c := pending[0]
+		pending = pending[1:]
+		if c == '\n' || c == '\r' {
+			return string(line), pending
+		}
+		line = append(line, c)
+	}
+}
+
+// printf 在原始模式下输出提示信息，换行需要写成 "\r\n"
+func (e *escapeInput) printf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprint(e.output, strings.ReplaceAll(msg, "\n", "\r\n"))
+}
@@ -0,0 +1,179 @@
+// escape_test 提供转义命令的单元测试
+package ui
+
+import (
+	"testing"
+
+	"gossh/internal/sshclient"
+)
+
+// TestParseEscapeChar 测试转义字符参数的解析
+func TestParseEscapeChar(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    byte
+		wantErr bool
+	}{
+		{name: "默认的波浪号", input: "~", want: '~'},
+		{name: "普通字符", input: "%", want: '%'},
+		{name: "控制字符", input: "^]", want: 0x1d},
+		{name: "小写的控制字符", input: "^a", want: 0x01},
+		{name: "禁用", input: "none", want: 0},
+		{name: "空字符串", input: "", wantErr: true},
+		{name: "多个字符", input: "ab", wantErr: true},
+		{name: "无效的控制字符", input: "^1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEscapeChar(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEscapeChar(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err != nil {
+				if !contains(err.Error(), "无效的转义字符") {
+					t.Errorf("ParseEscapeChar(%q) error = %v, want 无效的转义字符", tt.input, err)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseEscapeChar(%q) = %#x, want %#x", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEscapeCharName 测试转义字符的显示形式
+func TestEscapeCharName(t *testing.T) {
+	if got := escapeCharName('~'); got != "~" {
+		t.Errorf("escapeCharName('~') = %q, want \"~\"", got)
+	}
+	if got := escapeCharName(0x1d); got != "^]" {
+		t.Errorf("escapeCharName(0x1d) = %q, want \"^]\"", got)
+	}
+}
+
+// TestEscapeScanner 测试转义命令的识别
+func TestEscapeScanner(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputs     []string // 依次扫描的输入，模拟多次读取
+		wantOut    string
+		wantAction escapeAction
+		wantRest   string
+	}{
+		{name: "普通输入", inputs: []string{"ls -l\r"}, wantOut: "ls -l\r"},
+		{name: "会话开始时断开", inputs: []string{"~."}, wantAction: escapeDisconnect},
+		{name: "回车之后断开", inputs: []string{"exit\r~."}, wantOut: "exit\r", wantAction: escapeDisconnect},
+		{name: "换行之后断开", inputs: []string{"a\n~."}, wantOut: "a\n", wantAction: escapeDisconnect},
+		{name: "不在行首不识别", inputs: []string{"a~."}, wantOut: "a~."},
+		{name: "分两次读取", inputs: []string{"\r~", "."}, wantOut: "\r", wantAction: escapeDisconnect},
+		{name: "挂起", inputs: []string{"~\x1a"}, wantAction: escapeSuspend},
+		{name: "列出转发", inputs: []string{"~#"}, wantAction: escapeListForwards},
+		{name: "命令行和剩余输入", inputs: []string{"~C-L 8080:web:80\r"}, wantAction: escapeCommandLine, wantRest: "-L 8080:web:80\r"},
+		{name: "帮助", inputs: []string{"~?"}, wantAction: escapeHelp},
+		{name: "连续两个转义字符发送一个", inputs: []string{"~~."}, wantOut: "~."},
+		{name: "不是命令时原样发送", inputs: []string{"~x"}, wantOut: "~x"},
+		{name: "转义字符后回车仍在行首", inputs: []string{"~\r~."}, wantOut: "~\r", wantAction: escapeDisconnect},
+		{name: "命令之后仍在行首", inputs: []string{"~#", "~."}, wantAction: escapeDisconnect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newEscapeScanner('~')
+			var out []byte
+			var action escapeAction
+			var rest []byte
+			for _, in := range tt.inputs {
+				o, a, r := s.scan([]byte(in))
+				out = append(out, o...)
+				action, rest = a, r
+			}
+			if string(out) != tt.wantOut || action != tt.wantAction || string(rest) != tt.wantRest {
+				t.Errorf("scan() = (%q, %d, %q), want (%q, %d, %q)",
+					out, action, rest, tt.wantOut, tt.wantAction, tt.wantRest)
+			}
+		})
+	}
+}
+
+// TestParseForwardCommand 测试 ~C 命令行的解析
+func TestParseForwardCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    forwardCommand
+		wantErr string
+	}{
+		{
+			name: "本地转发",
+			line: "-L 8080:web:80",
+			want: forwardCommand{typ: sshclient.LocalForwardType, listen: "localhost:8080", target: "web:80"},
+		},
+		{
+			name: "选项和参数之间没有空格",
+			line: "-R0.0.0.0:9000:localhost:3000",
+			want: forwardCommand{typ: sshclient.RemoteForwardType, listen: "0.0.0.0:9000", target: "localhost:3000"},
+		},
+		{
+			name: "动态转发",
+			line: "-D 1080",
+			want: forwardCommand{typ: sshclient.DynamicForwardType, listen: "localhost:1080"},
+		},
+		{
+			name: "取消本地转发",
+			line: "-KL 8080",
+			want: forwardCommand{cancel: true, typ: sshclient.LocalForwardType, listen: "localhost:8080"},
+		},
+		{
+			name: "取消远程转发",
+			line: " -KR 0.0.0.0:9000 ",
+			want: forwardCommand{cancel: true, typ: sshclient.RemoteForwardType, listen: "0.0.0.0:9000"},
+		},
+		{name: "不是选项", line: "ls", wantErr: "不支持的命令"},
+		{name: "未知选项", line: "-X 8080", wantErr: "不支持的命令"},
+		{name: "只有 -K", line: "-K", wantErr: "不支持的命令"},
+		{name: "缺少参数", line: "-L", wantErr: "缺少转发参数"},
+		{name: "无效的转发参数", line: "-L 8080", wantErr: "无效的端口转发参数"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseForwardCommand(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseForwardCommand(%q) error = %v, want %s", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseForwardCommand(%q) error = %v", tt.line, err)
+			}
+			if *got != tt.want {
+				t.Errorf("parseForwardCommand(%q) = %+v, want %+v", tt.line, *got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatchListenAddr 测试取消转发时监听地址的匹配
+func TestMatchListenAddr(t *testing.T) {
+	tests := []struct {
+		name   string
+		actual string
+		want   string
+		match  bool
+	}{
+		{name: "完全相同", actual: "0.0.0.0:9000", want: "0.0.0.0:9000", match: true},
+		{name: "localhost 匹配 IPv4 回环地址", actual: "127.0.0.1:8080", want: "localhost:8080", match: true},
+		{name: "localhost 匹配 IPv6 回环地址", actual: "[::1]:8080", want: "localhost:8080", match: true},
+		{name: "端口不同", actual: "127.0.0.1:8081", want: "localhost:8080", match: false},
+		{name: "主机不同", actual: "0.0.0.0:8080", want: "localhost:8080", match: false},
+		{name: "无效的地址", actual: "invalid", want: "localhost:8080", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchListenAddr(tt.actual, tt.want); got != tt.match {
+				t.Errorf("matchListenAddr(%q, %q) = %v, want %v", tt.actual, tt.want, got, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package ui 的文件属性保留模块
+// 传输完成后把源文件的权限、访问时间和修改时间应用到目标文件（与 scp -p 相同），
+// 可选地同时设置所有者和组。源文件的属性在传输之前取得，读取文件会改变它的访问时间；
+// 目录的属性在其中的所有项传输完成后再设置，否则写入子项会改变目录的修改时间，只读的目录也无法再写入
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// preserveModeMask 是保留的权限位，包括 setuid、setgid 和粘滞位
+const preserveModeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// fileAttrs 是需要保留的文件属性
+type fileAttrs struct {
+	mode     os.FileMode // 权限位
+	atime    time.Time   // 访问时间
+	mtime    time.Time   // 修改时间
+	uid, gid int         // 所有者和组
+	hasOwner bool        // 是否取得了所有者和组
+}
+
+// localFileAttrs 返回本地文件的属性
+// 参数:
+//   localPath: 本地文件路径
+//   info: 本地文件的信息
+func localFileAttrs(localPath string, info os.FileInfo) fileAttrs {
+	attrs := fileAttrs{mode: info.Mode() & preserveModeMask, atime: info.ModTime(), mtime: info.ModTime()}
+	if atime, uid, gid, ok := localStat(localPath); ok {
+		attrs.atime, attrs.uid, attrs.gid, attrs.hasOwner = atime, uid, gid, true
+	}
+	return attrs
+}
+
+// remoteFileAttrs 返回远程文件的属性，服务器没有提供访问时间时使用修改时间
+// 参数:
+//   info: 远程文件的信息，来自 SFTP 客户端的 Stat 或 ReadDir
+func remoteFileAttrs(info os.FileInfo) fileAttrs {
+	attrs := fileAttrs{mode: info.Mode() & preserveModeMask, atime: info.ModTime(), mtime: info.ModTime()}
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		if stat.Atime != 0 {
+			attrs.atime = time.Unix(int64(stat.Atime), 0)
+		}
+		attrs.uid, attrs.gid, attrs.hasOwner = int(stat.UID), int(stat.GID), true
+	}
+	return attrs
+}
+
+// preserveRemote 把本地文件的属性应用到远程文件，opts.Preserve 和 opts.PreserveOwner 都为 false 时什么也不做
+// 参数:
+//   client: SFTP 客户端对象
+//   remotePath: 远程文件路径
+//   attrs: 传输之前取得的本地文件属性
+//   opts: 传输选项
+// 返回值:
+//   error: 如果服务器拒绝修改属性则返回错误
+func preserveRemote(client *sftp.Client, remotePath string, attrs fileAttrs, opts TransferOptions) error {
+	if !opts.Preserve && !opts.PreserveOwner {
+		return nil
+	}
+	// 先修改所有者，修改所有者会清除 setuid 和 setgid 位
+	if opts.PreserveOwner {
+		if !attrs.hasOwner {
+			return errors.New("保留所有者失败: 当前平台无法获取本地文件的所有者")
+		}
+		if err := client.Chown(remotePath, attrs.uid, attrs.gid); err != nil {
+			return fmt.Errorf("保留所有者失败: %w", err)
+		}
+	}
+	if !opts.Preserve {
+		return nil
+	}
+	if err := client.Chmod(remotePath, attrs.mode); err != nil {
+		return fmt.Errorf("保留权限失败: %w", err)
+	}
+	if err := client.Chtimes(remotePath, attrs.atime, attrs.mtime); err != nil {
+		return fmt.Errorf("保留修改时间失败: %w", err)
+	}
+	return nil
+}
+
+// preserveLocal 把远程文件的属性应用到本地文件，opts.Preserve 和 opts.PreserveOwner 都为 false 时什么也不做
+// 参数:
+//   localPath: 本地文件路径
+//   attrs: 传输之前取得的远程文件属性
+//   opts: 传输选项
+// 返回值:
+//   error: 如果无法修改本地文件的属性则返回错误
+func preserveLocal(localPath string, attrs fileAttrs, opts TransferOptions) error {
+	if !opts.Preserve && !opts.PreserveOwner {
+		return nil
+	}
+	if opts.PreserveOwner {
+		if !attrs.hasOwner {
+			return errors.New("保留所有者失败: 服务器没有提供远程文件的所有者")
+		}
+		if err := os.Chown(localPath, attrs.uid, attrs.gid); err != nil {
+			return fmt.Errorf("保留所有者失败: %w", err)
+		}
+	}
+	if !opts.Preserve {
+		return nil
+	}
+	if err := os.Chmod(localPath, attrs.mode); err != nil {
+		return fmt.Errorf("保留权限失败: %w", err)
+	}
+	if err := os.Chtimes(localPath, attrs.atime, attrs.mtime); err != nil {
+		return fmt.Errorf("保留修改时间失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+// Package ui 在没有 uid 和 gid 的平台上读取文件属性
+package ui
+
+import "time"
+
+// localStat 在不支持的平台上返回 false，保留属性时使用修改时间作为访问时间，不能保留所有者
+func localStat(localPath string) (time.Time, int, int, bool) {
+	return time.Time{}, 0, 0, false
+}
@@ -0,0 +1,223 @@
+// preserve_test 提供保留文件属性的单元测试
+// 内存中的 SFTP 服务器忽略属性的修改，这里使用以本地文件系统为后端的服务器，远程路径都是本地临时目录中的绝对路径。
+// pkg/sftp 的服务器总是把修改时间作为访问时间返回，所以下载后的访问时间等于修改时间
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	// preserveMtime 和 preserveAtime 是测试中设置的时间，SFTP 中的时间精确到秒
+	preserveMtime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	preserveAtime = time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+)
+
+// checkAttrs 检查文件的权限、修改时间和访问时间，平台不支持时不检查访问时间
+// 测试中的 SFTP 服务器使用本地文件系统，远程文件也可以直接检查
+func checkAttrs(t *testing.T, p string, mode os.FileMode, atime time.Time) {
+	t.Helper()
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("Stat(%s) error = %v", p, err)
+	}
+	if got := info.Mode().Perm(); got != mode {
+		t.Errorf("%s 的权限 = %v, want %v", p, got, mode)
+	}
+	if !info.ModTime().Equal(preserveMtime) {
+		t.Errorf("%s 的修改时间 = %v, want %v", p, info.ModTime(), preserveMtime)
+	}
+	if got, _, _, ok := localStat(p); ok && !got.Equal(atime) {
+		t.Errorf("%s 的访问时间 = %v, want %v", p, got, atime)
+	}
+}
+
+// TestPreserveUpload 测试上传时保留权限和时间
+func TestPreserveUpload(t *testing.T) {
+	client := newLatencySFTPClient(t, 0)
+	remoteDir := t.TempDir()
+	local := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(local, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	if err := os.Chmod(local, 0750); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	if err := os.Chtimes(local, preserveAtime, preserveMtime); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	localFile, err := os.Open(local)
+	if err != nil {
+		t.Fatalf("打开本地文件失败: %v", err)
+	}
+	defer localFile.Close()
+
+	remote := filepath.Join(remoteDir, "run.sh")
+	if _, err := uploadFile(client, localFile, remote, TransferOptions{Preserve: true}); err != nil {
+		t.Fatalf("uploadFile() error = %v", err)
+	}
+	checkAttrs(t, remote, 0750, preserveAtime)
+
+	// 续传完成后同样保留属性，上一次上传读取文件时改变了访问时间
+	if err := os.Chtimes(local, preserveAtime, preserveMtime); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	resumed := filepath.Join(remoteDir, "resumed.sh")
+	if _, _, err := resumeUpload(client, localFile, resumed, false, TransferOptions{Preserve: true}); err != nil {
+		t.Fatalf("resumeUpload() error = %v", err)
+	}
+	checkAttrs(t, resumed, 0750, preserveAtime)
+
+	plain := filepath.Join(remoteDir, "plain.sh")
+	if _, err := uploadFile(client, localFile, plain, TransferOptions{}); err != nil {
+		t.Fatalf("uploadFile() error = %v", err)
+	}
+	info, err := os.Stat(plain)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.ModTime().Equal(preserveMtime) {
+		t.Error("没有 Preserve 时不应该保留修改时间")
+	}
+}
+
+// TestPreserveDownload 测试下载时保留权限和时间
+func TestPreserveDownload(t *testing.T) {
+	client := newLatencySFTPClient(t, 0)
+	remote := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(remote, []byte("secret"), 0600); err != nil {
+		t.Fatalf("创建远程文件失败: %v", err)
+	}
+	if err := os.Chtimes(remote, preserveAtime, preserveMtime); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "secret.txt")
+	if _, err := downloadFile(client, remote, local, TransferOptions{Preserve: true}); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	checkAttrs(t, local, 0600, preserveMtime)
+
+	resumed := filepath.Join(dir, "resumed.txt")
+	if _, _, err := resumeDownload(client, remote, resumed, false, TransferOptions{Preserve: true}); err != nil {
+		t.Fatalf("resumeDownload() error = %v", err)
+	}
+	checkAttrs(t, resumed, 0600, preserveMtime)
+}
+
+// TestPreserveTree 测试递归传输时保留文件和目录的属性
+func TestPreserveTree(t *testing.T) {
+	client := newLatencySFTPClient(t, 0)
+	root := filepath.Join(t.TempDir(), "site")
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatalf("创建本地目录失败: %v", err)
+	}
+	script := filepath.Join(root, "bin", "start")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	for _, p := range []string{script, filepath.Join(root, "bin"), root} {
+		if err := os.Chtimes(p, preserveAtime, preserveMtime); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "bin"), 0700); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{path: "", mode: 0755},
+		{path: "bin", mode: 0700},
+		{path: filepath.Join("bin", "start"), mode: 0755},
+	}
+	opts := TransferOptions{Preserve: true}
+	remote := filepath.Join(t.TempDir(), "site")
+	if summary := uploadTree(client, root, remote, opts); summary.Failed() {
+		t.Fatalf("uploadTree() = %s, errors %v", summary, summary.Errors)
+	}
+	// 下载会改变远程文件的访问时间，先检查上传的结果
+	for _, tt := range tests {
+		checkAttrs(t, filepath.Join(remote, tt.path), tt.mode, preserveAtime)
+	}
+
+	local := filepath.Join(t.TempDir(), "copy")
+	if summary := downloadTree(client, remote, local, opts); summary.Failed() {
+		t.Fatalf("downloadTree() = %s, errors %v", summary, summary.Errors)
+	}
+	for _, tt := range tests {
+		checkAttrs(t, filepath.Join(local, tt.path), tt.mode, preserveMtime)
+	}
+}
+
+// TestPreserveOwner 测试保留所有者，普通用户只能设置为自己的 uid 和所属的组
+func TestPreserveOwner(t *testing.T) {
+	client := newLatencySFTPClient(t, 0)
+	local := filepath.Join(t.TempDir(), "owned.txt")
+	if err := os.WriteFile(local, []byte("data"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	_, uid, gid, ok := localStat(local)
+	if !ok {
+		t.Skip("当前平台无法获取文件的所有者")
+	}
+	localFile, err := os.Open(local)
+	if err != nil {
+		t.Fatalf("打开本地文件失败: %v", err)
+	}
+	defer localFile.Close()
+
+	remote := filepath.Join(t.TempDir(), "owned.txt")
+	if _, err := uploadFile(client, localFile, remote, TransferOptions{PreserveOwner: true}); err != nil {
+		t.Fatalf("uploadFile() error = %v", err)
+	}
+	info, err := client.Stat(remote)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if attrs := remoteFileAttrs(info); !attrs.hasOwner || attrs.uid != uid || attrs.gid != gid {
+		t.Errorf("远程文件的所有者 = %d:%d, want %d:%d", attrs.uid, attrs.gid, uid, gid)
+	}
+
+	copyPath := filepath.Join(t.TempDir(), "owned.txt")
+	if _, err := downloadFile(client, remote, copyPath, TransferOptions{PreserveOwner: true}); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if _, gotUID, gotGID, _ := localStat(copyPath); gotUID != uid || gotGID != gid {
+		t.Errorf("本地文件的所有者 = %d:%d, want %d:%d", gotUID, gotGID, uid, gid)
+	}
+}
+
+// TestPreserveCommands 测试交互命令的 -p 选项
+func TestPreserveCommands(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	remote, err := shell.client.Stat("/home/tester/notes.txt")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := downloadFileCommand(shell, []string{"-p", "notes.txt", dir}); err != nil {
+		t.Fatalf("get -p error = %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "notes.txt"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if !info.ModTime().Equal(remote.ModTime()) {
+		t.Errorf("本地文件的修改时间 = %v, want %v", info.ModTime(), remote.ModTime())
+	}
+
+	if err := uploadFileCommand(shell, []string{"-p", filepath.Join(dir, "notes.txt"), "copy.txt"}); err != nil {
+		t.Errorf("put -p error = %v", err)
+	}
+	if err := resumeDownloadCommand(shell, []string{"-p", "notes.txt", dir}); err != nil {
+		t.Errorf("reget -p error = %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+// Package ui 在 Unix 系统上读取文件的访问时间和所有者
+package ui
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// localStat 返回本地文件的访问时间、所有者和组
+// 参数:
+//   localPath: 本地文件路径，符号链接时返回它指向的文件的属性
+// 返回值:
+//   time.Time: 访问时间
+//   int: 所有者的 uid
+//   int: 组的 gid
+//   bool: 是否成功取得
+func localStat(localPath string) (time.Time, int, int, bool) {
+	var st unix.Stat_t
+	if err := unix.Stat(localPath, &st); err != nil {
+		return time.Time{}, 0, 0, false
+	}
+	return time.Unix(st.Atim.Unix()), int(st.Uid), int(st.Gid), true
+}
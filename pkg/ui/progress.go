@@ -0,0 +1,300 @@
+// Package ui 的传输进度模块
+// 传输函数通过 ProgressSink 报告进度，显示方式由实现决定：
+// 终端中原地刷新的进度条、定期输出的日志行，或者供程序读取的 JSON 行
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressInterval 是两次进度报告之间的最短间隔
+	progressInterval = 200 * time.Millisecond
+	// progressLogInterval 是输出不是终端时两行进度日志之间的间隔
+	progressLogInterval = 5 * time.Second
+	// progressBarWidth 是进度条本身占用的字符数
+	progressBarWidth = 30
+	// progressNameWidth 是进度条前面显示的文件名的最大字符数
+	progressNameWidth = 24
+)
+
+// Progress 是某一时刻的传输进度
+type Progress struct {
+	Name    string        // 正在传输的文件路径
+	Done    int64         // 已经传输的字节数，续传时包含之前传输的部分
+	Total   int64         // 文件的总字节数
+	Rate    float64       // 本次传输的平均速度，单位为字节/秒
+	ETA     time.Duration // 预计剩余时间，无法估计时为 -1
+	Elapsed time.Duration // 本次传输已经用的时间
+}
+
+// Percent 返回完成的百分比，空文件视为已经完成
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 100
+	}
+	return float64(p.Done) * 100 / float64(p.Total)
+}
+
+// ProgressSink 接收传输进度
+// 同一个 ProgressSink 可以依次用于多次传输，但不会同时收到两次传输的进度
+type ProgressSink interface {
+	// Update 在传输过程中定期调用，两次调用至少间隔 200 毫秒
+	Update(p Progress)
+	// Finish 在传输结束时调用一次，err 为 nil 表示传输成功
+	Finish(p Progress, err error)
+}
+
+// NewProgressSink 根据名称创建 ProgressSink，供命令行参数使用
+// 参数:
+//   mode: bar 显示进度条（输出不是终端时改为定期输出日志行），json 输出 JSON 行，none 不显示
+//   w: 进度的输出位置
+// 返回值:
+//   ProgressSink: 创建的 ProgressSink，mode 为 none 时返回 nil
+//   error: 如果 mode 无效则返回错误
+func NewProgressSink(mode string, w io.Writer) (ProgressSink, error) {
+	switch mode {
+	case "", "bar":
+		return NewTerminalProgress(w), nil
+	case "json":
+		return NewJSONProgress(w), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("无效的进度显示方式 %q，可选值: bar、json、none", mode)
+	}
+}
+
+// progressTracker 统计一次传输的字节数，并按 progressInterval 向 ProgressSink 报告
+// 并发传输时多个 goroutine 会同时调用 add，报告在锁内进行，保证 ProgressSink 不会被并发调用
+type progressTracker struct {
+	sink  ProgressSink
+	name  string
+	total int64
+	base  int64 // 续传前已经传输的字节数，不计入速度
+
+	mu    sync.Mutex
+	done  int64
+	start time.Time
+	last  time.Time // 上一次报告的时间
+}
+
+// newProgressTracker 创建一次传输的进度统计
+// 参数:
+//   sink: 接收进度的 ProgressSink，为 nil 时返回 nil，nil 的 progressTracker 不做任何事
+//   name: 传输的文件路径
+//   base: 续传前已经传输的字节数
+//   total: 文件的总字节数
+func newProgressTracker(sink ProgressSink, name string, base, total int64) *progressTracker {
+	if sink == nil {
+		return nil
+	}
+	now := time.Now()
+	t := &progressTracker{sink: sink, name: name, total: total, base: base, done: base, start: now, last: now}
+	sink.Update(t.snapshot(now))
+	return t
+}
+
+// add 记录新传输的 n 个字节，距离上一次报告超过 progressInterval 时报告进度
+func (t *progressTracker) add(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done += int64(n)
+	if now := time.Now(); now.Sub(t.last) >= progressInterval {
+		t.last = now
+		t.sink.Update(t.snapshot(now))
+	}
+}
+
+// finish 报告传输结束
+func (t *progressTracker) finish(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sink.Finish(t.snapshot(time.Now()), err)
+}
+
+// snapshot 计算当前的进度，调用方需要持有锁或者独占 t
+func (t *progressTracker) snapshot(now time.Time) Progress {
+	p := Progress{Name: t.name, Done: t.done, Total: t.total, ETA: -1, Elapsed: now.Sub(t.start)}
+	if seconds := p.Elapsed.Seconds(); seconds > 0 {
+		p.Rate = float64(t.done-t.base) / seconds
+	}
+	if p.Rate > 0 && t.total >= t.done {
+		p.ETA = time.Duration(float64(t.total-t.done) / p.Rate * float64(time.Second))
+	}
+	return p
+}
+
+// NewTerminalProgress 创建在终端中显示进度的 ProgressSink
+// w 是终端时显示原地刷新的进度条；否则每 5 秒输出一行进度，适合重定向到日志文件
+func NewTerminalProgress(w io.Writer) ProgressSink {
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return &barProgress{w: w}
+	}
+	return &lineProgress{w: w, interval: progressLogInterval}
+}
+
+// barProgress 用 "\r" 原地刷新一行进度条
+type barProgress struct {
+	w io.Writer
+}
+
+// Update 回到行首重新绘制进度条，并清除行尾残留的字符
+func (b *barProgress) Update(p Progress) {
+	fmt.Fprintf(b.w, "\r%s\033[K", formatProgressBar(p))
+}
+
+// Finish 保留最后的进度条并换行，失败时的错误由调用方显示
+func (b *barProgress) Finish(p Progress, err error) {
+	fmt.Fprintf(b.w, "\r%s\033[K\n", formatProgressBar(p))
+}
+
+// formatProgressBar 返回一行进度条，如
+// "app.tar.gz   45% [=============>                ] 1.2 MB/2.6 MB  500.0 KB/s  剩余 00:03"
+func formatProgressBar(p Progress) string {
+	percent := p.Percent()
+	filled := int(percent * progressBarWidth / 100)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("%-*s %3.0f%% [%s] %s/%s  %s/s  %s",
+		progressNameWidth, truncateName(filepath.Base(p.Name), progressNameWidth),
+		percent, bar, formatBytes(p.Done), formatBytes(p.Total), formatBytes(int64(p.Rate)), formatETA(p))
+}
+
+// lineProgress 每隔 interval 输出一行进度，传输结束时输出最后一行
+type lineProgress struct {
+	w        io.Writer
+	interval time.Duration
+	last     time.Duration // 上一行进度对应的 Elapsed
+}
+
+// Update 距离上一行进度超过 interval 时输出一行进度，其余的调用忽略
+func (l *lineProgress) Update(p Progress) {
+	if p.Elapsed < l.last+l.interval {
+		return
+	}
+	l.last = p.Elapsed
+	fmt.Fprintln(l.w, formatProgressLine(p))
+}
+
+// Finish 输出最后一行进度，失败时附上错误，并重置计时以便用于下一次传输
+func (l *lineProgress) Finish(p Progress, err error) {
+	l.last = 0
+	if err != nil {
+		fmt.Fprintf(l.w, "%s，失败: %v\n", formatProgressLine(p), err)
+		return
+	}
+	fmt.Fprintln(l.w, formatProgressLine(p))
+}
+
+// formatProgressLine 返回一行进度日志，如 "/data/app.tar.gz: 45% 1.2 MB/2.6 MB，500.0 KB/s，剩余 00:03"
+func formatProgressLine(p Progress) string {
+	return fmt.Sprintf("%s: %.0f%% %s/%s，%s/s，%s",
+		p.Name, p.Percent(), formatBytes(p.Done), formatBytes(p.Total), formatBytes(int64(p.Rate)), formatETA(p))
+}
+
+// formatETA 返回剩余时间，传输完成后返回用时
+func formatETA(p Progress) string {
+	if p.Done >= p.Total {
+		return "用时 " + formatClock(p.Elapsed)
+	}
+	if p.ETA < 0 {
+		return "剩余 --:--"
+	}
+	return "剩余 " + formatClock(p.ETA)
+}
+
+// formatClock 把时长格式化为 "mm:ss"，超过一小时时为 "h:mm:ss"
+func formatClock(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// truncateName 把过长的文件名截断为 width 个字符，开头用 "..." 表示省略
+func truncateName(name string, width int) string {
+	runes := []rune(name)
+	if len(runes) <= width {
+		return name
+	}
+	return "..." + string(runes[len(runes)-width+3:])
+}
+
+// NewJSONProgress 创建输出 JSON 行的 ProgressSink，每次报告输出一行，供其他程序读取
+// 每行包含 event（progress 或 done）、name、done、total、percent、rate（字节/秒）、
+// eta 和 elapsed（秒，eta 无法估计时为 -1），失败时还包含 error
+func NewJSONProgress(w io.Writer) ProgressSink {
+	return &jsonProgress{enc: json.NewEncoder(w)}
+}
+
+// jsonProgress 把进度编码为 JSON 行
+type jsonProgress struct {
+	enc *json.Encoder
+}
+
+// progressEvent 是 JSON 行的格式
+type progressEvent struct {
+	Event   string  `json:"event"`
+	Name    string  `json:"name"`
+	Done    int64   `json:"done"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+	Rate    float64 `json:"rate"`
+	ETA     float64 `json:"eta"`
+	Elapsed float64 `json:"elapsed"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Update 输出一行 event 为 progress 的 JSON
+func (j *jsonProgress) Update(p Progress) {
+	j.enc.Encode(newProgressEvent("progress", p))
+}
+
+// Finish 输出一行 event 为 done 的 JSON，失败时包含 error 字段
+func (j *jsonProgress) Finish(p Progress, err error) {
+	event := newProgressEvent("done", p)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	j.enc.Encode(event)
+}
+
+// newProgressEvent 把 Progress 转换为 JSON 行，时间以秒为单位
+func newProgressEvent(event string, p Progress) progressEvent {
+	eta := -1.0
+	if p.ETA >= 0 {
+		eta = p.ETA.Seconds()
+	}
+	return progressEvent{
+		Event:   event,
+		Name:    p.Name,
+		Done:    p.Done,
+		Total:   p.Total,
+		Percent: p.Percent(),
+		Rate:    p.Rate,
+		ETA:     eta,
+		Elapsed: p.Elapsed.Seconds(),
+	}
+}
@@ -0,0 +1,240 @@
+// progress_test 提供传输进度的单元测试
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingSink 记录收到的所有进度，用于测试
+type recordingSink struct {
+	updates  []Progress
+	finished []Progress
+	errs     []error
+}
+
+func (r *recordingSink) Update(p Progress) {
+	r.updates = append(r.updates, p)
+}
+
+func (r *recordingSink) Finish(p Progress, err error) {
+	r.finished = append(r.finished, p)
+	r.errs = append(r.errs, err)
+}
+
+// TestProgressTracker 测试进度统计和报告间隔
+func TestProgressTracker(t *testing.T) {
+	sink := &recordingSink{}
+	tracker := newProgressTracker(sink, "/tmp/a.bin", 100, 1000)
+	for i := 0; i < 10; i++ {
+		tracker.add(10)
+	}
+	tracker.add(0)
+	tracker.finish(nil)
+
+	// 创建时报告一次初始进度，之后的 add 都在 progressInterval 之内，不会再报告
+	if len(sink.updates) != 1 {
+		t.Fatalf("Update 调用了 %d 次, want 1", len(sink.updates))
+	}
+	if got := sink.updates[0]; got.Done != 100 || got.Total != 1000 || got.Name != "/tmp/a.bin" {
+		t.Errorf("初始进度 = %+v, want Done 100, Total 1000", got)
+	}
+	if len(sink.finished) != 1 || sink.errs[0] != nil {
+		t.Fatalf("Finish 调用了 %d 次 (%v), want 1 次成功", len(sink.finished), sink.errs)
+	}
+	final := sink.finished[0]
+	if final.Done != 200 {
+		t.Errorf("最终 Done = %d, want 200", final.Done)
+	}
+	if final.Rate <= 0 || final.ETA <= 0 {
+		t.Errorf("最终 Rate = %v, ETA = %v, want 都大于 0", final.Rate, final.ETA)
+	}
+}
+
+// TestProgressTracker_Nil 测试没有 ProgressSink 时不做任何事
+func TestProgressTracker_Nil(t *testing.T) {
+	tracker := newProgressTracker(nil, "a", 0, 10)
+	if tracker != nil {
+		t.Fatal("newProgressTracker(nil) != nil")
+	}
+	tracker.add(10)
+	tracker.finish(errors.New("失败"))
+}
+
+// TestTransferProgress 测试上传、下载和续传时报告的进度
+func TestTransferProgress(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	sink := &recordingSink{}
+	opts := TransferOptions{Progress: sink}
+
+	local := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(local, bytes.Repeat([]byte("x"), 100000), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	localFile, err := os.Open(local)
+	if err != nil {
+		t.Fatalf("打开本地文件失败: %v", err)
+	}
+	defer localFile.Close()
+	if _, err := uploadFile(shell.client, localFile, "/home/tester/data.bin", opts); err != nil {
+		t.Fatalf("uploadFile() error = %v", err)
+	}
+
+	if _, err := downloadFile(shell.client, "/home/tester/notes.txt", filepath.Join(t.TempDir(), "notes.txt"), opts); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+
+	partial := filepath.Join(t.TempDir(), "partial.txt")
+	if err := os.WriteFile(partial, []byte("old "), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+	// 续传重新传输末尾的 2 个字节
+	useResumeWindow(t, 2)
+	if _, _, err := resumeDownload(shell.client, "/home/tester/notes.txt", partial, false, opts); err != nil {
+		t.Fatalf("resumeDownload() error = %v", err)
+	}
+
+	want := []struct {
+		name        string
+		start, done int64
+	}{
+		{name: local, start: 0, done: 100000},
+		{name: "/home/tester/notes.txt", start: 0, done: 9},
+		{name: "/home/tester/notes.txt", start: 2, done: 9},
+	}
+	if len(sink.finished) != len(want) {
+		t.Fatalf("Finish 调用了 %d 次, want %d", len(sink.finished), len(want))
+	}
+	for i, w := range want {
+		if got := sink.updates[i]; got.Name != w.name || got.Done != w.start {
+			t.Errorf("第 %d 次传输的初始进度 = %+v, want Name %s, Done %d", i+1, got, w.name, w.start)
+		}
+		if got := sink.finished[i]; got.Done != w.done || got.Total != w.done || sink.errs[i] != nil {
+			t.Errorf("第 %d 次传输的最终进度 = %+v (%v), want Done = Total = %d", i+1, got, sink.errs[i], w.done)
+		}
+	}
+}
+
+// TestLineProgress 测试输出不是终端时按间隔输出进度行
+func TestLineProgress(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTerminalProgress(&buf)
+	if _, ok := sink.(*lineProgress); !ok {
+		t.Fatalf("NewTerminalProgress(非终端) = %T, want *lineProgress", sink)
+	}
+
+	p := Progress{Name: "/data/app.tar.gz", Total: 4096, ETA: -1}
+	for _, elapsed := range []time.Duration{0, time.Second, 6 * time.Second, 8 * time.Second, 12 * time.Second} {
+		p.Elapsed = elapsed
+		p.Done += 512
+		p.Rate = 512
+		p.ETA = 3 * time.Second
+		sink.Update(p)
+	}
+	sink.Finish(p, errors.New("连接断开"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// 6 秒和 12 秒时各输出一行，结束时再输出一行
+	if len(lines) != 3 {
+		t.Fatalf("输出了 %d 行, want 3:\n%s", len(lines), buf.String())
+	}
+	if want := "/data/app.tar.gz: 38% 1.5 KB/4.0 KB，512 B/s，剩余 00:03"; lines[0] != want {
+		t.Errorf("第一行 = %q, want %q", lines[0], want)
+	}
+	if !contains(lines[2], "失败: 连接断开") {
+		t.Errorf("最后一行 = %q, want 包含失败原因", lines[2])
+	}
+}
+
+// TestJSONProgress 测试 JSON 行的格式
+func TestJSONProgress(t *testing.T) {
+	var buf bytes.Buffer
+	sink, err := NewProgressSink("json", &buf)
+	if err != nil {
+		t.Fatalf("NewProgressSink(json) error = %v", err)
+	}
+	sink.Update(Progress{Name: "a.bin", Done: 0, Total: 100, ETA: -1})
+	sink.Finish(Progress{Name: "a.bin", Done: 50, Total: 100, Rate: 25, ETA: 2 * time.Second, Elapsed: 2 * time.Second}, errors.New("失败"))
+
+	var events []progressEvent
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event progressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("无效的 JSON 行 %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	want := []progressEvent{
+		{Event: "progress", Name: "a.bin", Total: 100, ETA: -1},
+		{Event: "done", Name: "a.bin", Done: 50, Total: 100, Percent: 50, Rate: 25, ETA: 2, Elapsed: 2, Error: "失败"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("输出了 %d 行, want %d", len(events), len(want))
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("第 %d 行 = %+v, want %+v", i+1, events[i], want[i])
+		}
+	}
+}
+
+// TestNewProgressSink 测试进度显示方式的解析
+func TestNewProgressSink(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantNil bool
+		wantErr bool
+	}{
+		{mode: "bar"},
+		{mode: ""},
+		{mode: "json"},
+		{mode: "none", wantNil: true},
+		{mode: "fancy", wantNil: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		sink, err := NewProgressSink(tt.mode, &bytes.Buffer{})
+		if (err != nil) != tt.wantErr || (sink == nil) != tt.wantNil {
+			t.Errorf("NewProgressSink(%q) = (%v, %v), want nil %v, wantErr %v", tt.mode, sink, err, tt.wantNil, tt.wantErr)
+		}
+	}
+}
+
+// TestFormatProgressBar 测试进度条的格式
+func TestFormatProgressBar(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Progress
+		want string
+	}{
+		{
+			name: "传输中",
+			p:    Progress{Name: "/data/app.tar.gz", Done: 50, Total: 100, Rate: 10, ETA: 5 * time.Second},
+			want: "app.tar.gz                50% [===============>              ] 50 B/100 B  10 B/s  剩余 00:05",
+		},
+		{
+			name: "已完成",
+			p:    Progress{Name: "a", Done: 100, Total: 100, Elapsed: 3723 * time.Second},
+			want: "a                        100% [==============================] 100 B/100 B  0 B/s  用时 1:02:03",
+		},
+		{
+			name: "无法估计剩余时间",
+			p:    Progress{Name: "这是一个非常非常非常非常非常非常长的中文文件名.txt", Total: 100, ETA: -1},
+			want: "...非常非常非常非常非常长的中文文件名.txt   0% [>                             ] 0 B/100 B  0 B/s  剩余 --:--",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatProgressBar(tt.p); got != tt.want {
+				t.Errorf("formatProgressBar() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+// Package ui 的目录递归传输模块
+// 上传或下载整个目录树：在目标端重建目录结构，按选择的策略处理符号链接，
+// 单个文件失败时记录错误并继续传输其余文件，最后返回文件数、字节数和失败列表。
+// 要求保留属性时，目录的属性在其中所有项传输完成之后设置
+package ui
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+
+	"gossh/internal/sshclient"
+)
+
+// SymlinkPolicy 表示递归传输时如何处理符号链接
+type SymlinkPolicy string
+
+const (
+	// SymlinkFollow 传输链接指向的文件或目录，与 scp -r 相同
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkPreserve 在目标端创建指向相同位置的符号链接
+	SymlinkPreserve SymlinkPolicy = "preserve"
+	// SymlinkSkip 跳过符号链接
+	SymlinkSkip SymlinkPolicy = "skip"
+)
+
+// ParseSymlinkPolicy 解析符号链接策略
+// 参数:
+//   s: follow、preserve 或 skip，为空时使用 follow
+// 返回值:
+//   SymlinkPolicy: 解析后的策略
+//   error: 如果策略无效则返回错误
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch SymlinkPolicy(s) {
+	case "":
+		return SymlinkFollow, nil
+	case SymlinkFollow, SymlinkPreserve, SymlinkSkip:
+		return SymlinkPolicy(s), nil
+	default:
+		return "", fmt.Errorf("无效的符号链接策略 %q，可选值: follow、preserve、skip", s)
+	}
+}
+
+// TransferError 表示递归传输中一个文件或目录的失败
+type TransferError struct {
+	Path string // 出错的源路径
+	Err  error  // 失败原因
+}
+
+// Error 实现 error 接口
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Unwrap 返回失败原因
+func (e *TransferError) Unwrap() error {
+	return e.Err
+}
+
+// TransferSummary 是递归传输的统计结果
+type TransferSummary struct {
+	Files   int              // 成功传输的文件数
+	Dirs    int              // 创建的目录数（包括已经存在的目录）
+	Links   int              // 按 preserve 策略重建的符号链接数
+	Skipped int              // 按 skip 策略跳过的符号链接数
+	Bytes   int64            // 传输的字节数
+	Errors  []*TransferError // 失败的文件和目录
+}
+
+// Failed 检查是否有文件或目录传输失败
+func (s *TransferSummary) Failed() bool {
+	return len(s.Errors) > 0
+}
+
+// String 返回便于阅读的统计信息，如 "12 个文件，3 个目录，1.5 MB，失败 1 个"
+func (s *TransferSummary) String() string {
+	parts := []string{
+		fmt.Sprintf("%d 个文件", s.Files),
+		fmt.Sprintf("%d 个目录", s.Dirs),
+		formatBytes(s.Bytes),
+	}
+	if s.Links > 0 {
+		parts = append(parts, fmt.Sprintf("%d 个符号链接", s.Links))
+	}
+	if s.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("跳过 %d 个符号链接", s.Skipped))
+	}
+	if len(s.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("失败 %d 个", len(s.Errors)))
+	}
+	return strings.Join(parts, "，")
+}
+
+// fail 记录一个失败
+func (s *TransferSummary) fail(p string, err error) {
+	s.Errors = append(s.Errors, &TransferError{Path: p, Err: err})
+}
+
+// UploadDir 把本地目录递归上传到远程目录
+// 远程目录不存在时创建，已经存在时合并，同名文件被覆盖
+// 参数:
+//   client: SSH 客户端对象
+//   localDir: 本地目录路径，本身是符号链接时总是跟随
+//   remoteDir: 远程目录路径
+//   opts: 传输选项，其中 Symlinks 决定目录中符号链接的处理策略，Preserve 同样适用于目录
+// 返回值:
+//   *TransferSummary: 传输统计，其中包括每个失败的文件
+//   error: 如果无法开始传输（如本地路径不是目录、无法建立 SFTP 会话）则返回错误
+func UploadDir(client *sshclient.Client, localDir, remoteDir string, opts TransferOptions) (*TransferSummary, error) {
+	// 先检查本地目录，本地目录有问题时无需建立 SFTP 会话
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("打开本地目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", localDir)
+	}
+
+	sftpClient, err := newSFTPClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("创建 SFTP 客户端失败: %w", err)
+	}
+	defer sftpClient.Close()
+
+	return uploadTree(sftpClient, localDir, remoteDir, withHasher(opts, client, sftpClient)), nil
+}
+
+// DownloadDir 把远程目录递归下载到本地目录
+// 本地目录不存在时创建，已经存在时合并，同名文件被覆盖
+// 参数:
+//   client: SSH 客户端对象
+//   remoteDir: 远程目录路径，本身是符号链接时总是跟随
+//   localDir: 本地目录路径
+//   opts: 传输选项，其中 Symlinks 决定目录中符号链接的处理策略，Preserve 同样适用于目录
+// 返回值:
+//   *TransferSummary: 传输统计，其中包括每个失败的文件
+//   error: 如果无法开始传输（如远程路径不是目录、无法建立 SFTP 会话）则返回错误
+func DownloadDir(client *sshclient.Client, remoteDir, localDir string, opts TransferOptions) (*TransferSummary, error) {
+	sftpClient, err := newSFTPClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("创建 SFTP 客户端失败: %w", err)
+	}
+	defer sftpClient.Close()
+
+	info, err := sftpClient.Stat(remoteDir)
+	if err != nil {
+		return nil, fmt.Errorf("打开远程目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", remoteDir)
+	}
+	return downloadTree(sftpClient, remoteDir, localDir, withHasher(opts, client, sftpClient)), nil
+}
+
+// uploadTree 递归上传目录，调用方已经确认 localDir 是目录
+func uploadTree(client *sftp.Client, localDir, remoteDir string, opts TransferOptions) *TransferSummary {
+	summary := &TransferSummary{}
+	info, err := os.Stat(localDir)
+	if err != nil {
+		summary.fail(localDir, err)
+		return summary
+	}
+	u := &treeUploader{client: client, opts: opts, summary: summary}
+	u.dir(localDir, remoteDir, info, nil)
+	return summary
+}
+
+// treeUploader 保存递归上传的状态
+type treeUploader struct {
+	client  *sftp.Client
+	opts    TransferOptions
+	summary *TransferSummary
+}
+
+// entry 上传目录中的一项，info 是 Lstat 的结果
+// ancestors 是当前路径上的所有目录，跟随符号链接时用于发现循环
+func (u *treeUploader) entry(local, remote string, info os.FileInfo, ancestors []os.FileInfo) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch u.opts.Symlinks {
+		case SymlinkSkip:
+			u.summary.Skipped++
+			return
+		case SymlinkPreserve:
+			target, err := os.Readlink(local)
+			if err == nil {
+				err = u.client.Symlink(target, remote)
+			}
+			if err != nil {
+				u.summary.fail(local, fmt.Errorf("创建符号链接失败: %w", err))
+				return
+			}
+			u.summary.Links++
+			return
+		default:
+			resolved, err := os.Stat(local)
+			if err != nil {
+				u.summary.fail(local, fmt.Errorf("符号链接指向的文件不存在: %w", err))
+				return
+			}
+			info = resolved
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		u.dir(local, remote, info, ancestors)
+	case info.Mode().IsRegular():
+		u.file(local, remote)
+	default:
+		u.summary.fail(local, fmt.Errorf("不支持的文件类型 %s", info.Mode().Type()))
+	}
+}
+
+// dir 创建远程目录并上传其中的每一项
+func (u *treeUploader) dir(local, remote string, info os.FileInfo, ancestors []os.FileInfo) {
+	for _, a := range ancestors {
+		if os.SameFile(a, info) {
+			u.summary.fail(local, fmt.Errorf("符号链接形成循环，已跳过"))
+			return
+		}
+	}
+	if err := mkdirRemote(u.client, remote); err != nil {
+		u.summary.fail(local, err)
+		return
+	}
+	u.summary.Dirs++
+
+	// 读取目录会改变访问时间，在读取之前取得需要保留的属性
+	attrs := localFileAttrs(local, info)
+	entries, err := os.ReadDir(local)
+	if err != nil {
+		u.summary.fail(local, fmt.Errorf("读取本地目录失败: %w", err))
+		return
+	}
+	ancestors = append(ancestors, info)
+	for _, e := range entries {
+		child := filepath.Join(local, e.Name())
+		childInfo, err := e.Info()
+		if err != nil {
+			u.summary.fail(child, err)
+			continue
+		}
+		u.entry(child, path.Join(remote, e.Name()), childInfo, ancestors)
+	}
+	if err := preserveRemote(u.client, remote, attrs, u.opts); err != nil {
+		u.summary.fail(local, err)
+	}
+}
+
+// file 上传一个普通文件
+func (u *treeUploader) file(local, remote string) {
+	localFile, err := os.Open(local)
+	if err != nil {
+		u.summary.fail(local, fmt.Errorf("打开本地文件失败: %w", err))
+		return
+	}
+	defer localFile.Close()
+
+	n, err := uploadFile(u.client, localFile, remote, u.opts)
+	u.summary.Bytes += n
+	if err != nil {
+		u.summary.fail(local, err)
+		return
+	}
+	u.summary.Files++
+}
+
+// mkdirRemote 创建远程目录，目录已经存在时不报错
+func mkdirRemote(client *sftp.Client, dir string) error {
+	err := client.Mkdir(dir)
+	if err == nil {
+		return nil
+	}
+	if info, statErr := client.Stat(dir); statErr == nil && info.IsDir() {
+		return nil
+	}
+	return fmt.Errorf("创建远程目录失败: %w", err)
+}
+
+// downloadTree 递归下载目录，调用方已经确认 remoteDir 是目录
+func downloadTree(client *sftp.Client, remoteDir, localDir string, opts TransferOptions) *TransferSummary {
+	summary := &TransferSummary{}
+	info, err := client.Stat(remoteDir)
+	if err != nil {
+		summary.fail(remoteDir, err)
+		return summary
+	}
+	d := &treeDownloader{client: client, opts: opts, summary: summary}
+	d.dir(remoteDir, localDir, info, nil)
+	return summary
+}
+
+// treeDownloader 保存递归下载的状态
+type treeDownloader struct {
+	client  *sftp.Client
+	opts    TransferOptions
+	summary *TransferSummary
+}
+
+// entry 下载目录中的一项，info 是 Lstat 的结果
+// ancestors 是当前路径上所有目录的真实路径，跟随符号链接时用于发现循环
+func (d *treeDownloader) entry(remote, local string, info os.FileInfo, ancestors []string) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch d.opts.Symlinks {
+		case SymlinkSkip:
+			d.summary.Skipped++
+			return
+		case SymlinkPreserve:
+			target, err := d.client.ReadLink(remote)
+			if err == nil {
+				err = os.Symlink(target, local)
+			}
+			if err != nil {
+				d.summary.fail(remote, fmt.Errorf("创建符号链接失败: %w", err))
+				return
+			}
+			d.summary.Links++
+			return
+		default:
+			resolved, err := d.client.Stat(remote)
+			if err != nil {
+				d.summary.fail(remote, fmt.Errorf("符号链接指向的文件不存在: %w", err))
+				return
+			}
+			info = resolved
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		d.dir(remote, local, info, ancestors)
+	case info.Mode().IsRegular():
+		d.file(remote, local)
+	default:
+		d.summary.fail(remote, fmt.Errorf("不支持的文件类型 %s", info.Mode().Type()))
+	}
+}
+
+// dir 创建本地目录并下载其中的每一项
+func (d *treeDownloader) dir(remote, local string, info os.FileInfo, ancestors []string) {
+	realPath, err := d.client.RealPath(remote)
+	if err != nil {
+		d.summary.fail(remote, fmt.Errorf("解析远程路径失败: %w", err))
+		return
+	}
+	for _, a := range ancestors {
+		if a == realPath {
+			d.summary.fail(remote, fmt.Errorf("符号链接形成循环，已跳过"))
+			return
+		}
+	}
+	if err := os.MkdirAll(local, 0755); err != nil {
+		d.summary.fail(remote, fmt.Errorf("创建本地目录失败: %w", err))
+		return
+	}
+	d.summary.Dirs++
+
+	entries, err := d.client.ReadDir(remote)
+	if err != nil {
+		d.summary.fail(remote, fmt.Errorf("读取远程目录失败: %w", err))
+		return
+	}
+	ancestors = append(ancestors, realPath)
+	for _, e := range entries {
+		// 远程文件名来自服务器，不能让它跳出目标目录
+		if !fs.ValidPath(e.Name()) || strings.ContainsAny(e.Name(), `/\`) {
+			d.summary.fail(path.Join(remote, e.Name()), fmt.Errorf("不安全的文件名，已跳过"))
+			continue
+		}
+		d.entry(path.Join(remote, e.Name()), filepath.Join(local, e.Name()), e, ancestors)
+	}
+	if err := preserveLocal(local, remoteFileAttrs(info), d.opts); err != nil {
+		d.summary.fail(remote, err)
+	}
+}
+
+// file 下载一个普通文件
+func (d *treeDownloader) file(remote, local string) {
+	n, err := downloadFile(d.client, remote, local, d.opts)
+	d.summary.Bytes += n
+	if err != nil {
+		d.summary.fail(remote, err)
+		return
+	}
+	d.summary.Files++
+}
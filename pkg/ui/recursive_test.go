@@ -0,0 +1,269 @@
+// recursive_test 提供目录递归传输的单元测试
+// 远程端使用内存中的 SFTP 服务器，本地端使用临时目录
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// makeLocalTree 在临时目录中创建测试用的目录树:
+//   a.txt、sub/b.txt、sub/deep/c.txt、link -> a.txt、sublink -> sub、dangling -> missing、loop -> .
+func makeLocalTree(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "tree")
+	files := map[string]string{
+		"a.txt":          "aaa",
+		"sub/b.txt":      "bb",
+		"sub/deep/c.txt": "c",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("创建文件失败: %v", err)
+		}
+	}
+	links := map[string]string{"link": "a.txt", "sublink": "sub", "dangling": "missing", "loop": "."}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("当前系统不支持符号链接: %v", err)
+		}
+	}
+	return root
+}
+
+// TestUploadTree 测试三种符号链接策略下的递归上传
+func TestUploadTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		symlinks  SymlinkPolicy
+		wantFiles map[string]string // 远程文件及内容，相对于目标目录
+		wantLinks map[string]string // 远程符号链接及目标
+		want      TransferSummary   // 不比较 Errors，只比较错误数量
+		wantErrs  int
+	}{
+		{
+			name:     "跟随符号链接",
+			symlinks: SymlinkFollow,
+			wantFiles: map[string]string{
+				"a.txt": "aaa", "sub/b.txt": "bb", "sub/deep/c.txt": "c",
+				"link": "aaa", "sublink/b.txt": "bb", "sublink/deep/c.txt": "c",
+			},
+			want: TransferSummary{Files: 6, Dirs: 5, Bytes: 12},
+			// dangling 指向的文件不存在，loop 形成循环
+			wantErrs: 2,
+		},
+		{
+			name:      "保留符号链接",
+			symlinks:  SymlinkPreserve,
+			wantFiles: map[string]string{"a.txt": "aaa", "sub/b.txt": "bb", "sub/deep/c.txt": "c"},
+			wantLinks: map[string]string{"link": "a.txt", "sublink": "sub", "dangling": "missing", "loop": "."},
+			want:      TransferSummary{Files: 3, Dirs: 3, Links: 4, Bytes: 6},
+		},
+		{
+			name:      "跳过符号链接",
+			symlinks:  SymlinkSkip,
+			wantFiles: map[string]string{"a.txt": "aaa", "sub/b.txt": "bb", "sub/deep/c.txt": "c"},
+			want:      TransferSummary{Files: 3, Dirs: 3, Skipped: 4, Bytes: 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeLocalTree(t)
+			shell := newTestSFTPShell(t, "")
+			summary := uploadTree(shell.client, root, "/upload", TransferOptions{Symlinks: tt.symlinks})
+
+			if len(summary.Errors) != tt.wantErrs {
+				t.Errorf("失败数量 = %d, want %d: %v", len(summary.Errors), tt.wantErrs, summary.Errors)
+			}
+			if !sameCounts(summary, &tt.want) {
+				t.Errorf("uploadTree() = %s, want %s", summary, &tt.want)
+			}
+			for name, content := range tt.wantFiles {
+				if data := readRemote(t, shell, "/upload/"+name); data != content {
+					t.Errorf("%s 的内容 = %q, want %q", name, data, content)
+				}
+			}
+			for name, target := range tt.wantLinks {
+				if got, err := shell.client.ReadLink("/upload/" + name); err != nil || got != target {
+					t.Errorf("%s 链接到 %q (%v), want %q", name, got, err, target)
+				}
+			}
+		})
+	}
+}
+
+// TestDownloadTree 测试三种符号链接策略下的递归下载
+func TestDownloadTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		symlinks  SymlinkPolicy
+		wantFiles []string // 本地的普通文件，相对于目标目录
+		wantLinks map[string]string
+		want      TransferSummary
+		wantErrs  int
+	}{
+		{
+			name:      "跟随符号链接",
+			symlinks:  SymlinkFollow,
+			wantFiles: []string{"docs/link", "notes.txt"},
+			want:      TransferSummary{Files: 2, Dirs: 2, Bytes: 18},
+			wantErrs:  1, // dangling 指向的文件不存在
+		},
+		{
+			name:      "保留符号链接",
+			symlinks:  SymlinkPreserve,
+			wantFiles: []string{"notes.txt"},
+			wantLinks: map[string]string{"docs/link": "/home/tester/notes.txt", "docs/dangling": "/missing"},
+			want:      TransferSummary{Files: 1, Dirs: 2, Links: 2, Bytes: 9},
+		},
+		{
+			name:      "跳过符号链接",
+			symlinks:  SymlinkSkip,
+			wantFiles: []string{"notes.txt"},
+			want:      TransferSummary{Files: 1, Dirs: 2, Skipped: 2, Bytes: 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := newTestSFTPShell(t, "")
+			for name, target := range map[string]string{"link": "/home/tester/notes.txt", "dangling": "/missing"} {
+				if err := shell.client.Symlink(target, "/home/tester/docs/"+name); err != nil {
+					t.Fatalf("创建远程符号链接失败: %v", err)
+				}
+			}
+
+			local := filepath.Join(t.TempDir(), "home")
+			summary := downloadTree(shell.client, "/home/tester", local, TransferOptions{Symlinks: tt.symlinks})
+			if len(summary.Errors) != tt.wantErrs {
+				t.Errorf("失败数量 = %d, want %d: %v", len(summary.Errors), tt.wantErrs, summary.Errors)
+			}
+			if !sameCounts(summary, &tt.want) {
+				t.Errorf("downloadTree() = %s, want %s", summary, &tt.want)
+			}
+
+			var files []string
+			filepath.Walk(local, func(p string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() {
+					rel, _ := filepath.Rel(local, p)
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(files)
+			if len(files) != len(tt.wantFiles) {
+				t.Fatalf("本地文件 = %v, want %v", files, tt.wantFiles)
+			}
+			for i := range files {
+				if files[i] != tt.wantFiles[i] {
+					t.Errorf("本地文件 = %v, want %v", files, tt.wantFiles)
+					break
+				}
+			}
+			for name, target := range tt.wantLinks {
+				if got, err := os.Readlink(filepath.Join(local, name)); err != nil || got != target {
+					t.Errorf("%s 链接到 %q (%v), want %q", name, got, err, target)
+				}
+			}
+		})
+	}
+}
+
+// TestDirCommands 测试 put -r 和 get -r 的目标路径
+func TestDirCommands(t *testing.T) {
+	root := makeLocalTree(t)
+	shell := newTestSFTPShell(t, "y\n")
+
+	// 指定的远程目录已经存在时上传到其中
+	if err := uploadFileCommand(shell, []string{"-r", "-symlinks=skip", root, "docs"}); err != nil {
+		t.Fatalf("put -r error = %v", err)
+	}
+	if got := readRemote(t, shell, "/home/tester/docs/tree/sub/b.txt"); got != "bb" {
+		t.Errorf("docs/tree/sub/b.txt 的内容 = %q, want \"bb\"", got)
+	}
+
+	// 目标目录已经存在时确认后合并
+	if err := uploadFileCommand(shell, []string{"-r", "-symlinks=skip", root, "docs"}); err != nil {
+		t.Fatalf("再次 put -r error = %v", err)
+	}
+
+	// 没有指定本地路径时下载到当前目录
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	defer os.Chdir(wd)
+	if err := downloadFileCommand(shell, []string{"-r", "docs"}); err != nil {
+		t.Fatalf("get -r error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "docs", "tree", "a.txt")); err != nil || string(data) != "aaa" {
+		t.Errorf("docs/tree/a.txt = %q (%v), want \"aaa\"", data, err)
+	}
+
+	tests := []struct {
+		name    string
+		command func(*sftpShell, []string) error
+		args    []string
+		wantErr string
+	}{
+		{name: "上传的不是目录", command: uploadFileCommand, args: []string{"-r", filepath.Join(root, "a.txt")}, wantErr: "不是目录"},
+		{name: "下载的不是目录", command: downloadFileCommand, args: []string{"-r", "notes.txt"}, wantErr: "不是目录"},
+		{name: "无效的符号链接策略", command: uploadFileCommand, args: []string{"-r", "-symlinks=copy", root}, wantErr: "无效的符号链接策略"},
+		{name: "未知选项", command: downloadFileCommand, args: []string{"-x", "docs"}, wantErr: "选项无效"},
+		{name: "不带 -r 上传目录", command: uploadFileCommand, args: []string{root}, wantErr: "put -r"},
+		{name: "不带 -r 下载目录", command: downloadFileCommand, args: []string{"docs"}, wantErr: "get -r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.command(shell, tt.args); err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sameCounts 比较两个统计中除 Errors 以外的计数
+func sameCounts(a, b *TransferSummary) bool {
+	return a.Files == b.Files && a.Dirs == b.Dirs && a.Links == b.Links &&
+		a.Skipped == b.Skipped && a.Bytes == b.Bytes
+}
+
+// TestParseSymlinkPolicy 测试符号链接策略的解析
+func TestParseSymlinkPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SymlinkPolicy
+		wantErr bool
+	}{
+		{input: "", want: SymlinkFollow},
+		{input: "follow", want: SymlinkFollow},
+		{input: "preserve", want: SymlinkPreserve},
+		{input: "skip", want: SymlinkSkip},
+		{input: "copy", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSymlinkPolicy(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSymlinkPolicy(%q) = (%q, %v), want (%q, wantErr %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestTransferSummary_String 测试递归传输统计的显示
+func TestTransferSummary_String(t *testing.T) {
+	summary := &TransferSummary{Files: 2, Dirs: 1, Bytes: 2048, Skipped: 1}
+	summary.fail("/tmp/x", os.ErrPermission)
+	want := "2 个文件，1 个目录，2.0 KB，跳过 1 个符号链接，失败 1 个"
+	if got := summary.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !summary.Failed() {
+		t.Error("Failed() = false, want true")
+	}
+}
@@ -0,0 +1,258 @@
+// Package ui 的断点续传模块
+// 续传时以目标文件的现有大小作为已传输的长度，从源文件的同一位置继续，
+// 可选地先比较已传输部分末尾的哈希，避免在内容不同的文件后面追加数据。
+// 并发传输被中断（如进程被杀死、连接断开后无法截断远程文件）时，目标文件末尾可能有尚未写入的块，
+// 所以续传总是从目标文件末尾之前 resumeWindow 的位置开始，重新传输这一段
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/sftp"
+
+	"gossh/internal/sshclient"
+)
+
+// resumeVerifySize 是续传前校验的续传位置之前的最小长度
+const resumeVerifySize = 1 << 20
+
+// resumeWindow 是续传时重新传输的目标文件末尾的长度
+// 被中断的传输可能使用了与本次不同的并发数和块大小，所以按允许的最大值计算，
+// 覆盖任何参数下 copyChunks 中断时可能留下空洞的范围
+// 定义为变量以便在测试中替换
+var resumeWindow int64 = MaxConcurrency * MaxChunkSize
+
+// ErrResumeMismatch 表示目标文件已有的内容与源文件不一致，无法续传
+var ErrResumeMismatch = errors.New("目标文件已有的内容与源文件不一致，无法续传")
+
+// resumeOffset 根据源文件和目标文件的大小确定续传的位置
+// 参数:
+//   srcSize: 源文件大小
+//   dstSize: 目标文件现有的大小
+//   window: 目标文件末尾可能有空洞的长度，这一段会重新传输
+// 返回值:
+//   int64: 续传开始的位置
+//   error: 如果目标文件比源文件大则返回错误
+func resumeOffset(srcSize, dstSize, window int64) (int64, error) {
+	if dstSize > srcSize {
+		return 0, fmt.Errorf("目标文件 (%s) 比源文件 (%s) 大，无法续传", formatBytes(dstSize), formatBytes(srcSize))
+	}
+	return max(dstSize-window, 0), nil
+}
+
+// verifyTail 比较两个文件在 offset 之前最多 window 字节的 SHA-256 哈希
+// 参数:
+//   src: 源文件
+//   dst: 目标文件
+//   offset: 已传输的长度
+//   window: 校验的长度
+// 返回值:
+//   error: 内容不一致时返回 ErrResumeMismatch，读取失败时返回相应的错误
+func verifyTail(src, dst io.ReaderAt, offset, window int64) error {
+	n := window
+	if offset < n {
+		n = offset
+	}
+	if n == 0 {
+		return nil
+	}
+	srcSum, err := tailDigest(src, offset-n, n)
+	if err != nil {
+		return fmt.Errorf("读取源文件失败: %w", err)
+	}
+	dstSum, err := tailDigest(dst, offset-n, n)
+	if err != nil {
+		return fmt.Errorf("读取目标文件失败: %w", err)
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		return ErrResumeMismatch
+	}
+	return nil
+}
+
+// tailDigest 计算文件从 off 开始 n 个字节的 SHA-256 哈希
+func tailDigest(r io.ReaderAt, off, n int64) ([]byte, error) {
+	h := sha256.New()
+	copied, err := io.Copy(h, io.NewSectionReader(r, off, n))
+	if err != nil {
+		return nil, err
+	}
+	if copied != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return h.Sum(nil), nil
+}
+
+// resumeUpload 把本地文件续传到远程路径，远程文件不存在时从头上传
+// 参数:
+//   client: SFTP 客户端对象
+//   localFile: 已经打开的本地文件，由调用方关闭
+//   remotePath: 远程文件路径
+//   verify: 是否在续传前校验已传输部分末尾的哈希
+//   opts: 传输选项
+// 返回值:
+//   int64: 续传开始的位置
+//   int64: 本次传输的字节数
+//   error: 如果远程文件比本地文件大、内容不一致或传输失败则返回错误
+func resumeUpload(client *sftp.Client, localFile *os.File, remotePath string, verify bool, opts TransferOptions) (int64, int64, error) {
+	localInfo, err := localFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+
+	// 不使用 O_TRUNC，保留远程文件已有的内容
+	remoteFile, err := client.OpenFile(remotePath, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	defer remoteFile.Close()
+
+	remoteInfo, err := remoteFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取远程文件信息失败: %w", err)
+	}
+	offset, err := resumeOffset(localInfo.Size(), remoteInfo.Size(), resumeWindow)
+	if err != nil {
+		return 0, 0, err
+	}
+	if verify {
+		if err := verifyTail(localFile, remoteFile, offset, resumeVerifySize); err != nil {
+			return offset, 0, err
+		}
+	}
+
+	// 读取文件会改变访问时间，在传输之前取得需要保留的属性
+	attrs := localFileAttrs(localFile.Name(), localInfo)
+	tracker := newProgressTracker(opts.Progress, localFile.Name(), offset, localInfo.Size())
+	n, err := copyChunks(remoteFile, localFile, offset, localInfo.Size(), opts, tracker)
+	if err != nil {
+		err = fmt.Errorf("文件传输失败: %w", err)
+	} else if err = remoteFile.Close(); err != nil {
+		err = fmt.Errorf("关闭远程文件失败: %w", err)
+	}
+	tracker.finish(err)
+	if err == nil {
+		err = preserveRemote(client, remotePath, attrs, opts)
+	}
+	if err == nil {
+		err = verifyChecksum(opts, localFile.Name(), remotePath)
+	}
+	return offset, n, err
+}
+
+// resumeDownload 把远程文件续传到本地路径，本地文件不存在时从头下载
+// 参数:
+//   client: SFTP 客户端对象
+//   remotePath: 远程文件路径
+//   localPath: 本地文件路径
+//   verify: 是否在续传前校验已传输部分末尾的哈希
+//   opts: 传输选项
+// 返回值:
+//   int64: 续传开始的位置
+//   int64: 本次传输的字节数
+//   error: 如果本地文件比远程文件大、内容不一致或传输失败则返回错误
+func resumeDownload(client *sftp.Client, remotePath, localPath string, verify bool, opts TransferOptions) (int64, int64, error) {
+	remoteFile, err := client.Open(remotePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	defer remoteFile.Close()
+
+	remoteInfo, err := remoteFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取远程文件信息失败: %w", err)
+	}
+
+	// 不使用 O_TRUNC，保留本地文件已有的内容
+	localFile, err := os.OpenFile(localPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer localFile.Close()
+
+	localInfo, err := localFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+	offset, err := resumeOffset(remoteInfo.Size(), localInfo.Size(), resumeWindow)
+	if err != nil {
+		return 0, 0, err
+	}
+	if verify {
+		if err := verifyTail(remoteFile, localFile, offset, resumeVerifySize); err != nil {
+			return offset, 0, err
+		}
+	}
+
+	tracker := newProgressTracker(opts.Progress, remotePath, offset, remoteInfo.Size())
+	n, err := copyChunks(localFile, remoteFile, offset, remoteInfo.Size(), opts, tracker)
+	if err != nil {
+		err = fmt.Errorf("文件传输失败: %w", err)
+	} else if err = localFile.Close(); err != nil {
+		err = fmt.Errorf("关闭本地文件失败: %w", err)
+	}
+	tracker.finish(err)
+	if err == nil {
+		err = preserveLocal(localPath, remoteFileAttrs(remoteInfo), opts)
+	}
+	if err == nil {
+		err = verifyChecksum(opts, localPath, remotePath)
+	}
+	return offset, n, err
+}
+
+// ResumeUploadFile 续传文件到远程服务器
+// 远程文件已经存在时从它的末尾继续上传，不存在时从头上传
+// 参数:
+//   client: SSH 客户端对象
+//   localPath: 本地文件路径
+//   remotePath: 远程文件路径
+//   verify: 是否在续传前校验已传输部分末尾的哈希
+//   opts: 传输选项
+// 返回值:
+//   int64: 续传开始的位置，即远程文件原有的大小减去重新传输的末尾部分
+//   error: 如果续传失败则返回错误信息
+func ResumeUploadFile(client *sshclient.Client, localPath, remotePath string, verify bool, opts TransferOptions) (int64, error) {
+	// 先打开本地文件，本地文件有问题时无需建立 SFTP 会话
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return 0, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer localFile.Close()
+
+	sftpClient, err := newSFTPClient(client)
+	if err != nil {
+		return 0, fmt.Errorf("创建 SFTP 客户端失败: %w", err)
+	}
+	defer sftpClient.Close()
+
+	offset, _, err := resumeUpload(sftpClient, localFile, remotePath, verify, withHasher(opts, client, sftpClient))
+	return offset, err
+}
+
+// ResumeDownloadFile 从远程服务器续传文件
+// 本地文件已经存在时从它的末尾继续下载，不存在时从头下载
+// 参数:
+//   client: SSH 客户端对象
+//   remotePath: 远程文件路径
+//   localPath: 本地文件路径
+//   verify: 是否在续传前校验已传输部分末尾的哈希
+//   opts: 传输选项
+// 返回值:
+//   int64: 续传开始的位置，即本地文件原有的大小减去重新传输的末尾部分
+//   error: 如果续传失败则返回错误信息
+func ResumeDownloadFile(client *sshclient.Client, remotePath, localPath string, verify bool, opts TransferOptions) (int64, error) {
+	sftpClient, err := newSFTPClient(client)
+	if err != nil {
+		return 0, fmt.Errorf("创建 SFTP 客户端失败: %w", err)
+	}
+	defer sftpClient.Close()
+
+	offset, _, err := resumeDownload(sftpClient, remotePath, localPath, verify, withHasher(opts, client, sftpClient))
+	return offset, err
+}
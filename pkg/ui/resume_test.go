@@ -0,0 +1,322 @@
+// resume_test 提供断点续传的单元测试
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useResumeWindow 在测试期间把续传重新传输的长度替换为 n
+func useResumeWindow(t *testing.T, n int64) {
+	t.Helper()
+	orig := resumeWindow
+	resumeWindow = n
+	t.Cleanup(func() { resumeWindow = orig })
+}
+
+// resumeTests 是上传和下载共用的测试用例，源文件的内容是 "old notes"，续传重新传输目标文件的最后 2 个字节
+var resumeTests = []struct {
+	name       string
+	existing   *string // 目标文件已有的内容，nil 表示不存在
+	verify     bool
+	wantOffset int64
+	want       string // 续传后目标文件的内容
+	wantErr    string
+}{
+	{name: "目标文件不存在时从头传输", want: "old notes"},
+	{name: "从目标文件的末尾之前继续", existing: strPtr("old "), wantOffset: 2, want: "old notes"},
+	{name: "校验通过后继续", existing: strPtr("old "), verify: true, wantOffset: 2, want: "old notes"},
+	{name: "目标文件已经完整时重新传输末尾", existing: strPtr("old notes"), verify: true, wantOffset: 7, want: "old notes"},
+	{name: "目标文件比源文件大", existing: strPtr("old notes!"), want: "old notes!", wantErr: "无法续传"},
+	{name: "校验发现内容不一致", existing: strPtr("new "), verify: true, want: "new ", wantErr: ErrResumeMismatch.Error()},
+	{name: "不校验时不检查已有的内容", existing: strPtr("new "), wantOffset: 2, want: "ned notes"},
+}
+
+// strPtr 返回字符串的指针
+func strPtr(s string) *string {
+	return &s
+}
+
+// TestResumeDownload 测试从本地文件的末尾继续下载
+func TestResumeDownload(t *testing.T) {
+	useResumeWindow(t, 2)
+	for _, tt := range resumeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := newTestSFTPShell(t, "")
+			local := filepath.Join(t.TempDir(), "notes.txt")
+			if tt.existing != nil {
+				if err := os.WriteFile(local, []byte(*tt.existing), 0644); err != nil {
+					t.Fatalf("创建本地文件失败: %v", err)
+				}
+			}
+
+			offset, n, err := resumeDownload(shell.client, "/home/tester/notes.txt", local, tt.verify, TransferOptions{})
+			checkResume(t, offset, n, err, tt.wantOffset, tt.wantErr)
+			data, err := os.ReadFile(local)
+			if err != nil {
+				t.Fatalf("读取本地文件失败: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("本地文件的内容 = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+// TestResumeUpload 测试从远程文件的末尾继续上传
+func TestResumeUpload(t *testing.T) {
+	useResumeWindow(t, 2)
+	for _, tt := range resumeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := newTestSFTPShell(t, "")
+			local := filepath.Join(t.TempDir(), "notes.txt")
+			if err := os.WriteFile(local, []byte("old notes"), 0644); err != nil {
+				t.Fatalf("创建本地文件失败: %v", err)
+			}
+			localFile, err := os.Open(local)
+			if err != nil {
+				t.Fatalf("打开本地文件失败: %v", err)
+			}
+			defer localFile.Close()
+
+			remotePath := "/home/tester/docs/notes.txt"
+			if tt.existing != nil {
+				file, err := shell.client.Create(remotePath)
+				if err != nil {
+					t.Fatalf("创建远程文件失败: %v", err)
+				}
+				file.Write([]byte(*tt.existing))
+				file.Close()
+			}
+
+			offset, n, err := resumeUpload(shell.client, localFile, remotePath, tt.verify, TransferOptions{})
+			checkResume(t, offset, n, err, tt.wantOffset, tt.wantErr)
+			if got := readRemote(t, shell, remotePath); got != tt.want {
+				t.Errorf("远程文件的内容 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkResume 检查续传的起始位置、传输的字节数和错误
+func checkResume(t *testing.T, offset, n int64, err error, wantOffset int64, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !contains(err.Error(), wantErr) {
+			t.Errorf("error = %v, want %s", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	if offset != wantOffset {
+		t.Errorf("offset = %d, want %d", offset, wantOffset)
+	}
+	if want := int64(len("old notes")) - wantOffset; n != want {
+		t.Errorf("传输的字节数 = %d, want %d", n, want)
+	}
+}
+
+// resumeData 返回续传测试使用的 size 个字节的数据，其中没有 0，便于区分空洞
+func resumeData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i%251 + 1)
+	}
+	return data
+}
+
+// withHole 返回 data 的前 size 个字节，其中 [holeStart, holeEnd) 没有写入
+func withHole(data []byte, size, holeStart, holeEnd int) []byte {
+	b := append([]byte(nil), data[:size]...)
+	clear(b[holeStart:holeEnd])
+	return b
+}
+
+// TestResumeRepairsHoles 测试续传修复并发传输中断后目标文件末尾没有写完的块
+// 中断时连续写完的部分之后最多还有 Concurrency 个块已经写入，目标文件的大小可能超过实际写完的位置
+func TestResumeRepairsHoles(t *testing.T) {
+	useResumeWindow(t, 4000)
+	data := resumeData(10500)
+	tests := []struct {
+		name     string
+		existing []byte
+		opts     TransferOptions
+	}{
+		{name: "末尾之前有空洞", existing: withHole(data, 8000, 5000, 6000), opts: TransferOptions{Concurrency: 4, ChunkSize: 1000}},
+		{name: "大小完整但有空洞", existing: withHole(data, len(data), 7000, 9000), opts: TransferOptions{Concurrency: 4, ChunkSize: 1000}},
+		{name: "续传使用较小的并发数", existing: withHole(data, len(data), 7000, 9000), opts: TransferOptions{Concurrency: 1, ChunkSize: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			shell := newTestSFTPShell(t, "")
+			source := filepath.Join(t.TempDir(), "data.bin")
+			if err := os.WriteFile(source, data, 0644); err != nil {
+				t.Fatalf("创建本地文件失败: %v", err)
+			}
+			if _, err := uploadFile(shell.client, mustOpen(t, source), "/home/tester/data.bin", opts); err != nil {
+				t.Fatalf("uploadFile() error = %v", err)
+			}
+
+			local := filepath.Join(t.TempDir(), "data.bin")
+			if err := os.WriteFile(local, tt.existing, 0644); err != nil {
+				t.Fatalf("创建本地文件失败: %v", err)
+			}
+			if _, _, err := resumeDownload(shell.client, "/home/tester/data.bin", local, false, opts); err != nil {
+				t.Fatalf("resumeDownload() error = %v", err)
+			}
+			if got, _ := os.ReadFile(local); !bytes.Equal(got, data) {
+				t.Error("续传下载后本地文件的内容不一致")
+			}
+
+			remotePath := "/home/tester/partial.bin"
+			file, err := shell.client.Create(remotePath)
+			if err != nil {
+				t.Fatalf("创建远程文件失败: %v", err)
+			}
+			file.Write(tt.existing)
+			file.Close()
+			if _, _, err := resumeUpload(shell.client, mustOpen(t, source), remotePath, false, opts); err != nil {
+				t.Fatalf("resumeUpload() error = %v", err)
+			}
+			if got := readRemote(t, shell, remotePath); got != string(data) {
+				t.Error("续传上传后远程文件的内容不一致")
+			}
+		})
+	}
+}
+
+// TestResumeWindow 测试续传重新传输的长度不取决于本次的分块参数
+// 中断的传输使用较大的并发数和块大小时，空洞可能超出本次参数计算的范围
+func TestResumeWindow(t *testing.T) {
+	if resumeWindow < MaxConcurrency*MaxChunkSize {
+		t.Fatalf("resumeWindow = %d, want 至少 %d", resumeWindow, MaxConcurrency*MaxChunkSize)
+	}
+
+	// 以 -concurrency 12 -chunk-size 256K 传输时中断，第 1 块没有写入，之后的块都写完了
+	data := resumeData(3 << 20)
+	existing := withHole(data, len(data), 256<<10, 512<<10)
+	remotePath := filepath.Join(t.TempDir(), "remote.bin")
+	if err := os.WriteFile(remotePath, data, 0644); err != nil {
+		t.Fatalf("创建远程文件失败: %v", err)
+	}
+	client := newLatencySFTPClient(t, 0)
+	for _, opts := range []TransferOptions{{}, {Concurrency: 1}} {
+		local := filepath.Join(t.TempDir(), "data.bin")
+		if err := os.WriteFile(local, existing, 0644); err != nil {
+			t.Fatalf("创建本地文件失败: %v", err)
+		}
+		if _, _, err := resumeDownload(client, remotePath, local, false, opts); err != nil {
+			t.Fatalf("并发数 %d: resumeDownload() error = %v", opts.Concurrency, err)
+		}
+		if got, _ := os.ReadFile(local); !bytes.Equal(got, data) {
+			t.Errorf("并发数 %d: 续传后本地文件的内容不一致", opts.Concurrency)
+		}
+	}
+}
+
+// mustOpen 打开本地文件，测试结束时关闭
+func mustOpen(t *testing.T, p string) *os.File {
+	t.Helper()
+	file, err := os.Open(p)
+	if err != nil {
+		t.Fatalf("打开本地文件失败: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// TestResumeOffset 测试续传位置的计算
+func TestResumeOffset(t *testing.T) {
+	tests := []struct {
+		name             string
+		src, dst, window int64
+		want             int64
+		wantErr          bool
+	}{
+		{name: "重新传输末尾", src: 100, dst: 50, window: 10, want: 40},
+		{name: "目标文件比窗口小", src: 100, dst: 5, window: 10, want: 0},
+		{name: "目标文件已经完整", src: 100, dst: 100, window: 10, want: 90},
+		{name: "目标文件比源文件大", src: 100, dst: 101, window: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resumeOffset(tt.src, tt.dst, tt.window)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("resumeOffset() = (%d, %v), want %d, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestVerifyTail 测试只比较已传输部分末尾的内容
+func TestVerifyTail(t *testing.T) {
+	src := make([]byte, resumeVerifySize+10)
+	dst := make([]byte, len(src))
+	copy(dst, src)
+	// 校验范围之外的差异不会被发现
+	dst[0] = 1
+	if err := verifyTail(bytes.NewReader(src), bytes.NewReader(dst), int64(len(src)), resumeVerifySize); err != nil {
+		t.Errorf("verifyTail() error = %v, want nil", err)
+	}
+	dst[len(dst)-1] = 1
+	if err := verifyTail(bytes.NewReader(src), bytes.NewReader(dst), int64(len(src)), resumeVerifySize); !errors.Is(err, ErrResumeMismatch) {
+		t.Errorf("verifyTail() error = %v, want ErrResumeMismatch", err)
+	}
+	if err := verifyTail(bytes.NewReader(src), bytes.NewReader(nil), 0, resumeVerifySize); err != nil {
+		t.Errorf("verifyTail() 在没有已传输内容时 error = %v, want nil", err)
+	}
+}
+
+// TestResumeCommands 测试 reget 和 reput 命令
+func TestResumeCommands(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	dir := t.TempDir()
+	local := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(local, []byte("old"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+
+	// 本地路径是已经存在的目录时续传其中的同名文件
+	if err := resumeDownloadCommand(shell, []string{"-verify", "notes.txt", dir}); err != nil {
+		t.Fatalf("reget error = %v", err)
+	}
+	if data, _ := os.ReadFile(local); string(data) != "old notes" {
+		t.Errorf("本地文件的内容 = %q, want \"old notes\"", data)
+	}
+
+	if err := os.WriteFile(local, []byte("old notes, more"), 0644); err != nil {
+		t.Fatalf("写入本地文件失败: %v", err)
+	}
+	if err := resumeUploadCommand(shell, []string{"-verify", local, "~"}); err != nil {
+		t.Fatalf("reput error = %v", err)
+	}
+	if got := readRemote(t, shell, "/home/tester/notes.txt"); got != "old notes, more" {
+		t.Errorf("远程文件的内容 = %q, want \"old notes, more\"", got)
+	}
+
+	tests := []struct {
+		name    string
+		command func(*sftpShell, []string) error
+		args    []string
+		wantErr string
+	}{
+		{name: "reget 没有参数", command: resumeDownloadCommand, wantErr: "请指定"},
+		{name: "reput 没有参数", command: resumeUploadCommand, wantErr: "请指定"},
+		{name: "reget 目录", command: resumeDownloadCommand, args: []string{"docs"}, wantErr: "是目录"},
+		{name: "reput 目录", command: resumeUploadCommand, args: []string{dir}, wantErr: "是目录"},
+		{name: "未知选项", command: resumeDownloadCommand, args: []string{"-r", "docs"}, wantErr: "选项无效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.command(shell, tt.args); err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
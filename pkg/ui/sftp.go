@@ -5,11 +5,15 @@ package ui
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 
@@ -24,20 +28,22 @@ import (
 //   error: 如果会话启动失败则返回错误信息
 func StartSFTPSession(client *sshclient.Client) error {
 	// 基于 SSH 连接创建 SFTP 客户端
-	sftpClient, err := sftp.NewClient(client.GetConnection())
+	sftpClient, err := newSFTPClient(client)
 	if err != nil {
 		return fmt.Errorf("创建 SFTP 客户端失败: %w", err)
 	}
 	defer sftpClient.Close() // 会话结束时关闭 SFTP 连接
 
-	// 获取当前远程工作目录
+	// 获取当前远程工作目录，作为会话的起始目录和 cd ~ 的目标
 	pwd, err := sftpClient.Getwd()
 	if err != nil {
 		pwd = "/" // 如果获取失败，默认为根目录
 	}
-
-	// 创建标准输入读取器
+	// 创建标准输入读取器，覆盖确认也从这里读取
 	reader := bufio.NewReader(os.Stdin)
+	shell := newSFTPShell(sftpClient, pwd, reader)
+	shell.progress = NewTerminalProgress(os.Stdout)
+	shell.hasher = newRemoteHasher(client, sftpClient)
 
 	fmt.Println("进入 SFTP 交互模式，输入 'help' 查看可用命令")
 	fmt.Printf("连接到: %s@%s\n", client.GetConfig().Username, client.GetConfig().Host)
@@ -46,8 +52,8 @@ func StartSFTPSession(client *sshclient.Client) error {
 
 	// 主命令循环
 	for {
-		// 显示 SFTP 提示符
-		fmt.Print("sftp> ")
+		// 显示带当前远程目录的 SFTP 提示符
+		fmt.Print(shell.prompt())
 
 		// 读取用户输入
 		input, err := reader.ReadString('\n')
@@ -69,7 +75,7 @@ func StartSFTPSession(client *sshclient.Client) error {
 		args := parts[1:]
 
 		// 执行相应的 SFTP 命令
-		if err := executeSFTPCommand(sftpClient, command, args); err != nil {
+		if err := executeSFTPCommand(shell, command, args); err != nil {
 			fmt.Printf("错误: %v\n", err)
 		}
 
@@ -82,40 +88,106 @@ func StartSFTPSession(client *sshclient.Client) error {
 	return nil
 }
 
-// executeSFTPCommand 执行具体的 SFTP 命令
-// 根据用户输入的命令执行相应的文件操作
+// sftpShell 保存交互式 SFTP 会话的状态
+// SFTP 协议没有切换工作目录的请求，当前目录由客户端记录，相对路径在发送请求前拼接成绝对路径
+type sftpShell struct {
+	client   *sftp.Client
+	input    *bufio.Reader // 用户输入，用于覆盖确认
+	home     string        // 登录时的目录，cd 和 cd ~ 切换到这里
+	cwd      string        // 当前远程目录
+	oldwd    string        // 上一个远程目录，cd - 切换到这里
+	progress ProgressSink  // 显示传输进度，为 nil 时不显示
+	hasher   remoteHasher  // 计算远程文件的哈希，用于 -checksum 校验
+}
+
+// newSFTPShell 创建从指定目录开始的 SFTP 会话状态
 // 参数:
 //   client: SFTP 客户端对象
+//   home: 登录时的远程目录
+//   input: 用户输入
+func newSFTPShell(client *sftp.Client, home string, input *bufio.Reader) *sftpShell {
+	return &sftpShell{client: client, input: input, home: home, cwd: home}
+}
+
+// resolve 把用户输入的远程路径转换成绝对路径
+// "~" 和 "~/" 开头的路径相对于登录时的目录，其他相对路径相对于当前目录
+func (s *sftpShell) resolve(p string) string {
+	switch {
+	case p == "~":
+		return s.home
+	case strings.HasPrefix(p, "~/"):
+		return path.Join(s.home, p[2:])
+	case path.IsAbs(p):
+		return path.Clean(p)
+	default:
+		return path.Join(s.cwd, p)
+	}
+}
+
+// transferOptions 返回会话中传输文件使用的选项
+func (s *sftpShell) transferOptions() TransferOptions {
+	return TransferOptions{Progress: s.progress, hasher: s.hasher}
+}
+
+// display 返回用于显示的路径，登录目录显示为 "~"
+func (s *sftpShell) display(p string) string {
+	switch {
+	case s.home == "/":
+		return p
+	case p == s.home:
+		return "~"
+	case strings.HasPrefix(p, s.home+"/"):
+		return "~" + p[len(s.home):]
+	default:
+		return p
+	}
+}
+
+// prompt 返回显示当前远程目录的提示符
+func (s *sftpShell) prompt() string {
+	return fmt.Sprintf("sftp %s> ", s.display(s.cwd))
+}
+
+// executeSFTPCommand 执行具体的 SFTP 命令
+// 根据用户输入的命令执行相应的文件操作，相对路径按当前远程目录解析
+// 参数:
+//   shell: SFTP 会话状态
 //   command: 用户输入的命令
 //   args: 命令参数
 // 返回值:
 //   error: 如果命令执行失败则返回错误信息
-func executeSFTPCommand(client *sftp.Client, command string, args []string) error {
+func executeSFTPCommand(shell *sftpShell, command string, args []string) error {
 	switch command {
 	case "help":
 		// 显示帮助信息
 		showSFTPHelp()
 	case "ls", "dir":
 		// 列出远程目录内容
-		return listRemoteDirectory(client, args)
+		return listRemoteDirectory(shell, args)
 	case "pwd":
 		// 显示当前远程工作目录
-		return showRemotePwd(client)
+		return showRemotePwd(shell)
 	case "cd":
 		// 切换远程工作目录
-		return changeRemoteDirectory(client, args)
+		return changeRemoteDirectory(shell, args)
 	case "get":
 		// 下载文件
-		return downloadFileCommand(client, args)
+		return downloadFileCommand(shell, args)
 	case "put":
 		// 上传文件
-		return uploadFileCommand(client, args)
+		return uploadFileCommand(shell, args)
+	case "reget":
+		// 续传下载
+		return resumeDownloadCommand(shell, args)
+	case "reput":
+		// 续传上传
+		return resumeUploadCommand(shell, args)
 	case "mkdir":
 		// 创建远程目录
-		return createRemoteDirectory(client, args)
+		return createRemoteDirectory(shell, args)
 	case "rm":
 		// 删除远程文件
-		return removeRemoteFile(client, args)
+		return removeRemoteFile(shell, args)
 	case "exit", "quit":
 		// 退出命令
 		fmt.Println("再见!")
@@ -131,25 +203,30 @@ func showSFTPHelp() {
 	fmt.Println("可用的 SFTP 命令:")
 	fmt.Println("  ls [目录]     - 列出远程目录内容")
 	fmt.Println("  pwd          - 显示当前远程工作目录")
-	fmt.Println("  cd <目录>     - 切换远程工作目录")
-	fmt.Println("  get <远程文件> [本地文件] - 下载文件")
-	fmt.Println("  put <本地文件> [远程文件] - 上传文件")
+	fmt.Println("  cd [目录]     - 切换远程工作目录，cd - 返回上一个目录，cd 或 cd ~ 返回登录目录")
+	fmt.Println("  get [-r] [-p] [-owner] [-symlinks=策略] [-checksum] <远程文件> [本地文件] - 下载文件，-r 递归下载目录")
+	fmt.Println("  put [-r] [-p] [-owner] [-symlinks=策略] [-checksum] <本地文件> [远程文件] - 上传文件，-r 递归上传目录")
+	fmt.Println("  reget [-verify] [-checksum] [-p] <远程文件> [本地文件] - 从本地文件的末尾继续下载，-verify 先校验已下载的部分")
+	fmt.Println("  reput [-verify] [-checksum] [-p] <本地文件> [远程文件] - 从远程文件的末尾继续上传，-verify 先校验已上传的部分")
 	fmt.Println("  mkdir <目录>  - 创建远程目录")
 	fmt.Println("  rm <文件>     - 删除远程文件")
 	fmt.Println("  help         - 显示此帮助信息")
 	fmt.Println("  exit/quit    - 退出 SFTP 会话")
+	fmt.Println("符号链接策略: follow 传输链接指向的内容（默认），preserve 重建链接，skip 跳过")
+	fmt.Println("-checksum 在传输完成后比较本地和远程文件的 SHA-256")
+	fmt.Println("-p 保留源文件的权限、访问时间和修改时间，-owner 保留所有者和组（通常需要 root 权限）")
 }
 
 // listRemoteDirectory 列出远程目录内容
-func listRemoteDirectory(client *sftp.Client, args []string) error {
-	// 确定要列出的目录
-	dir := "."
+func listRemoteDirectory(shell *sftpShell, args []string) error {
+	// 确定要列出的目录，默认为当前目录
+	dir := shell.cwd
 	if len(args) > 0 {
-		dir = args[0]
+		dir = shell.resolve(args[0])
 	}
 
 	// 读取目录内容
-	files, err := client.ReadDir(dir)
+	files, err := shell.client.ReadDir(dir)
 	if err != nil {
 		return fmt.Errorf("读取目录失败: %w", err)
 	}
@@ -171,34 +248,55 @@ func listRemoteDirectory(client *sftp.Client, args []string) error {
 }
 
 // showRemotePwd 显示当前远程工作目录
-func showRemotePwd(client *sftp.Client) error {
-	pwd, err := client.Getwd()
-	if err != nil {
-		return fmt.Errorf("获取当前目录失败: %w", err)
-	}
-	fmt.Println(pwd)
+func showRemotePwd(shell *sftpShell) error {
+	fmt.Println(shell.cwd)
 	return nil
 }
 
 // changeRemoteDirectory 切换远程工作目录
-func changeRemoteDirectory(client *sftp.Client, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("请指定要切换到的目录")
+// 没有参数或参数为 "~" 时切换到登录目录，"-" 切换到上一个目录
+// 目标通过 RealPath 规范化（解析 ".." 和符号链接），并且必须是目录
+func changeRemoteDirectory(shell *sftpShell, args []string) error {
+	target := shell.home
+	if len(args) > 0 {
+		target = args[0]
+	}
+	if target == "-" {
+		if shell.oldwd == "" {
+			return fmt.Errorf("没有上一个目录")
+		}
+		target = shell.oldwd
 	}
 
-	// SFTP 客户端没有 Chdir 方法，我们需要通过其他方式实现
-	// 先检查目录是否存在
-	_, err := client.Stat(args[0])
+	dir, err := shell.client.RealPath(shell.resolve(target))
+	if err != nil {
+		return fmt.Errorf("目录不存在或无法访问: %w", err)
+	}
+	info, err := shell.client.Stat(dir)
 	if err != nil {
 		return fmt.Errorf("目录不存在或无法访问: %w", err)
 	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s 不是目录", dir)
+	}
 
-	// 显示提示信息（注意：SFTP 协议本身不支持切换工作目录）
-	fmt.Printf("注意: SFTP 协议不支持切换工作目录，请在命令中使用完整路径\n")
-	fmt.Printf("目录 %s 存在且可访问\n", args[0])
+	shell.oldwd, shell.cwd = shell.cwd, dir
+	if len(args) > 0 && args[0] == "-" {
+		fmt.Println(dir) // 与 shell 一样，cd - 显示切换到的目录
+	}
 	return nil
 }
 
+// newSFTPClient 基于 SSH 客户端的连接创建 SFTP 客户端
+// 连接尚未建立时返回错误，而不是在底层库中触发空指针
+func newSFTPClient(client *sshclient.Client) (*sftp.Client, error) {
+	conn := client.GetConnection()
+	if conn == nil {
+		return nil, errors.New("SSH 连接未建立")
+	}
+	return sftp.NewClient(conn)
+}
+
 // UploadFile 上传文件到远程服务器
 // 这是一个公共函数，可以被其他模块调用
 // 参数:
@@ -208,34 +306,35 @@ func changeRemoteDirectory(client *sftp.Client, args []string) error {
 // 返回值:
 //   error: 如果上传失败则返回错误信息
 func UploadFile(client *sshclient.Client, localPath, remotePath string) error {
-	// 创建 SFTP 客户端
-	sftpClient, err := sftp.NewClient(client.GetConnection())
-	if err != nil {
-		return fmt.Errorf("创建 SFTP 客户端失败: %w", err)
-	}
-	defer sftpClient.Close()
+	return UploadFileWithOptions(client, localPath, remotePath, TransferOptions{})
+}
 
-	// 打开本地文件
+// UploadFileWithOptions 按指定的选项上传文件到远程服务器
+// 参数:
+//   client: SSH 客户端对象
+//   localPath: 本地文件路径
+//   remotePath: 远程文件路径
+//   opts: 传输选项，如报告进度的 ProgressSink
+// 返回值:
+//   error: 如果上传失败则返回错误信息
+func UploadFileWithOptions(client *sshclient.Client, localPath, remotePath string, opts TransferOptions) error {
+	// 先打开本地文件，本地文件有问题时无需建立 SFTP 会话
 	localFile, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("打开本地文件失败: %w", err)
 	}
 	defer localFile.Close()
 
-	// 创建远程文件
-	remoteFile, err := sftpClient.Create(remotePath)
-	if err != nil {
-		return fmt.Errorf("创建远程文件失败: %w", err)
-	}
-	defer remoteFile.Close()
-
-	// 复制文件内容
-	_, err = io.Copy(remoteFile, localFile)
+	// 创建 SFTP 客户端
+	sftpClient, err := newSFTPClient(client)
 	if err != nil {
-		return fmt.Errorf("文件传输失败: %w", err)
+		return fmt.Errorf("创建 SFTP 客户端失败: %w", err)
 	}
+	defer sftpClient.Close()
 
-	return nil
+	// 创建远程文件并复制内容
+	_, err = uploadFile(sftpClient, localFile, remotePath, withHasher(opts, client, sftpClient))
+	return err
 }
 
 // DownloadFile 从远程服务器下载文件
@@ -247,79 +346,415 @@ func UploadFile(client *sshclient.Client, localPath, remotePath string) error {
 // 返回值:
 //   error: 如果下载失败则返回错误信息
 func DownloadFile(client *sshclient.Client, remotePath, localPath string) error {
+	return DownloadFileWithOptions(client, remotePath, localPath, TransferOptions{})
+}
+
+// DownloadFileWithOptions 按指定的选项从远程服务器下载文件
+// 参数:
+//   client: SSH 客户端对象
+//   remotePath: 远程文件路径
+//   localPath: 本地文件路径
+//   opts: 传输选项，如报告进度的 ProgressSink
+// 返回值:
+//   error: 如果下载失败则返回错误信息
+func DownloadFileWithOptions(client *sshclient.Client, remotePath, localPath string, opts TransferOptions) error {
 	// 创建 SFTP 客户端
-	sftpClient, err := sftp.NewClient(client.GetConnection())
+	sftpClient, err := newSFTPClient(client)
 	if err != nil {
 		return fmt.Errorf("创建 SFTP 客户端失败: %w", err)
 	}
 	defer sftpClient.Close()
 
-	// 打开远程文件
-	remoteFile, err := sftpClient.Open(remotePath)
+	// 打开远程文件，创建本地文件并复制内容
+	_, err = downloadFile(sftpClient, remotePath, localPath, withHasher(opts, client, sftpClient))
+	return err
+}
+
+// transferFlags 是 get 和 put 命令的选项
+type transferFlags struct {
+	recursive bool          // -r 递归传输目录
+	symlinks  SymlinkPolicy // -symlinks 递归传输时符号链接的处理策略
+	checksum  bool          // -checksum 传输后比较 SHA-256
+	preserve  bool          // -p 保留权限、访问时间和修改时间
+	owner     bool          // -owner 保留所有者和组
+}
+
+// parseTransferArgs 解析 get 和 put 命令的选项
+// 参数:
+//   command: 命令名，用于错误信息
+//   args: 命令参数，选项在路径之前
+// 返回值:
+//   *transferFlags: 解析后的选项
+//   []string: 选项之后的路径参数
+//   error: 如果选项无效则返回错误
+func parseTransferArgs(command string, args []string) (*transferFlags, []string, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	recursive := fs.Bool("r", false, "递归传输目录")
+	symlinks := fs.String("symlinks", string(SymlinkFollow), "符号链接的处理策略")
+	checksum := fs.Bool("checksum", false, "传输后比较本地和远程文件的 SHA-256")
+	preserve := fs.Bool("p", false, "保留权限、访问时间和修改时间")
+	owner := fs.Bool("owner", false, "保留所有者和组")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%s 的选项无效: %w", command, err)
+	}
+	policy, err := ParseSymlinkPolicy(*symlinks)
 	if err != nil {
-		return fmt.Errorf("打开远程文件失败: %w", err)
+		return nil, nil, err
 	}
-	defer remoteFile.Close()
+	return &transferFlags{
+		recursive: *recursive,
+		symlinks:  policy,
+		checksum:  *checksum,
+		preserve:  *preserve,
+		owner:     *owner,
+	}, fs.Args(), nil
+}
 
-	// 创建本地文件
-	localFile, err := os.Create(localPath)
+// uploadFileCommand 处理上传文件命令
+// 远程路径是已经存在的目录时上传到该目录中，远程文件已经存在时先确认是否覆盖
+func uploadFileCommand(shell *sftpShell, args []string) error {
+	flags, args, err := parseTransferArgs("put", args)
 	if err != nil {
-		return fmt.Errorf("创建本地文件失败: %w", err)
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("请指定要上传的本地文件")
+	}
+
+	localPath := expandLocalPath(args[0])
+	remotePath := shell.resolve(filepath.Base(localPath)) // 默认上传到当前远程目录，使用原文件名
+
+	if len(args) > 1 {
+		remotePath = shell.resolve(args[1]) // 用户指定了远程路径
+	}
+	opts := shell.transferOptions()
+	opts.Checksum = flags.checksum
+	opts.Preserve = flags.preserve
+	opts.PreserveOwner = flags.owner
+	if flags.recursive {
+		opts.Symlinks = flags.symlinks
+		return uploadDirCommand(shell, localPath, remotePath, len(args) > 1, opts)
+	}
+
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
 	}
 	defer localFile.Close()
+	if info, err := localFile.Stat(); err == nil && info.IsDir() {
+		return fmt.Errorf("%s 是目录，上传目录请使用 put -r", localPath)
+	}
+
+	if info, err := shell.client.Stat(remotePath); err == nil {
+		if info.IsDir() {
+			remotePath = path.Join(remotePath, filepath.Base(localPath))
+			info, err = shell.client.Stat(remotePath)
+		}
+		if err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("远程路径 %s 是目录", remotePath)
+			}
+			if !confirm(shell.input, fmt.Sprintf("远程文件 %s 已存在，是否覆盖?", remotePath)) {
+				fmt.Println("已取消上传")
+				return nil
+			}
+		}
+	}
 
-	// 复制文件内容
-	_, err = io.Copy(localFile, remoteFile)
+	fmt.Printf("上传 %s 到 %s...\n", localPath, remotePath)
+	start := time.Now()
+	n, err := uploadFile(shell.client, localFile, remotePath, opts)
 	if err != nil {
-		return fmt.Errorf("文件传输失败: %w", err)
+		return err
 	}
+	fmt.Printf("上传完成: %s\n", transferSummary(n, time.Since(start)))
+	reportChecksum(opts)
+	return nil
+}
 
+// downloadFileCommand 处理下载文件命令
+// 本地路径是已经存在的目录时下载到该目录中，本地文件已经存在时先确认是否覆盖
+func downloadFileCommand(shell *sftpShell, args []string) error {
+	flags, args, err := parseTransferArgs("get", args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("请指定要下载的远程文件")
+	}
+
+	remotePath := shell.resolve(args[0])
+	localPath := path.Base(remotePath) // 默认使用文件名作为本地路径
+
+	if len(args) > 1 {
+		localPath = expandLocalPath(args[1]) // 用户指定了本地路径
+	}
+	opts := shell.transferOptions()
+	opts.Checksum = flags.checksum
+	opts.Preserve = flags.preserve
+	opts.PreserveOwner = flags.owner
+	if flags.recursive {
+		opts.Symlinks = flags.symlinks
+		return downloadDirCommand(shell, remotePath, localPath, len(args) > 1, opts)
+	}
+
+	info, err := shell.client.Stat(remotePath)
+	if err != nil {
+		return fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s 是目录，下载目录请使用 get -r", remotePath)
+	}
+
+	if info, err := os.Stat(localPath); err == nil {
+		if info.IsDir() {
+			localPath = filepath.Join(localPath, path.Base(remotePath))
+			info, err = os.Stat(localPath)
+		}
+		if err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("本地路径 %s 是目录", localPath)
+			}
+			if !confirm(shell.input, fmt.Sprintf("本地文件 %s 已存在，是否覆盖?", localPath)) {
+				fmt.Println("已取消下载")
+				return nil
+			}
+		}
+	}
+
+	fmt.Printf("下载 %s 到 %s...\n", remotePath, localPath)
+	start := time.Now()
+	n, err := downloadFile(shell.client, remotePath, localPath, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("下载完成: %s\n", transferSummary(n, time.Since(start)))
+	reportChecksum(opts)
 	return nil
 }
 
-// uploadFileCommand 处理上传文件命令
-func uploadFileCommand(client *sftp.Client, args []string) error {
+// uploadDirCommand 处理 put -r，递归上传目录
+// 与 scp -r 相同，明确指定的远程路径是已经存在的目录时上传到该目录中；
+// 目标目录已经存在时先确认是否合并
+// 参数:
+//   explicit: 用户是否指定了远程路径
+func uploadDirCommand(shell *sftpShell, localPath, remotePath string, explicit bool, opts TransferOptions) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("打开本地目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s 不是目录", localPath)
+	}
+
+	if info, err := shell.client.Stat(remotePath); err == nil {
+		if info.IsDir() && explicit {
+			remotePath = path.Join(remotePath, filepath.Base(filepath.Clean(localPath)))
+			info, err = shell.client.Stat(remotePath)
+		}
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("远程路径 %s 不是目录", remotePath)
+			}
+			if !confirm(shell.input, fmt.Sprintf("远程目录 %s 已存在，其中的同名文件会被覆盖，是否继续?", remotePath)) {
+				fmt.Println("已取消上传")
+				return nil
+			}
+		}
+	}
+
+	fmt.Printf("上传目录 %s 到 %s...\n", localPath, remotePath)
+	start := time.Now()
+	summary := uploadTree(shell.client, localPath, remotePath, opts)
+	return reportTreeTransfer("上传", summary, time.Since(start))
+}
+
+// downloadDirCommand 处理 get -r，递归下载目录
+// 与 scp -r 相同，明确指定的本地路径是已经存在的目录时下载到该目录中；
+// 目标目录已经存在时先确认是否合并
+// 参数:
+//   explicit: 用户是否指定了本地路径
+func downloadDirCommand(shell *sftpShell, remotePath, localPath string, explicit bool, opts TransferOptions) error {
+	info, err := shell.client.Stat(remotePath)
+	if err != nil {
+		return fmt.Errorf("打开远程目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s 不是目录", remotePath)
+	}
+
+	if info, err := os.Stat(localPath); err == nil {
+		if info.IsDir() && explicit {
+			localPath = filepath.Join(localPath, path.Base(remotePath))
+			info, err = os.Stat(localPath)
+		}
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("本地路径 %s 不是目录", localPath)
+			}
+			if !confirm(shell.input, fmt.Sprintf("本地目录 %s 已存在，其中的同名文件会被覆盖，是否继续?", localPath)) {
+				fmt.Println("已取消下载")
+				return nil
+			}
+		}
+	}
+
+	fmt.Printf("下载目录 %s 到 %s...\n", remotePath, localPath)
+	start := time.Now()
+	summary := downloadTree(shell.client, remotePath, localPath, opts)
+	return reportTreeTransfer("下载", summary, time.Since(start))
+}
+
+// reportTreeTransfer 显示递归传输的失败列表和统计信息
+// 返回值:
+//   error: 有文件失败时返回错误，说明失败的数量
+func reportTreeTransfer(action string, summary *TransferSummary, elapsed time.Duration) error {
+	for _, e := range summary.Errors {
+		fmt.Printf("  失败: %v\n", e)
+	}
+	fmt.Printf("%s完成: %s，用时 %s\n", action, summary, elapsed.Round(time.Millisecond))
+	if summary.Failed() {
+		return fmt.Errorf("%d 个文件或目录%s失败", len(summary.Errors), action)
+	}
+	return nil
+}
+
+// resumeFlags 是 reget 和 reput 命令的选项
+type resumeFlags struct {
+	verify   bool // -verify 续传前校验已传输部分末尾的哈希
+	checksum bool // -checksum 传输后比较整个文件的 SHA-256
+	preserve bool // -p 保留权限、访问时间和修改时间
+}
+
+// parseResumeArgs 解析 reget 和 reput 命令的选项
+// 参数:
+//   command: 命令名，用于错误信息
+//   args: 命令参数，选项在路径之前
+// 返回值:
+//   *resumeFlags: 解析后的选项
+//   []string: 选项之后的路径参数
+//   error: 如果选项无效则返回错误
+func parseResumeArgs(command string, args []string) (*resumeFlags, []string, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	verify := fs.Bool("verify", false, "续传前校验已传输部分末尾的哈希")
+	checksum := fs.Bool("checksum", false, "传输后比较本地和远程文件的 SHA-256")
+	preserve := fs.Bool("p", false, "保留权限、访问时间和修改时间")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%s 的选项无效: %w", command, err)
+	}
+	return &resumeFlags{verify: *verify, checksum: *checksum, preserve: *preserve}, fs.Args(), nil
+}
+
+// resumeUploadCommand 处理 reput 命令，从远程文件的末尾继续上传
+// 远程路径是已经存在的目录时续传该目录中的同名文件
+func resumeUploadCommand(shell *sftpShell, args []string) error {
+	flags, args, err := parseResumeArgs("reput", args)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("请指定要上传的本地文件")
 	}
 
-	localPath := args[0]
-	remotePath := filepath.Base(localPath) // 默认使用文件名作为远程路径
-
+	localPath := expandLocalPath(args[0])
+	remotePath := shell.resolve(filepath.Base(localPath))
 	if len(args) > 1 {
-		remotePath = args[1] // 用户指定了远程路径
+		remotePath = shell.resolve(args[1])
 	}
 
-	// 这里需要将 sftp.Client 转换为 sshclient.Client
-	// 实际实现中需要保存原始的 sshclient.Client 引用
-	fmt.Printf("上传 %s 到 %s...\n", localPath, remotePath)
-	return fmt.Errorf("上传功能需要完整的客户端对象")
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer localFile.Close()
+	if info, err := localFile.Stat(); err == nil && info.IsDir() {
+		return fmt.Errorf("%s 是目录", localPath)
+	}
+	if info, err := shell.client.Stat(remotePath); err == nil && info.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	}
+
+	fmt.Printf("续传 %s 到 %s...\n", localPath, remotePath)
+	start := time.Now()
+	opts := shell.transferOptions()
+	opts.Checksum = flags.checksum
+	opts.Preserve = flags.preserve
+	offset, n, err := resumeUpload(shell.client, localFile, remotePath, flags.verify, opts)
+	if err != nil {
+		return err
+	}
+	reportResume("上传", offset, n, time.Since(start))
+	reportChecksum(opts)
+	return nil
 }
 
-// downloadFileCommand 处理下载文件命令
-func downloadFileCommand(client *sftp.Client, args []string) error {
+// resumeDownloadCommand 处理 reget 命令，从本地文件的末尾继续下载
+// 本地路径是已经存在的目录时续传该目录中的同名文件
+func resumeDownloadCommand(shell *sftpShell, args []string) error {
+	flags, args, err := parseResumeArgs("reget", args)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("请指定要下载的远程文件")
 	}
 
-	remotePath := args[0]
-	localPath := filepath.Base(remotePath) // 默认使用文件名作为本地路径
-
+	remotePath := shell.resolve(args[0])
+	localPath := path.Base(remotePath)
 	if len(args) > 1 {
-		localPath = args[1] // 用户指定了本地路径
+		localPath = expandLocalPath(args[1])
 	}
 
-	fmt.Printf("下载 %s 到 %s...\n", remotePath, localPath)
-	return fmt.Errorf("下载功能需要完整的客户端对象")
+	info, err := shell.client.Stat(remotePath)
+	if err != nil {
+		return fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s 是目录", remotePath)
+	}
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	fmt.Printf("续传 %s 到 %s...\n", remotePath, localPath)
+	start := time.Now()
+	opts := shell.transferOptions()
+	opts.Checksum = flags.checksum
+	opts.Preserve = flags.preserve
+	offset, n, err := resumeDownload(shell.client, remotePath, localPath, flags.verify, opts)
+	if err != nil {
+		return err
+	}
+	reportResume("下载", offset, n, time.Since(start))
+	reportChecksum(opts)
+	return nil
+}
+
+// reportChecksum 在传输成功并且要求校验时显示校验结果
+func reportChecksum(opts TransferOptions) {
+	if opts.Checksum {
+		fmt.Println("SHA-256 校验通过")
+	}
+}
+
+// reportResume 显示续传的起始位置和传输统计
+// 续传总是重新传输目标文件的末尾，目标文件已经完整时也是如此，所以这里不区分已经完整的文件
+func reportResume(action string, offset, n int64, elapsed time.Duration) {
+	if offset > 0 {
+		fmt.Printf("从 %s 处继续%s（目标文件末尾的部分重新传输）\n", formatBytes(offset), action)
+	}
+	fmt.Printf("%s完成: %s\n", action, transferSummary(n, elapsed))
 }
 
 // createRemoteDirectory 创建远程目录
-func createRemoteDirectory(client *sftp.Client, args []string) error {
+func createRemoteDirectory(shell *sftpShell, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("请指定要创建的目录名")
 	}
 
-	if err := client.Mkdir(args[0]); err != nil {
+	if err := shell.client.Mkdir(shell.resolve(args[0])); err != nil {
 		return fmt.Errorf("创建目录失败: %w", err)
 	}
 
@@ -328,12 +763,12 @@ func createRemoteDirectory(client *sftp.Client, args []string) error {
 }
 
 // removeRemoteFile 删除远程文件
-func removeRemoteFile(client *sftp.Client, args []string) error {
+func removeRemoteFile(shell *sftpShell, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("请指定要删除的文件名")
 	}
 
-	if err := client.Remove(args[0]); err != nil {
+	if err := shell.client.Remove(shell.resolve(args[0])); err != nil {
 		return fmt.Errorf("删除文件失败: %w", err)
 	}
 
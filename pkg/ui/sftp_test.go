@@ -0,0 +1,300 @@
+// sftp_test 提供交互式 SFTP 会话的单元测试
+// 使用 pkg/sftp 自带的内存文件系统作为服务器，不需要网络连接
+package ui
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTPShell 启动内存中的 SFTP 服务器，返回从 /home/tester 开始的会话
+// 服务器上预先创建了 /home/tester/docs、/var/log 和文件 /home/tester/notes.txt
+// 参数:
+//   input: 模拟的用户输入，用于回答覆盖确认
+func newTestSFTPShell(t *testing.T, input string) *sftpShell {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler(), sftp.WithStartDirectory("/home/tester"))
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatalf("创建 SFTP 客户端失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	for _, dir := range []string{"/home/tester/docs", "/var/log"} {
+		if err := client.MkdirAll(dir); err != nil {
+			t.Fatalf("创建目录 %s 失败: %v", dir, err)
+		}
+	}
+	file, err := client.Create("/home/tester/notes.txt")
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	file.Write([]byte("old notes"))
+	file.Close()
+
+	home, err := client.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	return newSFTPShell(client, home, bufio.NewReader(strings.NewReader(input)))
+}
+
+// TestSFTPShell_Resolve 测试远程路径的解析
+func TestSFTPShell_Resolve(t *testing.T) {
+	shell := &sftpShell{home: "/home/tester", cwd: "/var/log"}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "相对路径", input: "syslog", want: "/var/log/syslog"},
+		{name: "上级目录", input: "../lib", want: "/var/lib"},
+		{name: "当前目录", input: ".", want: "/var/log"},
+		{name: "绝对路径", input: "/etc//hosts", want: "/etc/hosts"},
+		{name: "登录目录", input: "~", want: "/home/tester"},
+		{name: "登录目录下的路径", input: "~/docs/a.txt", want: "/home/tester/docs/a.txt"},
+		{name: "其他用户的目录不展开", input: "~root", want: "/var/log/~root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shell.resolve(tt.input); got != tt.want {
+				t.Errorf("resolve(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSFTPShell_Prompt 测试提示符中的当前目录
+func TestSFTPShell_Prompt(t *testing.T) {
+	tests := []struct {
+		name string
+		home string
+		cwd  string
+		want string
+	}{
+		{name: "登录目录", home: "/home/tester", cwd: "/home/tester", want: "sftp ~> "},
+		{name: "登录目录的子目录", home: "/home/tester", cwd: "/home/tester/docs", want: "sftp ~/docs> "},
+		{name: "前缀相同的其他目录", home: "/home/tester", cwd: "/home/tester2", want: "sftp /home/tester2> "},
+		{name: "其他目录", home: "/home/tester", cwd: "/var/log", want: "sftp /var/log> "},
+		{name: "登录目录是根目录", home: "/", cwd: "/var", want: "sftp /var> "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := &sftpShell{home: tt.home, cwd: tt.cwd}
+			if got := shell.prompt(); got != tt.want {
+				t.Errorf("prompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestChangeRemoteDirectory 测试 cd 命令依次切换目录
+func TestChangeRemoteDirectory(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	if shell.home != "/home/tester" {
+		t.Fatalf("登录目录 = %q, want /home/tester", shell.home)
+	}
+
+	steps := []struct {
+		name    string
+		args    []string
+		wantCwd string
+		wantErr string
+	}{
+		{name: "相对路径", args: []string{"docs"}, wantCwd: "/home/tester/docs"},
+		{name: "上级目录", args: []string{".."}, wantCwd: "/home/tester"},
+		{name: "绝对路径", args: []string{"/var/log"}, wantCwd: "/var/log"},
+		{name: "返回上一个目录", args: []string{"-"}, wantCwd: "/home/tester"},
+		{name: "再次返回", args: []string{"-"}, wantCwd: "/var/log"},
+		{name: "波浪号", args: []string{"~/docs"}, wantCwd: "/home/tester/docs"},
+		{name: "没有参数时返回登录目录", args: nil, wantCwd: "/home/tester"},
+		{name: "不存在的目录", args: []string{"missing"}, wantCwd: "/home/tester", wantErr: "目录不存在或无法访问"},
+		{name: "不是目录", args: []string{"notes.txt"}, wantCwd: "/home/tester", wantErr: "不是目录"},
+	}
+	for _, step := range steps {
+		err := changeRemoteDirectory(shell, step.args)
+		if step.wantErr != "" {
+			if err == nil || !contains(err.Error(), step.wantErr) {
+				t.Errorf("%s: changeRemoteDirectory(%v) error = %v, want %s", step.name, step.args, err, step.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("%s: changeRemoteDirectory(%v) error = %v", step.name, step.args, err)
+		}
+		if shell.cwd != step.wantCwd {
+			t.Errorf("%s: 当前目录 = %q, want %q", step.name, shell.cwd, step.wantCwd)
+		}
+	}
+}
+
+// TestChangeRemoteDirectory_NoPrevious 测试没有上一个目录时的 cd -
+func TestChangeRemoteDirectory_NoPrevious(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	if err := changeRemoteDirectory(shell, []string{"-"}); err == nil || !contains(err.Error(), "没有上一个目录") {
+		t.Errorf("changeRemoteDirectory(-) error = %v, want 没有上一个目录", err)
+	}
+}
+
+// TestSFTPCommands_RelativePaths 测试 mkdir、ls 和 rm 按当前目录解析相对路径
+func TestSFTPCommands_RelativePaths(t *testing.T) {
+	shell := newTestSFTPShell(t, "")
+	if err := changeRemoteDirectory(shell, []string{"/var/log"}); err != nil {
+		t.Fatalf("cd error = %v", err)
+	}
+
+	if err := executeSFTPCommand(shell, "mkdir", []string{"app"}); err != nil {
+		t.Fatalf("mkdir error = %v", err)
+	}
+	if info, err := shell.client.Stat("/var/log/app"); err != nil || !info.IsDir() {
+		t.Errorf("mkdir app 没有在当前目录中创建目录: %v", err)
+	}
+	if err := executeSFTPCommand(shell, "ls", []string{"app"}); err != nil {
+		t.Errorf("ls app error = %v", err)
+	}
+
+	if err := executeSFTPCommand(shell, "rm", []string{"~/notes.txt"}); err != nil {
+		t.Fatalf("rm error = %v", err)
+	}
+	if _, err := shell.client.Stat("/home/tester/notes.txt"); err == nil {
+		t.Error("rm ~/notes.txt 之后文件仍然存在")
+	}
+}
+
+// readRemote 读取远程文件的内容
+func readRemote(t *testing.T, shell *sftpShell, p string) string {
+	t.Helper()
+	file, err := shell.client.Open(p)
+	if err != nil {
+		t.Fatalf("打开远程文件 %s 失败: %v", p, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("读取远程文件 %s 失败: %v", p, err)
+	}
+	return string(data)
+}
+
+// TestUploadFileCommand 测试 put 命令的目标路径和覆盖确认
+func TestUploadFileCommand(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(local, []byte("new notes"), 0644); err != nil {
+		t.Fatalf("创建本地文件失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string // 覆盖确认的回答
+		args       []string
+		remotePath string
+		want       string
+		wantErr    string
+	}{
+		{name: "相对于当前目录的路径", args: []string{local, "docs/a.txt"}, remotePath: "/home/tester/docs/a.txt", want: "new notes"},
+		{name: "上传到已经存在的目录", args: []string{local, "docs"}, remotePath: "/home/tester/docs/notes.txt", want: "new notes"},
+		{name: "确认覆盖", input: "y\n", args: []string{local}, remotePath: "/home/tester/notes.txt", want: "new notes"},
+		{name: "拒绝覆盖", input: "n\n", args: []string{local}, remotePath: "/home/tester/notes.txt", want: "old notes"},
+		{name: "没有回答时不覆盖", args: []string{local}, remotePath: "/home/tester/notes.txt", want: "old notes"},
+		{name: "本地文件不存在", args: []string{local + ".missing"}, wantErr: "打开本地文件失败"},
+		{name: "本地路径是目录", args: []string{filepath.Dir(local)}, wantErr: "是目录"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := newTestSFTPShell(t, tt.input)
+			err := uploadFileCommand(shell, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !contains(err.Error(), tt.wantErr) {
+					t.Errorf("uploadFileCommand(%v) error = %v, want %s", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("uploadFileCommand(%v) error = %v", tt.args, err)
+			}
+			if got := readRemote(t, shell, tt.remotePath); got != tt.want {
+				t.Errorf("%s 的内容 = %q, want %q", tt.remotePath, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDownloadFileCommand 测试 get 命令的目标路径和覆盖确认
+func TestDownloadFileCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string // 覆盖确认的回答
+		existing string // 本地已经存在的 notes.txt 的内容，为空时不存在
+		target   string // get 的本地参数，相对于临时目录，为空时表示临时目录本身
+		want     string
+		wantErr  string
+	}{
+		{name: "下载到指定文件", target: "copy.txt", want: "old notes"},
+		{name: "下载到已经存在的目录", want: "old notes"},
+		{name: "确认覆盖", input: "yes\n", existing: "local", want: "old notes"},
+		{name: "拒绝覆盖", input: "\n", existing: "local", want: "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := newTestSFTPShell(t, tt.input)
+			dir := t.TempDir()
+			if tt.existing != "" {
+				if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(tt.existing), 0644); err != nil {
+					t.Fatalf("创建本地文件失败: %v", err)
+				}
+			}
+			local := filepath.Join(dir, tt.target)
+			if err := downloadFileCommand(shell, []string{"notes.txt", local}); err != nil {
+				t.Fatalf("downloadFileCommand() error = %v", err)
+			}
+
+			if tt.target == "" {
+				local = filepath.Join(dir, "notes.txt")
+			}
+			data, err := os.ReadFile(local)
+			if err != nil {
+				t.Fatalf("读取本地文件失败: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("%s 的内容 = %q, want %q", local, data, tt.want)
+			}
+		})
+	}
+
+	shell := newTestSFTPShell(t, "")
+	if err := downloadFileCommand(shell, []string{"missing.txt", t.TempDir()}); err == nil || !contains(err.Error(), "打开远程文件失败") {
+		t.Errorf("下载不存在的文件 error = %v, want 打开远程文件失败", err)
+	}
+	if err := downloadFileCommand(shell, []string{"docs", t.TempDir()}); err == nil || !contains(err.Error(), "是目录") {
+		t.Errorf("下载目录 error = %v, want 是目录", err)
+	}
+}
+
+// TestFormatBytes 测试字节数的格式化
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 1536, want: "1.5 KB"},
+		{n: 5 << 20, want: "5.0 MB"},
+		{n: 3 << 40, want: "3.0 TB"},
+		{n: 2048 << 40, want: "2048.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
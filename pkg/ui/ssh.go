@@ -5,24 +5,55 @@ package ui
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
-	"golang.org/x/crypto/ssh"
 	"golang.org/x/term"
 
+	"gossh/internal/asciicast"
 	"gossh/internal/sshclient"
 )
 
+// SessionOptions 是交互式 SSH 会话的选项
+type SessionOptions struct {
+	// EscapeChar 是转义字符，为 0 时禁用转义命令
+	// 转义命令只在标准输入是终端时有效
+	EscapeChar byte
+
+	// RecordFile 是会话录像（asciicast v2 格式）的文件路径，为空时不录像
+	RecordFile string
+	// RecordInput 表示录像中是否包含用户输入
+	// 输入可能包含密码等敏感信息，默认只记录输出
+	RecordInput bool
+}
+
 // StartSSHSession 启动交互式 SSH 会话
 // 用户可以在远程服务器上执行命令，就像本地终端一样
+// 使用默认的转义字符 ~，等同于 StartSSHSessionWithOptions(client, SessionOptions{EscapeChar: DefaultEscapeChar})
 // 参数:
 //   client: SSH 客户端对象
 // 返回值:
-//   error: 如果会话启动失败则返回错误信息
+//   error: 如果会话启动失败则返回错误信息；
+//          远程 shell 以非零状态退出时返回 *sshclient.ExitError，其中包含退出码
 func StartSSHSession(client *sshclient.Client) error {
+	return StartSSHSessionWithOptions(client, SessionOptions{EscapeChar: DefaultEscapeChar})
+}
+
+// StartSSHSessionWithOptions 使用指定的选项启动交互式 SSH 会话
+// 标准输入是终端时会请求伪终端，并把本地终端切换到原始模式，会话结束后恢复
+// 参数:
+//   client: SSH 客户端对象
+//   opts: 会话选项
+// 返回值:
+//   error: 如果会话启动失败则返回错误信息；
+//          远程 shell 以非零状态退出时返回 *sshclient.ExitError，其中包含退出码；
+//          用户通过 ~. 断开连接时返回 ErrEscapeDisconnect
+func StartSSHSessionWithOptions(client *sshclient.Client, opts SessionOptions) error {
 	// 获取底层的 SSH 连接
 	conn := client.GetConnection()
 
@@ -33,39 +64,145 @@ func StartSSHSession(client *sshclient.Client) error {
 	}
 	defer session.Close() // 会话结束时关闭
 
-	// 获取终端的大小信息
-	// 这样远程终端的显示效果会更好
-	fd := int(os.Stdin.Fd())
-	if term.IsTerminal(fd) {
-		// 获取当前终端的宽度和高度
-		width, height, err := term.GetSize(fd)
-		if err == nil {
-			// 请求一个伪终端，设置终端类型和大小
-			if err := session.RequestPty("xterm", height, width, ssh.TerminalModes{}); err != nil {
-				return fmt.Errorf("请求伪终端失败: %w", err)
-			}
-		}
-	}
-
 	// 连接标准输入输出
 	// 让用户的输入能够发送到远程服务器，远程的输出能够显示在本地
+	var stdin io.Reader = os.Stdin
 	session.Stdin = os.Stdin   // 用户输入发送到远程
 	session.Stdout = os.Stdout // 远程输出显示在本地
 	session.Stderr = os.Stderr // 远程错误信息显示在本地
 
+	// 获取当前终端的宽度和高度，用于请求伪终端和录像
+	fd := int(os.Stdin.Fd())
+	isTerminal := term.IsTerminal(fd)
+	width, height := defaultWidth, defaultHeight
+	if isTerminal {
+		width, height, err = term.GetSize(fd)
+		if err != nil {
+			return fmt.Errorf("获取终端大小失败: %w", err)
+		}
+	}
+
+	// 录像时把输出（和输入）同时写入录像文件，录像失败不影响会话
+	var rec *asciicast.Recorder
+	if opts.RecordFile != "" {
+		rec, err = asciicast.Create(opts.RecordFile, recordHeader(client, width, height))
+		if err != nil {
+			return err
+		}
+		defer rec.Close()
+		session.Stdout = io.MultiWriter(os.Stdout, rec.Writer(asciicast.EventOutput))
+		session.Stderr = io.MultiWriter(os.Stderr, rec.Writer(asciicast.EventOutput))
+		if opts.RecordInput {
+			stdin = io.TeeReader(os.Stdin, rec.Writer(asciicast.EventInput))
+			session.Stdin = stdin
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	var input *escapeInput
+	var remote io.WriteCloser
+	if isTerminal {
+		// 请求一个与本地终端相同大小、相同类型的伪终端
+		if err := session.RequestPty(termType(), height, width, terminalModes()); err != nil {
+			return fmt.Errorf("请求伪终端失败: %w", err)
+		}
+
+		// 切换到原始模式，按键（包括 Ctrl+C）原样发送到远程
+		// 无论会话如何结束都要恢复终端，否则本地 shell 无法正常使用
+		raw, err := makeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer raw.Close()
+
+		// 被 SIGTERM 或 SIGHUP 终止时同样恢复终端，并结束会话
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGTERM, syscall.SIGHUP)
+		defer signal.Stop(sigs)
+		go func() {
+			select {
+			case <-sigs:
+				raw.Close()
+				session.Close()
+			case <-done:
+			}
+		}()
+
+		// 本地窗口大小变化时通知远程，全屏程序才能正确重绘；录像中同样记录
+		resize := func(width, height int) {
+			session.WindowChange(height, width)
+			if rec != nil {
+				rec.Resize(width, height)
+			}
+		}
+		go watchResize(fd, done, resize)
+
+		// 启用转义命令时由 escapeInput 转发输入，识别行首的转义字符
+		if opts.EscapeChar != 0 {
+			session.Stdin = nil
+			remote, err = session.StdinPipe()
+			if err != nil {
+				return fmt.Errorf("获取会话输入失败: %w", err)
+			}
+			input = &escapeInput{
+				client:  client,
+				resize:  resize,
+				term:    raw,
+				scanner: newEscapeScanner(opts.EscapeChar),
+				stdin:   stdin,
+				output:  os.Stderr,
+			}
+		}
+	}
+
 	// 启动远程 shell
 	// 这会在远程服务器上启动一个交互式 shell
 	if err := session.Shell(); err != nil {
 		return fmt.Errorf("启动远程 shell 失败: %w", err)
 	}
+	if input != nil {
+		go input.copy(remote)
+	}
 
 	// 等待会话结束
 	// 当用户输入 exit 或者连接断开时，会话会结束
-	if err := session.Wait(); err != nil {
-		return fmt.Errorf("SSH 会话异常结束: %w", err)
+	err = session.Wait()
+	if input != nil && input.Disconnected() {
+		err = ErrEscapeDisconnect
+	} else {
+		err = sessionExitError(err)
 	}
 
-	return nil
+	// 录像不完整时必须让用户知道：会话本身成功时返回录像的错误，否则输出警告
+	if rec != nil {
+		if recErr := rec.Close(); recErr != nil {
+			if err == nil {
+				return recErr
+			}
+			fmt.Fprintf(os.Stderr, "警告: %v\n", recErr)
+		}
+	}
+	return err
+}
+
+// recordHeader 返回会话录像的头部，标题为 user@host
+// 终端大小未知（为 0）时按 80x24 记录，否则播放器无法显示
+func recordHeader(client *sshclient.Client, width, height int) asciicast.Header {
+	if width <= 0 || height <= 0 {
+		width, height = defaultWidth, defaultHeight
+	}
+	cfg := client.GetConfig()
+	env := map[string]string{"TERM": termType()}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		env["SHELL"] = shell
+	}
+	return asciicast.Header{
+		Width:  width,
+		Height: height,
+		Title:  cfg.Username + "@" + cfg.Host,
+		Env:    env,
+	}
 }
 
 // ExecuteInteractiveCommand 执行交互式命令
@@ -112,16 +249,16 @@ func ExecuteInteractiveCommand(client *sshclient.Client) error {
 			continue
 		}
 
-		// 在远程服务器上执行命令
-		output, err := client.ExecuteCommand(command)
+		// 在远程服务器上执行命令，输出在产生时立即显示
+		result, err := client.Stream(context.Background(), command, os.Stdout, os.Stderr, nil)
 		if err != nil {
 			// 显示错误信息，但不退出程序
 			fmt.Printf("命令执行失败: %v\n", err)
 			continue
 		}
-
-		// 显示命令执行结果
-		fmt.Print(output)
+		if !result.Success() {
+			fmt.Printf("命令执行失败: %v\n", &sshclient.ExitError{Result: result})
+		}
 	}
 
 	return nil
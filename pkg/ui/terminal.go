@@ -0,0 +1,121 @@
+// Package ui 的交互式终端模块
+// 把本地终端切换到原始模式，让 Ctrl+C 等按键原样发送到远程，
+// 并把本地窗口大小的变化同步到远程的伪终端
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	"gossh/internal/sshclient"
+)
+
+// defaultTerm 是没有设置 $TERM 时请求的终端类型
+const defaultTerm = "xterm-256color"
+
+// defaultWidth 和 defaultHeight 是终端大小未知时录像使用的大小
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// termType 返回请求伪终端时使用的终端类型，与本地终端保持一致
+func termType() string {
+	if t := os.Getenv("TERM"); t != "" {
+		return t
+	}
+	return defaultTerm
+}
+
+// terminalModes 返回请求伪终端时使用的终端模式
+// 本地终端处于原始模式，回显和行编辑都由远程的伪终端负责
+func terminalModes() ssh.TerminalModes {
+	return ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+}
+
+// rawTerminal 表示已经切换到原始模式的本地终端
+// 转义命令需要临时恢复终端（如 ~C 读取命令行、~^Z 挂起），之后再切换回原始模式
+type rawTerminal struct {
+	fd    int
+	state *term.State // 切换之前的终端状态
+
+	mu     sync.Mutex // 保护 raw 和 closed
+	raw    bool       // 当前是否处于原始模式
+	closed bool       // 会话已经结束，不再切换到原始模式
+}
+
+// makeRaw 把终端切换到原始模式
+// 参数:
+//   fd: 终端的文件描述符
+// 返回值:
+//   *rawTerminal: 用于恢复终端状态
+//   error: 如果无法切换则返回错误
+func makeRaw(fd int) (*rawTerminal, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("切换终端到原始模式失败: %w", err)
+	}
+	return &rawTerminal{fd: fd, state: state, raw: true}, nil
+}
+
+// Restore 临时恢复终端原来的状态，可以多次调用
+func (t *rawTerminal) Restore() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.raw {
+		term.Restore(t.fd, t.state)
+		t.raw = false
+	}
+}
+
+// Raw 重新切换到原始模式，会话已经结束时不做任何事
+// 返回值:
+//   error: 如果无法切换则返回错误
+func (t *rawTerminal) Raw() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.raw || t.closed {
+		return nil
+	}
+	if _, err := term.MakeRaw(t.fd); err != nil {
+		return fmt.Errorf("切换终端到原始模式失败: %w", err)
+	}
+	t.raw = true
+	return nil
+}
+
+// Close 恢复终端并禁止之后再切换到原始模式，可以多次调用，也可以在其他 goroutine 中调用
+func (t *rawTerminal) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.raw {
+		term.Restore(t.fd, t.state)
+		t.raw = false
+	}
+	t.closed = true
+}
+
+// sessionExitError 把 session.Wait 的结果转换成返回给调用方的错误
+// 远程 shell 以非零状态退出或被信号终止时返回 *sshclient.ExitError，调用方可以用它的退出码退出
+func sessionExitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &sshclient.ExitError{Result: &sshclient.Result{
+			ExitCode:   exitErr.ExitStatus(),
+			ExitSignal: exitErr.Signal(),
+		}}
+	}
+	return fmt.Errorf("SSH 会话异常结束: %w", err)
+}
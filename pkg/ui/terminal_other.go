@@ -0,0 +1,44 @@
+//go:build !unix
+
+// Package ui 在没有 SIGWINCH 和 SIGTSTP 的平台上的终端功能
+package ui
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// resizePollInterval 是检查终端大小的间隔
+const resizePollInterval = 250 * time.Millisecond
+
+// watchResize 定期检查终端大小，变化时调用 fn，直到 stop 被关闭
+// 参数:
+//   fd: 终端的文件描述符
+//   stop: 关闭时停止监听
+//   fn: 窗口大小变化时调用，参数为新的宽度和高度
+func watchResize(fd int, stop <-chan struct{}, fn func(width, height int)) {
+	lastWidth, lastHeight, _ := term.GetSize(fd)
+	ticker := time.NewTicker(resizePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			width, height, err := term.GetSize(fd)
+			if err != nil || (width == lastWidth && height == lastHeight) {
+				continue
+			}
+			lastWidth, lastHeight = width, height
+			fn(width, height)
+		}
+	}
+}
+
+// suspendSelf 在不支持作业控制的平台上返回错误
+func suspendSelf() error {
+	return errors.New("当前平台不支持挂起")
+}
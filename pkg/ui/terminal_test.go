@@ -0,0 +1,53 @@
+// terminal_test 提供交互式终端相关的单元测试
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// TestTermType 测试伪终端类型跟随 $TERM
+func TestTermType(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{name: "使用本地终端类型", env: "screen-256color", want: "screen-256color"},
+		{name: "没有设置时使用默认值", env: "", want: defaultTerm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", tt.env)
+			if got := termType(); got != tt.want {
+				t.Errorf("termType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTerminalModes 测试伪终端开启回显并设置了速率
+func TestTerminalModes(t *testing.T) {
+	modes := terminalModes()
+	if modes[ssh.ECHO] != 1 {
+		t.Errorf("ECHO = %d, want 1", modes[ssh.ECHO])
+	}
+	if modes[ssh.TTY_OP_ISPEED] == 0 || modes[ssh.TTY_OP_OSPEED] == 0 {
+		t.Errorf("终端速率没有设置: %v", modes)
+	}
+}
+
+// TestSessionExitError 测试会话结束状态的转换
+func TestSessionExitError(t *testing.T) {
+	if err := sessionExitError(nil); err != nil {
+		t.Errorf("sessionExitError(nil) = %v, want nil", err)
+	}
+
+	missing := &ssh.ExitMissingError{}
+	err := sessionExitError(missing)
+	if err == nil || !errors.Is(err, missing) || !contains(err.Error(), "SSH 会话异常结束") {
+		t.Errorf("sessionExitError(ExitMissingError) = %v, want 包含原始错误的会话异常结束", err)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+// Package ui 在 Unix 系统上的终端功能：监听窗口大小变化和挂起进程
+package ui
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// watchResize 在收到 SIGWINCH 时读取终端大小并调用 fn，直到 stop 被关闭
+// 参数:
+//   fd: 终端的文件描述符
+//   stop: 关闭时停止监听
+//   fn: 窗口大小变化时调用，参数为新的宽度和高度
+func watchResize(fd int, stop <-chan struct{}, fn func(width, height int)) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sigs:
+			if width, height, err := term.GetSize(fd); err == nil {
+				fn(width, height)
+			}
+		}
+	}
+}
+
+// suspendSelf 向自身发送 SIGTSTP，进程暂停直到在 shell 中用 fg 恢复
+// 返回值:
+//   error: 如果无法发送信号则返回错误
+func suspendSelf() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGTSTP)
+}
@@ -0,0 +1,172 @@
+// Package ui 的文件传输模块
+// 交互式 SFTP 会话和 UploadFile/DownloadFile 共用这里的传输逻辑，
+// 调用方负责创建和关闭 *sftp.Client，一个会话中的多次传输复用同一个 SFTP 连接
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// TransferOptions 是文件传输的选项，零值表示默认行为
+type TransferOptions struct {
+	Progress      ProgressSink  // 接收每个文件的传输进度，为 nil 时不报告
+	Symlinks      SymlinkPolicy // 递归传输时符号链接的处理策略，为空时跟随符号链接
+	Concurrency   int           // 每个文件同时传输的块数，为 0 时使用 DefaultConcurrency
+	ChunkSize     int64         // 每块的字节数，为 0 时使用 DefaultChunkSize
+	Checksum      bool          // 传输完成后比较本地和远程文件的 SHA-256，不一致时返回 *ChecksumMismatchError
+	Preserve      bool          // 把源文件的权限、访问时间和修改时间应用到目标文件
+	PreserveOwner bool          // 把源文件的所有者和组应用到目标文件，通常需要 root 权限
+
+	hasher remoteHasher // 计算远程文件的哈希，由持有 SSH 连接的入口函数设置
+}
+
+// uploadFile 把已经打开的本地文件上传到远程路径，远程文件已经存在时覆盖
+// 参数:
+//   client: SFTP 客户端对象
+//   localFile: 已经打开的本地文件，由调用方关闭
+//   remotePath: 远程文件路径
+//   opts: 传输选项
+// 返回值:
+//   int64: 传输的字节数
+//   error: 如果创建远程文件、传输、保留属性或校验失败则返回错误
+func uploadFile(client *sftp.Client, localFile *os.File, remotePath string, opts TransferOptions) (int64, error) {
+	info, err := localFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+
+	remoteFile, err := client.Create(remotePath)
+	if err != nil {
+		return 0, fmt.Errorf("创建远程文件失败: %w", err)
+	}
+	defer remoteFile.Close()
+
+	// 读取文件会改变访问时间，在传输之前取得需要保留的属性
+	attrs := localFileAttrs(localFile.Name(), info)
+
+	// 多个块同时传输，比逐块写入快得多
+	tracker := newProgressTracker(opts.Progress, localFile.Name(), 0, info.Size())
+	n, err := copyChunks(remoteFile, localFile, 0, info.Size(), opts, tracker)
+	if err != nil {
+		err = fmt.Errorf("文件传输失败: %w", err)
+	} else if err = remoteFile.Close(); err != nil {
+		err = fmt.Errorf("关闭远程文件失败: %w", err)
+	}
+	tracker.finish(err)
+	if err == nil {
+		err = preserveRemote(client, remotePath, attrs, opts)
+	}
+	if err == nil {
+		err = verifyChecksum(opts, localFile.Name(), remotePath)
+	}
+	return n, err
+}
+
+// downloadFile 把远程文件下载到本地路径，本地文件已经存在时覆盖
+// 参数:
+//   client: SFTP 客户端对象
+//   remotePath: 远程文件路径
+//   localPath: 本地文件路径
+//   opts: 传输选项
+// 返回值:
+//   int64: 传输的字节数
+//   error: 如果打开远程文件、创建本地文件、传输、保留属性或校验失败则返回错误
+func downloadFile(client *sftp.Client, remotePath, localPath string, opts TransferOptions) (int64, error) {
+	remoteFile, err := client.Open(remotePath)
+	if err != nil {
+		return 0, fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	defer remoteFile.Close()
+
+	info, err := remoteFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("获取远程文件信息失败: %w", err)
+	}
+
+	localFile, err := os.Create(localPath)
+	if err != nil {
+		return 0, fmt.Errorf("创建本地文件失败: %w", err)
+	}
+	defer localFile.Close()
+
+	// 多个块同时传输，比逐块读取快得多
+	tracker := newProgressTracker(opts.Progress, remotePath, 0, info.Size())
+	n, err := copyChunks(localFile, remoteFile, 0, info.Size(), opts, tracker)
+	if err != nil {
+		err = fmt.Errorf("文件传输失败: %w", err)
+	} else if err = localFile.Close(); err != nil {
+		err = fmt.Errorf("关闭本地文件失败: %w", err)
+	}
+	tracker.finish(err)
+	if err == nil {
+		err = preserveLocal(localPath, remoteFileAttrs(info), opts)
+	}
+	if err == nil {
+		err = verifyChecksum(opts, localPath, remotePath)
+	}
+	return n, err
+}
+
+// expandLocalPath 展开本地路径开头的 "~"
+func expandLocalPath(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
+
+// formatBytes 把字节数格式化为便于阅读的形式，如 "1.5 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		value /= unit
+		if value < unit || suffix == "TB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// transferSummary 返回传输完成后显示的统计信息，如 "1.5 MB，用时 2.1s，730.2 KB/s"
+func transferSummary(n int64, elapsed time.Duration) string {
+	summary := fmt.Sprintf("%s，用时 %s", formatBytes(n), elapsed.Round(time.Millisecond))
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		summary += fmt.Sprintf("，%s/s", formatBytes(int64(float64(n)/seconds)))
+	}
+	return summary
+}
+
+// confirm 显示提示并读取用户的回答，只有输入 y 或 yes 时返回 true
+// 输入结束时视为拒绝
+// 参数:
+//   input: 交互式会话读取命令的输入，回答从同一个缓冲区读取
+//   prompt: 提示信息
+func confirm(input *bufio.Reader, prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, err := input.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -70,14 +70,6 @@ func TestUploadFile(t *testing.T) {
 		t.Fatalf("创建测试文件失败: %v", err)
 	}
 
-	// 创建模拟客户端
-	cfg := &config.SSHConfig{
-		Host:     "192.168.1.100",
-		Port:     22,
-		Username: "root",
-		Password: "123456",
-	}
-
 	tests := []struct {
 		name       string
 		localPath  string